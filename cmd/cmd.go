@@ -20,10 +20,14 @@ import (
 	goflag "flag"
 	"fmt"
 	"io"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
 	"regexp"
 	"strconv"
 	"strings"
+	"syscall"
 	"text/template"
 	"time"
 
@@ -33,10 +37,13 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/stern/stern/stern"
+	"golang.org/x/sync/errgroup"
 	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -60,16 +67,39 @@ type options struct {
 	timestamps          string
 	timezone            string
 	since               time.Duration
+	sinceTime           string
+	until               string
 	namespaces          []string
+	namespaceRegex      []string
+	namespaceSelector   string
+	excludeNamespace    []string
 	condition           string
 	exclude             []string
 	include             []string
 	highlight           []string
+	excludeScoped       []string
+	includeScoped       []string
+	highlightScoped     []string
+	fieldFilters        []string
+	unstructured        string
+	minLevel            string
+	multiline           []string
+	multilineStart      string
+	multilineTimeout    time.Duration
+	redact              []string
+	redactPresets       []string
+	collapseRepeats     string
+	collapseWindow      time.Duration
+	beforeContext       int
+	afterContext        int
+	contextLines        int
 	initContainers      bool
 	ephemeralContainers bool
 	allNamespaces       bool
 	selector            string
 	fieldSelector       string
+	annotationSelector  string
+	podFilters          []string
 	tail                int64
 	color               string
 	version             bool
@@ -78,36 +108,58 @@ type options struct {
 	templateFile        string
 	output              string
 	prompt              bool
-	podQuery            string
+	podQueries          []string
 	noFollow            bool
-	resource            string
+	previousOnRestart   bool
+	lifecycleEvents     bool
+	events              string
+	summary             string
+	metricsAddress      string
+	ordered             bool
+	reorderWindow       time.Duration
+	outputBufferSize    int
+	outputOverflow      string
+	resources           []string
+	onlyOwned           bool
+	readyEndpoints      bool
 	verbosity           int
 	onlyLogLines        bool
 	maxLogRequests      int
 	qps                 float32
 	burst               int
 	node                string
+	nodeSelector        string
+	nodeLabels          []string
 	configFilePath      string
 	showHiddenOptions   bool
 	stdin               bool
 	diffContainer       bool
 	podColors           []string
 	containerColors     []string
+	contexts            []string
 
-	client       kubernetes.Interface
-	clientConfig clientcmd.ClientConfig
+	client        kubernetes.Interface
+	dynamicClient dynamic.Interface
+	restMapper    meta.RESTMapper
+	clientConfig  clientcmd.ClientConfig
+	clusters      []*cluster
 }
 
 func NewOptions(streams genericclioptions.IOStreams) *options {
 	configFlags := genericclioptions.NewConfigFlags(true)
 	// stern has its own namespace flag, so disable the one in configFlags
 	configFlags.Namespace = nil
+	// stern has its own context flag to allow specifying multiple contexts
+	configFlags.Context = nil
 
 	return &options{
 		configFlags: configFlags,
 		IOStreams:   streams,
 
 		color:               "auto",
+		unstructured:        string(stern.UnstructuredDrop),
+		multilineTimeout:    time.Second,
+		collapseWindow:      30 * time.Second,
 		container:           ".*",
 		containerStates:     []string{stern.ALL_STATES},
 		condition:           "",
@@ -122,17 +174,20 @@ func NewOptions(streams genericclioptions.IOStreams) *options {
 		timezone:            "Local",
 		prompt:              false,
 		noFollow:            false,
+		reorderWindow:       2 * time.Second,
+		outputBufferSize:    1024,
+		outputOverflow:      string(stern.OverflowBlock),
 		maxLogRequests:      -1,
 		configFilePath:      defaultConfigFilePath,
 	}
 }
 
 func (o *options) Complete(args []string) error {
-	if len(args) > 0 {
-		if s := args[0]; strings.Contains(s, "/") {
-			o.resource = s
+	for _, s := range args {
+		if strings.Contains(s, "/") {
+			o.resources = append(o.resources, s)
 		} else {
-			o.podQuery = s
+			o.podQueries = append(o.podQueries, s)
 		}
 	}
 
@@ -141,11 +196,70 @@ func (o *options) Complete(args []string) error {
 		o.configFilePath = envVar
 	}
 
-	o.clientConfig = o.configFlags.ToRawKubeConfigLoader()
+	contexts := makeUnique(o.contexts)
+	if len(contexts) <= 1 {
+		if len(contexts) == 1 {
+			o.configFlags.Context = &contexts[0]
+		}
+		c, err := o.newCluster(o.configFlags)
+		if err != nil {
+			return err
+		}
+		o.clusters = []*cluster{c}
+	} else {
+		o.clusters = nil
+		for _, name := range contexts {
+			c, err := o.newCluster(o.configFlagsForContext(name))
+			if err != nil {
+				return errors.Wrapf(err, "context %s", name)
+			}
+			c.context = name
+			o.clusters = append(o.clusters, c)
+		}
+	}
+
+	// the first cluster is used for the prompt and the completion
+	o.clientConfig = o.clusters[0].clientConfig
+	o.client = o.clusters[0].client
+	o.dynamicClient = o.clusters[0].dynamicClient
+	o.restMapper = o.clusters[0].restMapper
 
-	restConfig, err := o.configFlags.ToRESTConfig()
+	if len(o.namespaces) == 0 {
+		namespace, _, err := o.clientConfig.Namespace()
+		if err != nil {
+			return err
+		}
+		o.namespaces = []string{namespace}
+		// each context can be configured with a different namespace
+		for _, c := range o.clusters {
+			if c.namespace, _, err = c.clientConfig.Namespace(); err != nil {
+				return errors.Wrapf(err, "context %s", c.context)
+			}
+		}
+	}
+
+	return nil
+}
+
+// cluster holds the clients for a kubeconfig context
+type cluster struct {
+	// context is the name of the kubeconfig context, which is empty when
+	// a single context is used
+	context string
+	// namespace is the default namespace of the context, which is empty
+	// when namespaces are specified
+	namespace string
+
+	clientConfig  clientcmd.ClientConfig
+	client        kubernetes.Interface
+	dynamicClient dynamic.Interface
+	restMapper    meta.RESTMapper
+}
+
+func (o *options) newCluster(configFlags *genericclioptions.ConfigFlags) (*cluster, error) {
+	restConfig, err := configFlags.ToRESTConfig()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if o.qps != 0 {
@@ -157,29 +271,94 @@ func (o *options) Complete(args []string) error {
 		restConfig.Burst = rest.DefaultBurst
 	}
 
-	o.client = kubernetes.NewForConfigOrDie(restConfig)
+	c := &cluster{
+		clientConfig: configFlags.ToRawKubeConfigLoader(),
+		client:       kubernetes.NewForConfigOrDie(restConfig),
+	}
 
-	if len(o.namespaces) == 0 {
-		namespace, _, err := o.clientConfig.Namespace()
-		if err != nil {
-			return err
-		}
-		o.namespaces = []string{namespace}
+	c.dynamicClient, err = dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	c.restMapper, err = configFlags.ToRESTMapper()
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// configFlagsForContext returns Kubernetes flags that share the values with
+// the specified flags except the kubeconfig context.
+func (o *options) configFlagsForContext(name string) *genericclioptions.ConfigFlags {
+	f := genericclioptions.NewConfigFlags(true)
+	f.CacheDir = o.configFlags.CacheDir
+	f.KubeConfig = o.configFlags.KubeConfig
+	f.ClusterName = o.configFlags.ClusterName
+	f.AuthInfoName = o.configFlags.AuthInfoName
+	f.Context = &name
+	f.Namespace = nil
+	f.APIServer = o.configFlags.APIServer
+	f.TLSServerName = o.configFlags.TLSServerName
+	f.Insecure = o.configFlags.Insecure
+	f.CertFile = o.configFlags.CertFile
+	f.KeyFile = o.configFlags.KeyFile
+	f.CAFile = o.configFlags.CAFile
+	f.BearerToken = o.configFlags.BearerToken
+	f.Impersonate = o.configFlags.Impersonate
+	f.ImpersonateUID = o.configFlags.ImpersonateUID
+	f.ImpersonateGroup = o.configFlags.ImpersonateGroup
+	f.ImpersonateUserExtra = o.configFlags.ImpersonateUserExtra
+	f.Username = o.configFlags.Username
+	f.Password = o.configFlags.Password
+	f.Timeout = o.configFlags.Timeout
+	f.DisableCompression = o.configFlags.DisableCompression
+	f.WrapConfigFn = o.configFlags.WrapConfigFn
+	return f
 }
 
 func (o *options) Validate() error {
-	if !o.prompt && o.podQuery == "" && o.resource == "" && o.selector == "" && o.fieldSelector == "" && !o.stdin {
+	if !o.prompt && len(o.podQueries) == 0 && len(o.resources) == 0 && o.selector == "" && o.fieldSelector == "" && !o.stdin {
 		return errors.New("One of pod-query, --selector, --field-selector, --prompt or --stdin is required")
 	}
-	if o.selector != "" && o.resource != "" {
+	if o.selector != "" && len(o.resources) > 0 {
 		return errors.New("--selector and the <resource>/<name> query cannot be set at the same time")
 	}
+	if o.onlyOwned && len(o.resources) == 0 {
+		return errors.New("--only-owned requires the <resource>/<name> query")
+	}
+	if o.readyEndpoints && !hasServiceQuery(o.resources) {
+		return errors.New("--ready-endpoints requires the service/<name> query")
+	}
+	if o.beforeContext < 0 || o.afterContext < 0 || o.contextLines < 0 {
+		return errors.New("--before-context, --after-context and --context-lines must not be negative")
+	}
+	if (o.beforeContext > 0 || o.afterContext > 0 || o.contextLines > 0) && len(o.include) == 0 && len(o.includeScoped) == 0 && len(o.fieldFilters) == 0 {
+		return errors.New("--before-context, --after-context and --context-lines require --include, --include-scoped or --field-filter")
+	}
+	if o.stdin && (len(o.excludeScoped) > 0 || len(o.includeScoped) > 0 || len(o.highlightScoped) > 0) {
+		return errors.New("--exclude-scoped, --include-scoped and --highlight-scoped cannot be used with --stdin")
+	}
+	if o.reorderWindow < 0 {
+		return errors.New("--reorder-window must not be negative")
+	}
+	if o.outputBufferSize <= 0 {
+		return errors.New("--output-buffer-size must be positive")
+	}
 	if o.noFollow && o.tail == 0 {
 		return errors.New("--no-follow cannot be used with --tail=0")
 	}
+	if o.until != "" && !o.noFollow {
+		// A tail stops at the first line after --until, which might never come when following
+		return errors.New("--until requires --no-follow")
+	}
+	if o.lifecycleEvents && o.noFollow {
+		return errors.New("--lifecycle-events cannot be used with --no-follow")
+	}
+	if o.previousOnRestart && o.noFollow {
+		return errors.New("--previous-on-restart cannot be used with --no-follow")
+	}
 	if o.condition != "" && o.tail != 0 && !o.noFollow {
 		return errors.New("--condition is currently only supported with --tail=0 or --no-follow")
 	}
@@ -200,17 +379,108 @@ func (o *options) Run(cmd *cobra.Command) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	if config.Summary != nil {
+		// Ctrl-C stops the tails to print the summary instead of killing stern
+		var stop context.CancelFunc
+		ctx, stop = signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		// A second Ctrl-C kills stern when printing the summary hangs
+		go func() {
+			<-ctx.Done()
+			stop()
+		}()
+	}
+
+	if o.metricsAddress != "" {
+		config.Metrics = stern.NewMetrics()
+		stop, err := serveMetrics(o.metricsAddress, config.Metrics, o.ErrOut)
+		if err != nil {
+			return err
+		}
+		defer stop()
+	}
+
 	if o.prompt {
 		if err := promptHandler(ctx, o.client, config, o.Out); err != nil {
 			return err
 		}
 	}
 
-	return stern.Run(ctx, o.client, config)
+	// The output is shared by the runs of multiple contexts, so that their
+	// records never interleave
+	output := stern.NewOutputWriter(config.Out, config.ErrOut, config.OutputBufferSize, config.OutputOverflow)
+	defer output.Close()
+	config.Out = output
+	var ordered *stern.OrderedWriter
+	if config.Ordered && !config.Stdin {
+		// The lines of all the contexts are merged as well
+		ordered = stern.NewOrderedWriter(output, config.ErrOut, config.ReorderWindow, config.Follow, max(len(o.clusters), 1))
+		defer ordered.Close()
+		config.Out = ordered
+	}
+
+	if len(o.clusters) <= 1 || config.Stdin {
+		err = stern.Run(ctx, o.client, config)
+	} else {
+		err = runClusters(ctx, o.clusters, config)
+	}
+	// the logs are written before the summary
+	if ordered != nil {
+		ordered.Close()
+	}
+	output.Close()
+	if config.Summary == nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		// the errors of the watches stopped by Ctrl-C are not worth reporting
+		err = nil
+	}
+	if printErr := config.Summary.Print(o.ErrOut); printErr != nil && err == nil {
+		err = printErr
+	}
+	return err
+}
+
+// serveMetrics serves the metrics at /metrics on the address until stop is
+// called
+func serveMetrics(address string, metrics *stern.Metrics, errOut io.Writer) (stop func(), err error) {
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to listen for metrics")
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			fmt.Fprintf(errOut, "failed to serve metrics: %v\n", err)
+		}
+	}()
+	return func() { srv.Close() }, nil
+}
+
+// runClusters runs stern for each cluster concurrently. It returns when
+// all of them finish or one of them fails.
+func runClusters(ctx context.Context, clusters []*cluster, config *stern.Config) error {
+	eg, ctx := errgroup.WithContext(ctx)
+	for _, c := range clusters {
+		cfg := *config
+		cfg.Context = c.context
+		if c.namespace != "" && !cfg.AllNamespaces {
+			cfg.Namespaces = []string{c.namespace}
+		}
+		cfg.DynamicClient = c.dynamicClient
+		cfg.RESTMapper = c.restMapper
+		eg.Go(func() error {
+			return errors.Wrapf(stern.Run(ctx, c.client, &cfg), "context %s", c.context)
+		})
+	}
+	return eg.Wait()
 }
 
 func (o *options) sternConfig() (*stern.Config, error) {
-	pod, err := regexp.Compile(o.podQuery)
+	pod, err := compileQueries(o.podQueries)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to compile regular expression from query")
 	}
@@ -220,6 +490,24 @@ func (o *options) sternConfig() (*stern.Config, error) {
 		return nil, errors.Wrap(err, "failed to compile regular expression for excluded pod query")
 	}
 
+	namespaceQuery, err := compileREs(o.namespaceRegex)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to compile regular expression for namespace query")
+	}
+
+	excludeNamespace, err := compileREs(o.excludeNamespace)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to compile regular expression for excluded namespace query")
+	}
+
+	namespaceSelector := labels.Everything()
+	if o.namespaceSelector != "" {
+		namespaceSelector, err = labels.Parse(o.namespaceSelector)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse namespace selector as label selector")
+		}
+	}
+
 	container, err := regexp.Compile(o.container)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to compile regular expression for container query")
@@ -245,6 +533,72 @@ func (o *options) sternConfig() (*stern.Config, error) {
 		return nil, errors.Wrap(err, "failed to compile regular expression for highlight filter")
 	}
 
+	var scopedFilters []*stern.ScopedFilter
+	for _, filters := range []struct {
+		filterType stern.FilterType
+		exprs      []string
+	}{
+		{stern.FilterExclude, o.excludeScoped},
+		{stern.FilterInclude, o.includeScoped},
+		{stern.FilterHighlight, o.highlightScoped},
+	} {
+		for _, expr := range filters.exprs {
+			f, err := stern.ParseScopedFilter(filters.filterType, expr)
+			if err != nil {
+				return nil, err
+			}
+			scopedFilters = append(scopedFilters, f)
+		}
+	}
+
+	var fieldFilters []*stern.FieldFilter
+	for _, expr := range o.fieldFilters {
+		fieldFilter, err := stern.NewFieldFilter(expr)
+		if err != nil {
+			return nil, err
+		}
+		fieldFilters = append(fieldFilters, fieldFilter)
+	}
+
+	unstructured, err := stern.NewUnstructuredPolicy(o.unstructured)
+	if err != nil {
+		return nil, err
+	}
+
+	multiline, err := stern.NewMultiline(makeUnique(o.multiline), o.multilineStart)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build multiline options")
+	}
+
+	var redact []*stern.RedactRule
+	for _, name := range makeUnique(o.redactPresets) {
+		rules, err := stern.RedactPreset(name)
+		if err != nil {
+			return nil, err
+		}
+		redact = append(redact, rules...)
+	}
+	for _, expr := range o.redact {
+		rule, err := stern.NewRedactRule(expr)
+		if err != nil {
+			return nil, err
+		}
+		redact = append(redact, rule)
+	}
+
+	repeatMode, err := stern.NewRepeatMode(o.collapseRepeats)
+	if err != nil {
+		return nil, err
+	}
+
+	minLevel := stern.LevelUnknown
+	if o.minLevel != "" {
+		minLevel, err = stern.ParseLevel(o.minLevel)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse --min-level")
+		}
+	}
+
 	condition := stern.Condition{}
 	if o.condition != "" {
 		condition, err = stern.NewCondition(o.condition)
@@ -253,6 +607,23 @@ func (o *options) sternConfig() (*stern.Config, error) {
 		}
 	}
 
+	var podFilters []*stern.PodFilter
+	for _, expr := range o.podFilters {
+		podFilter, err := stern.NewPodFilter(expr)
+		if err != nil {
+			return nil, err
+		}
+		podFilters = append(podFilters, podFilter)
+	}
+
+	annotationSelector := labels.Everything()
+	if o.annotationSelector != "" {
+		annotationSelector, err = labels.Parse(o.annotationSelector)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse annotation selector as label selector")
+		}
+	}
+
 	containerStates := []stern.ContainerState{}
 	for _, containerStateStr := range makeUnique(o.containerStates) {
 		containerState, err := stern.NewContainerState(containerStateStr)
@@ -275,6 +646,14 @@ func (o *options) sternConfig() (*stern.Config, error) {
 		return nil, err
 	}
 
+	nodeSelector := labels.Everything()
+	if o.nodeSelector != "" {
+		nodeSelector, err = labels.Parse(o.nodeSelector)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse node selector as label selector")
+		}
+	}
+
 	var tailLines *int64
 	if o.tail != -1 {
 		tailLines = &o.tail
@@ -314,7 +693,48 @@ func (o *options) sternConfig() (*stern.Config, error) {
 		return nil, err
 	}
 
+	// --since-time and --until
+	sinceTime, err := parseTimeFlag("--since-time", o.sinceTime, location)
+	if err != nil {
+		return nil, err
+	}
+	until, err := parseTimeFlag("--until", o.until, location)
+	if err != nil {
+		return nil, err
+	}
+	if sinceTime != nil && until != nil && until.Before(*sinceTime) {
+		return nil, errors.New("--until must not be before --since-time")
+	}
+
+	outputOverflow, err := stern.NewOverflowPolicy(o.outputOverflow)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := stern.NewEventsMode(o.events)
+	if err != nil {
+		return nil, err
+	}
+
+	summaryFormat, err := stern.NewSummaryFormat(o.summary)
+	if err != nil {
+		return nil, err
+	}
+	var summary *stern.Summary
+	if summaryFormat != stern.SummaryOff && !o.stdin {
+		summary = stern.NewSummary(summaryFormat, location)
+	}
+
 	maxLogRequests := o.maxLogRequests
+	// --before-context and --after-context take precedence over --context-lines like grep
+	beforeContext, afterContext := o.beforeContext, o.afterContext
+	if beforeContext == 0 {
+		beforeContext = o.contextLines
+	}
+	if afterContext == 0 {
+		afterContext = o.contextLines
+	}
+
 	if maxLogRequests == -1 {
 		if o.noFollow {
 			maxLogRequests = 5
@@ -325,6 +745,9 @@ func (o *options) sternConfig() (*stern.Config, error) {
 
 	return &stern.Config{
 		Namespaces:            namespaces,
+		NamespaceQuery:        namespaceQuery,
+		ExcludeNamespaceQuery: excludeNamespace,
+		NamespaceSelector:     namespaceSelector,
 		PodQuery:              pod,
 		ExcludePodQuery:       excludePod,
 		Timestamps:            timestampFormat != "",
@@ -337,26 +760,82 @@ func (o *options) sternConfig() (*stern.Config, error) {
 		Exclude:               exclude,
 		Include:               include,
 		Highlight:             highlight,
+		ScopedFilters:         scopedFilters,
+		FieldFilters:          fieldFilters,
+		UnstructuredPolicy:    unstructured,
+		MinLevel:              minLevel,
+		Multiline:             multiline,
+		MultilineTimeout:      o.multilineTimeout,
+		Redact:                redact,
+		RepeatMode:            repeatMode,
+		RepeatWindow:          o.collapseWindow,
+		BeforeContext:         beforeContext,
+		AfterContext:          afterContext,
+		OmitContextSeparator:  o.jsonOutput(),
 		InitContainers:        o.initContainers,
 		EphemeralContainers:   o.ephemeralContainers,
 		Since:                 o.since,
+		SinceTime:             sinceTime,
+		Until:                 until,
 		AllNamespaces:         o.allNamespaces,
 		LabelSelector:         labelSelector,
 		FieldSelector:         fieldSelector,
+		NodeSelector:          nodeSelector,
+		AnnotationSelector:    annotationSelector,
+		PodFilters:            podFilters,
+		NodeLabels:            makeUnique(o.nodeLabels),
 		TailLines:             tailLines,
 		Template:              template,
 		Follow:                !o.noFollow,
-		Resource:              o.resource,
+		PreviousOnRestart:     o.previousOnRestart,
+		LifecycleEvents:       o.lifecycleEvents,
+		Events:                events,
+		Summary:               summary,
+		Ordered:               o.ordered,
+		ReorderWindow:         o.reorderWindow,
+		OutputBufferSize:      o.outputBufferSize,
+		OutputOverflow:        outputOverflow,
+		Resources:             makeUnique(o.resources),
+		OnlyOwned:             o.onlyOwned,
+		ReadyEndpoints:        o.readyEndpoints,
 		OnlyLogLines:          o.onlyLogLines,
 		MaxLogRequests:        maxLogRequests,
 		Stdin:                 o.stdin,
 		DiffContainer:         o.diffContainer,
+		DynamicClient:         o.dynamicClient,
+		RESTMapper:            o.restMapper,
 
 		Out:    o.Out,
 		ErrOut: o.ErrOut,
 	}, nil
 }
 
+// localTimeLayouts are the layouts of the times without a timezone accepted by
+// --since-time and --until
+var localTimeLayouts = []string{
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+}
+
+// parseTimeFlag parses the time in RFC3339, or in one of localTimeLayouts in
+// the location. It returns nil if the value is empty.
+func parseTimeFlag(name, value string, location *time.Location) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return &t, nil
+	}
+	for _, layout := range localTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, location); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("%s should be in RFC3339 like 2006-01-02T15:04:05Z07:00 or in a local time like '2006-01-02 15:04:05': %q", name, value)
+}
+
 // setVerbosity sets the log level verbosity
 func (o *options) setVerbosity() error {
 	// klog does not have an external method to set verbosity,
@@ -392,6 +871,48 @@ func (o *options) setColorList() error {
 	return nil
 }
 
+// isScopedFilterFlag returns if the flag accepts filters with scopes
+func isScopedFilterFlag(name string) bool {
+	switch name {
+	case "exclude-scoped", "include-scoped", "highlight-scoped":
+		return true
+	}
+	return false
+}
+
+// scopeValueEscaper escapes the separators in the values of the scope
+var scopeValueEscaper = strings.NewReplacer(",", `\,`, ":", `\:`)
+
+// formatScopedFilter formats the filter in the structured form of the config
+// file into the form of "<key>=<value>[,<key>=<value>...]:<regex>"
+//
+//	exclude-scoped:
+//	  - container: istio-proxy
+//	    pattern: GET /healthz
+func formatScopedFilter(m map[string]any) (string, error) {
+	pattern, ok := m["pattern"]
+	if !ok {
+		return "", errors.New("pattern is required")
+	}
+	var scope []string
+	for _, key := range []string{"namespace", "pod", "container"} {
+		if v, ok := m[key]; ok {
+			scope = append(scope, fmt.Sprintf("%s=%s", key, scopeValueEscaper.Replace(fmt.Sprint(v))))
+		}
+	}
+	for key := range m {
+		switch key {
+		case "pattern", "namespace", "pod", "container":
+		default:
+			return "", fmt.Errorf("unknown key %q", key)
+		}
+	}
+	if len(scope) == 0 {
+		return "", errors.New("one of namespace, pod and container is required")
+	}
+	return strings.Join(scope, ",") + ":" + fmt.Sprint(pattern), nil
+}
+
 // overrideFlagSetDefaultFromConfig overrides the default value of the flagSets
 // from the config file
 func (o *options) overrideFlagSetDefaultFromConfig(fs *pflag.FlagSet) error {
@@ -438,6 +959,14 @@ func (o *options) overrideFlagSetDefaultFromConfig(fs *pflag.FlagSet) error {
 			if flagSlice, ok := flag.Value.(pflag.SliceValue); ok {
 				values := make([]string, len(valueSlice))
 				for i, v := range valueSlice {
+					if m, ok := v.(map[string]any); ok && isScopedFilterFlag(name) {
+						expr, err := formatScopedFilter(m)
+						if err != nil {
+							return fmt.Errorf("invalid value %v for %q in the config file: %v", value, name, err)
+						}
+						values[i] = expr
+						continue
+					}
 					values[i] = fmt.Sprint(v)
 				}
 				if err := flagSlice.Replace(values); err != nil {
@@ -461,34 +990,71 @@ func (o *options) AddFlags(fs *pflag.FlagSet) {
 
 	fs.BoolVarP(&o.allNamespaces, "all-namespaces", "A", o.allNamespaces, "If present, tail across all namespaces. A specific namespace is ignored even if specified with --namespace.")
 	fs.StringVar(&o.color, "color", o.color, "Force set color output. 'auto':  colorize if tty attached, 'always': always colorize, 'never': never colorize.")
+	fs.StringSliceVar(&o.contexts, "context", o.contexts, "The name of the kubeconfig context to use. To tail multiple clusters at once, repeat this or set comma-separated value.")
 	fs.StringVar(&o.completion, "completion", o.completion, "Output stern command-line completion code for the specified shell. Can be 'bash', 'zsh' or 'fish'.")
 	fs.StringVarP(&o.container, "container", "c", o.container, "Container name when multiple containers in pod. (regular expression)")
 	fs.StringSliceVar(&o.containerStates, "container-state", o.containerStates, "Tail containers with state in running, waiting, terminated, or all. 'all' matches all container states. To specify multiple states, repeat this or set comma-separated value.")
 	fs.StringArrayVarP(&o.exclude, "exclude", "e", o.exclude, "Log lines to exclude. (regular expression)")
+	fs.StringArrayVar(&o.excludeScoped, "exclude-scoped", o.excludeScoped, "Log lines to exclude only from the containers in the scope, like 'container=istio-proxy:GET /healthz'. The scope is one or more of namespace=, pod= and container= separated by commas, where ',' and ':' in the values are escaped by a backslash. (regular expression)")
 	fs.StringArrayVarP(&o.excludeContainer, "exclude-container", "E", o.excludeContainer, "Container name to exclude when multiple containers in pod. (regular expression)")
+	fs.StringArrayVar(&o.excludeNamespace, "exclude-namespace", o.excludeNamespace, "Namespace name to exclude. (regular expression)")
 	fs.StringArrayVar(&o.excludePod, "exclude-pod", o.excludePod, "Pod name to exclude. (regular expression)")
 	fs.StringVar(&o.condition, "condition", o.condition, "The condition to filter on: [condition-name[=condition-value]. The default condition-value is true. Match is case-insensitive. Currently only supported with --tail=0 or --no-follow.")
 	fs.BoolVar(&o.noFollow, "no-follow", o.noFollow, "Exit when all logs have been shown.")
+	fs.BoolVar(&o.previousOnRestart, "previous-on-restart", o.previousOnRestart, "Print the logs of the previous container that have not been printed when a container restarts, such as the last lines before a crash, and then tail the new container. It cannot be used with --no-follow.")
+	fs.BoolVar(&o.lifecycleEvents, "lifecycle-events", o.lifecycleEvents, "Print the lifecycle transitions of the containers in the logs, such as waiting with CrashLoopBackOff, terminated with OOMKilled and the exit code, restarts and readiness changes. They are printed regardless of --include, --exclude and --min-level, and the details are in the event field of the json output. It cannot be used with --no-follow.")
+	fs.StringVar(&o.events, "events", o.events, "Print the Kubernetes events of the tailed pods with the logs, such as scheduling failures, image pull errors and failed probes. One of 'pods' or 'owners', which also prints the events of the owners such as ReplicaSets and Jobs, in the form '--events=mode' ('=' cannot be omitted). If specified but without value, 'pods' is used. Events are filtered like log lines, and .Kind is 'Event' in the template.")
+	fs.StringVar(&o.summary, "summary", o.summary, "Print the statistics of each container to stderr on exit or Ctrl-C, such as the numbers of the lines read, matched, printed and excluded by the filters, bytes, retries and errors. One of 'table' or 'json' in the form '--summary=format' ('=' cannot be omitted). If specified but without value, 'table' is used. Ignored with --stdin.")
+	fs.StringVar(&o.metricsAddress, "metrics-address", o.metricsAddress, "Serve Prometheus metrics at /metrics on the address such as ':9100', including the active tails, lines and bytes per container, retries, watch reconnects, template errors and the lines matching each --include and --highlight pattern. Disabled if empty.")
+	fs.BoolVar(&o.ordered, "ordered", o.ordered, "Merge the logs of all containers in the order of their timestamps. Lines are held for --reorder-window when following, or until all the containers have passed them with --no-follow. The lines of multiple contexts are merged as well. At most 10000 lines are held, beyond which the oldest ones are printed with a warning.")
+	fs.IntVar(&o.outputBufferSize, "output-buffer-size", o.outputBufferSize, "The number of log lines buffered before they are written to the output.")
+	fs.StringVar(&o.outputOverflow, "output-overflow", o.outputOverflow, "What to do when the output buffer is full because the output cannot keep up. One of 'block' to slow down reading the logs, or 'drop' to drop the lines and report the number of them.")
+	fs.DurationVar(&o.reorderWindow, "reorder-window", o.reorderWindow, "Time to hold lines to merge them by their timestamps with --ordered. Longer windows tolerate more delays of the log streams but delay the output.")
 	fs.StringArrayVarP(&o.include, "include", "i", o.include, "Log lines to include. (regular expression)")
+	fs.StringArrayVar(&o.includeScoped, "include-scoped", o.includeScoped, "Log lines to include only from the containers in the scope, like 'pod=api-.*:error'. The scope is the same as --exclude-scoped. (regular expression)")
 	fs.StringArrayVarP(&o.highlight, "highlight", "H", o.highlight, "Log lines to highlight. (regular expression)")
+	fs.StringArrayVar(&o.highlightScoped, "highlight-scoped", o.highlightScoped, "Log lines to highlight only in the containers in the scope, like 'namespace=prod:timeout'. The scope is the same as --exclude-scoped. (regular expression)")
+	fs.StringArrayVar(&o.fieldFilters, "field-filter", o.fieldFilters, "Filter JSON or logfmt log lines by their fields, e.g. 'level in (error,fatal) && status >= 500 && path =~ \"^/api\"'. To specify multiple filters, repeat this. All the filters must match.")
+	fs.StringVar(&o.unstructured, "field-filter-unstructured", o.unstructured, "What to do with log lines that are neither JSON nor logfmt when --field-filter is set. One of 'drop' or 'keep'.")
+	fs.StringVar(&o.minLevel, "min-level", o.minLevel, "Drop log lines below the level. One of trace, debug, info, warn, error and fatal. The level is detected from JSON, logfmt, klog and plain prefixes like 'WARN'. Lines without a detected level are kept.")
+	fs.StringSliceVar(&o.multiline, "multiline", o.multiline, fmt.Sprintf("Join multi-line log entries such as stack traces into one message using the presets. One or more of %s.", strings.Join(stern.MultilinePresetNames(), ", ")))
+	fs.StringVar(&o.multilineStart, "multiline-start", o.multilineStart, "Join multi-line log entries into one message where lines matching this regular expression start a new entry, e.g. '^\\d{4}-\\d{2}-\\d{2}'.")
+	fs.DurationVar(&o.multilineTimeout, "multiline-timeout", o.multilineTimeout, "Time to wait for the next line before printing a pending multi-line entry. Zero waits until the next entry starts or the log ends.")
+	fs.StringArrayVar(&o.redact, "redact", o.redact, "Redact the strings matching the regular expression from log messages before the output. The form is '<regex>' or '<regex>=><replacement>', where the replacement may refer to submatches like '${1}' and defaults to '[REDACTED]'. To specify multiple rules, repeat this.")
+	fs.StringSliceVar(&o.redactPresets, "redact-preset", o.redactPresets, fmt.Sprintf("Redact well-known secrets and personal information from log messages before the output. One or more of %s.", strings.Join(stern.RedactPresetNames(), ", ")))
+	fs.StringVar(&o.collapseRepeats, "collapse-repeats", o.collapseRepeats, "Suppress consecutive repeated log lines of each container and print 'last message repeated N times' instead. One of 'exact' or 'normalized', which ignores the differences of numbers, UUIDs and timestamps. The count is available as .RepeatCount in the template.")
+	fs.DurationVar(&o.collapseWindow, "collapse-window", o.collapseWindow, "Time after which the number of the repeated log lines is printed even if they continue. Zero waits until a different line comes or the log ends.")
+	fs.IntVarP(&o.beforeContext, "before-context", "B", o.beforeContext, "Number of lines to show before each line matching --include, like grep -B.")
+	fs.IntVar(&o.afterContext, "after-context", o.afterContext, "Number of lines to show after each line matching --include, like grep -A.")
+	fs.IntVarP(&o.contextLines, "context-lines", "C", o.contextLines, "Number of lines to show before and after each line matching --include, like grep -C.")
 	fs.BoolVar(&o.initContainers, "init-containers", o.initContainers, "Include or exclude init containers.")
 	fs.BoolVar(&o.ephemeralContainers, "ephemeral-containers", o.ephemeralContainers, "Include or exclude ephemeral containers.")
 	fs.StringSliceVarP(&o.namespaces, "namespace", "n", o.namespaces, "Kubernetes namespace to use. Default to namespace configured in kubernetes context. To specify multiple namespaces, repeat this or set comma-separated value.")
+	fs.StringArrayVar(&o.namespaceRegex, "namespace-regex", o.namespaceRegex, "Namespace name to tail, including namespaces created later. A specific namespace is ignored even if specified with --namespace. Pods are watched across all namespaces, which requires the permissions to list and watch pods cluster-wide. (regular expression)")
+	fs.StringVar(&o.namespaceSelector, "namespace-selector", o.namespaceSelector, "Selector (label query) of namespaces to tail, including namespaces created or labeled later. A specific namespace is ignored even if specified with --namespace. Pods are watched across all namespaces, which requires the permissions to list and watch pods and namespaces cluster-wide.")
 	fs.StringVar(&o.node, "node", o.node, "Node name to filter on.")
+	fs.StringVar(&o.nodeSelector, "node-selector", o.nodeSelector, "Selector (label query) of nodes to filter on. Pods scheduled on the matching nodes are tailed, following nodes joining or leaving.")
+	fs.StringSliceVar(&o.nodeLabels, "node-label", o.nodeLabels, "Node label key to expose as .NodeLabels in the template, e.g. topology.kubernetes.io/zone. To specify multiple keys, repeat this or set comma-separated value.")
 	fs.IntVar(&o.maxLogRequests, "max-log-requests", o.maxLogRequests, "Maximum number of concurrent logs to request. Defaults to 50, but 5 when specifying --no-follow")
 	fs.Float32Var(&o.qps, "qps", o.qps, "Maximum QPS to the Kubernetes API server. Defaults to 0 (use client-go default). Use -1 to disable client-side throttling.")
 	fs.IntVar(&o.burst, "burst", o.burst, "Maximum burst for throttle to the Kubernetes API server. Defaults to 0 (use client-go default). Ignored when --qps=-1.")
 	fs.StringVarP(&o.output, "output", "o", o.output, "Specify predefined template. Currently support: [default, raw, json, extjson, ppextjson]")
 	fs.BoolVarP(&o.prompt, "prompt", "p", o.prompt, "Toggle interactive prompt for selecting 'app.kubernetes.io/instance' label values.")
 	fs.StringVarP(&o.selector, "selector", "l", o.selector, "Selector (label query) to filter on. If present, default to \".*\" for the pod-query.")
+	fs.StringVar(&o.annotationSelector, "annotation-selector", o.annotationSelector, "Selector (label query) on the pod annotations to filter on.")
+	fs.StringArrayVar(&o.podFilters, "pod-filter", o.podFilters, "Client-side pod filter in the form of '<field><operator><value>'. The field is a JSONPath expression like '{.spec.priority}' or one of 'phase', 'image', 'serviceAccount' and 'qosClass'. The operator is one of =, ==, !=, =~, !~, <, <=, > and >=. To specify multiple filters, repeat this.")
 	fs.StringVar(&o.fieldSelector, "field-selector", o.fieldSelector, "Selector (field query) to filter on. If present, default to \".*\" for the pod-query.")
 	fs.DurationVarP(&o.since, "since", "s", o.since, "Return logs newer than a relative duration like 5s, 2m, or 3h.")
+	fs.StringVar(&o.sinceTime, "since-time", o.sinceTime, "Return logs after an absolute time in RFC3339 like 2024-01-02T15:04:05Z, or in a local time like '2024-01-02 15:04:05' in the --timezone. It takes precedence over --since.")
+	fs.StringVar(&o.until, "until", o.until, "Stop tailing each container when its logs pass an absolute time in the same format as --since-time. It requires --no-follow, and combined with --since-time it extracts a time window.")
 	fs.Int64Var(&o.tail, "tail", o.tail, "The number of lines from the end of the logs to show. Defaults to -1, showing all logs.")
 	fs.StringVar(&o.template, "template", o.template, "Template to use for log lines, leave empty to use --output flag.")
 	fs.StringVarP(&o.templateFile, "template-file", "T", o.templateFile, "Path to template to use for log lines, leave empty to use --output flag. It overrides --template option.")
 	fs.StringVarP(&o.timestamps, "timestamps", "t", o.timestamps, "Print timestamps with the specified format. One of 'default' or 'short' in the form '--timestamps=format' ('=' cannot be omitted). If specified but without value, 'default' is used.")
 	fs.StringVar(&o.timezone, "timezone", o.timezone, "Set timestamps to specific timezone.")
 	fs.BoolVar(&o.onlyLogLines, "only-log-lines", o.onlyLogLines, "Print only log lines")
+	fs.BoolVar(&o.onlyOwned, "only-owned", o.onlyOwned, "Tail only pods owned by the resource of the <resource>/<name> query by following ownerReferences. The labels of the resource are still used to select the pods.")
+	fs.BoolVar(&o.readyEndpoints, "ready-endpoints", o.readyEndpoints, "Tail only pods that are ready endpoints of the service of the service/<name> query by following its EndpointSlices.")
 	fs.StringVar(&o.configFilePath, "config", o.configFilePath, "Path to the stern config file")
 	fs.IntVar(&o.verbosity, "verbosity", o.verbosity, "Number of the log level verbosity")
 	fs.BoolVarP(&o.version, "version", "v", o.version, "Print the version and exit.")
@@ -499,6 +1065,8 @@ func (o *options) AddFlags(fs *pflag.FlagSet) {
 	fs.StringSliceVar(&o.containerColors, "container-colors", o.containerColors, "Specifies the colors used to highlight container names. Use the same format as --pod-colors. Defaults to the values of --pod-colors if omitted, and must match its length.")
 
 	fs.Lookup("timestamps").NoOptDefVal = "default"
+	fs.Lookup("events").NoOptDefVal = string(stern.EventsPods)
+	fs.Lookup("summary").NoOptDefVal = string(stern.SummaryTable)
 }
 
 func (o *options) addKubernetesFlags(fs *pflag.FlagSet) {
@@ -506,7 +1074,7 @@ func (o *options) addKubernetesFlags(fs *pflag.FlagSet) {
 	o.configFlags.AddFlags(flagset)
 	flagset.VisitAll(func(f *pflag.Flag) {
 		// Hide Kubernetes flags except some
-		if f.Name != "kubeconfig" && f.Name != "context" {
+		if f.Name != "kubeconfig" {
 			f.Hidden = true
 		}
 
@@ -529,6 +1097,19 @@ func (o *options) outputHiddenOptions() {
 	fs.PrintDefaults()
 }
 
+// dynamicNamespaces returns if namespaces are discovered by the query or selector
+func (o *options) dynamicNamespaces() bool {
+	return len(o.namespaceRegex) > 0 || o.namespaceSelector != ""
+}
+
+// jsonOutput returns if the records are printed by a predefined JSON template
+func (o *options) jsonOutput() bool {
+	if o.template != "" || o.templateFile != "" {
+		return false
+	}
+	return o.output == "json" || o.output == "extjson" || o.output == "ppextjson"
+}
+
 func (o *options) generateTemplate() (*template.Template, error) {
 	t := o.template
 	if o.templateFile != "" {
@@ -541,25 +1122,34 @@ func (o *options) generateTemplate() (*template.Template, error) {
 	if t == "" {
 		switch o.output {
 		case "default":
-			t = "{{color .PodColor .PodName}} {{color .ContainerColor .ContainerName}} {{if .Timestamp}}{{.Timestamp}} {{end}}{{.Message}}"
-			if o.allNamespaces || len(o.namespaces) > 1 {
+			t = "{{color .PodColor .PodName}} {{color .ContainerColor .ContainerName}} {{with .Kind}}{{colorMagenta .}} {{end}}{{if .Timestamp}}{{.Timestamp}} {{end}}{{.Message}}"
+			if o.allNamespaces || o.dynamicNamespaces() || len(o.namespaces) > 1 {
 				t = fmt.Sprintf("{{color .PodColor .Namespace}} %s", t)
 			}
+			if len(o.clusters) > 1 {
+				t = fmt.Sprintf("{{color .PodColor .Context}} %s", t)
+			}
 		case "raw":
-			t = "{{if .Timestamp}}{{.Timestamp}} {{end}}{{.Message}}"
+			t = "{{with .Kind}}{{.}} {{end}}{{if .Timestamp}}{{.Timestamp}} {{end}}{{.Message}}"
 		case "json":
 			t = "{{json .}}"
 		case "extjson":
-			t = "\"pod\": \"{{color .PodColor .PodName}}\", \"container\": \"{{color .ContainerColor .ContainerName}}\", \"message\": {{extjson .Message}}"
-			if o.allNamespaces {
+			t = "\"pod\": \"{{color .PodColor .PodName}}\", \"container\": \"{{color .ContainerColor .ContainerName}}\", {{with .Kind}}\"kind\": \"{{colorMagenta .}}\", {{end}}{{with .Level}}\"level\": \"{{.}}\", {{end}}\"message\": {{extjson .Message}}"
+			if o.allNamespaces || o.dynamicNamespaces() {
 				t = fmt.Sprintf("\"namespace\": \"{{color .PodColor .Namespace}}\", %s", t)
 			}
+			if len(o.clusters) > 1 {
+				t = fmt.Sprintf("\"context\": \"{{color .PodColor .Context}}\", %s", t)
+			}
 			t = fmt.Sprintf("{%s}", t)
 		case "ppextjson":
-			t = "  \"pod\": \"{{color .PodColor .PodName}}\",\n  \"container\": \"{{color .ContainerColor .ContainerName}}\",\n  \"message\": {{extjson .Message}}"
-			if o.allNamespaces {
+			t = "  \"pod\": \"{{color .PodColor .PodName}}\",\n  \"container\": \"{{color .ContainerColor .ContainerName}}\",\n{{with .Kind}}  \"kind\": \"{{colorMagenta .}}\",\n{{end}}{{with .Level}}  \"level\": \"{{.}}\",\n{{end}}  \"message\": {{extjson .Message}}"
+			if o.allNamespaces || o.dynamicNamespaces() {
 				t = fmt.Sprintf("  \"namespace\": \"{{color .PodColor .Namespace}}\",\n%s", t)
 			}
+			if len(o.clusters) > 1 {
+				t = fmt.Sprintf("  \"context\": \"{{color .PodColor .Context}}\",\n%s", t)
+			}
 			t = fmt.Sprintf("{\n%s\n}", t)
 		default:
 			return nil, errors.New("output should be one of 'default', 'raw', 'json', 'extjson', and 'ppextjson'")
@@ -779,7 +1369,7 @@ func NewSternCmd(stream genericclioptions.IOStreams) (*cobra.Command, error) {
 	o := NewOptions(stream)
 
 	cmd := &cobra.Command{
-		Use:   "stern pod-query",
+		Use:   "stern pod-query...",
 		Short: "Tail multiple pods and containers from Kubernetes",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// klog's v flag should be initialized before creating a k8s client
@@ -852,6 +1442,35 @@ func makeUnique(items []string) []string {
 	return result
 }
 
+// hasServiceQuery returns if one of the resources is a service query
+func hasServiceQuery(resources []string) bool {
+	for _, resource := range resources {
+		kind, _, _ := strings.Cut(resource, "/")
+		if stern.ServiceMatcher.Matches(kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// compileQueries compiles the pod queries into a regular expression that
+// matches any of them
+func compileQueries(queries []string) (*regexp.Regexp, error) {
+	queries = makeUnique(queries)
+	if len(queries) <= 1 {
+		return regexp.Compile(strings.Join(queries, ""))
+	}
+	ss := make([]string, len(queries))
+	for i, q := range queries {
+		// compile each query to report which query is invalid
+		if _, err := regexp.Compile(q); err != nil {
+			return nil, err
+		}
+		ss[i] = "(?:" + q + ")"
+	}
+	return regexp.Compile(strings.Join(ss, "|"))
+}
+
 func compileREs(exprs []string) ([]*regexp.Regexp, error) {
 	var regexps []*regexp.Regexp
 	for _, s := range exprs {
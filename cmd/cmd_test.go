@@ -65,6 +65,8 @@ func TestOptionsComplete(t *testing.T) {
 		env                    map[string]string
 		args                   []string
 		expectedConfigFilePath string
+		expectedPodQueries     []string
+		expectedResources      []string
 	}{
 		{
 			name:                   "No environment variables",
@@ -72,6 +74,14 @@ func TestOptionsComplete(t *testing.T) {
 			args:                   []string{},
 			expectedConfigFilePath: defaultConfigFilePath,
 		},
+		{
+			name:                   "Multiple queries",
+			env:                    map[string]string{},
+			args:                   []string{"deploy/api", "web-.*", "sts/db", "job/migrate"},
+			expectedConfigFilePath: defaultConfigFilePath,
+			expectedPodQueries:     []string{"web-.*"},
+			expectedResources:      []string{"deploy/api", "sts/db", "job/migrate"},
+		},
 		{
 			name: "Set STERNCONFIG env to ./config.yaml",
 			env: map[string]string{
@@ -94,6 +104,12 @@ func TestOptionsComplete(t *testing.T) {
 			if tt.expectedConfigFilePath != o.configFilePath {
 				t.Errorf("expected %s for configFilePath, but got %s", tt.expectedConfigFilePath, o.configFilePath)
 			}
+			if !reflect.DeepEqual(tt.expectedPodQueries, o.podQueries) {
+				t.Errorf("expected %v for podQueries, but got %v", tt.expectedPodQueries, o.podQueries)
+			}
+			if !reflect.DeepEqual(tt.expectedResources, o.resources) {
+				t.Errorf("expected %v for resources, but got %v", tt.expectedResources, o.resources)
+			}
 		})
 	}
 }
@@ -116,17 +132,165 @@ func TestOptionsValidate(t *testing.T) {
 			func() *options {
 				o := NewOptions(streams)
 				o.selector = "app=nginx"
-				o.resource = "deployment/nginx"
+				o.resources = []string{"deployment/nginx"}
 
 				return o
 			}(),
 			"--selector and the <resource>/<name> query cannot be set at the same time",
 		},
+		{
+			"Specify --only-owned without resource",
+			func() *options {
+				o := NewOptions(streams)
+				o.podQueries = []string{"."}
+				o.onlyOwned = true
+
+				return o
+			}(),
+			"--only-owned requires the <resource>/<name> query",
+		},
+		{
+			"Specify --only-owned with resource",
+			func() *options {
+				o := NewOptions(streams)
+				o.resources = []string{"deployment/nginx"}
+				o.onlyOwned = true
+
+				return o
+			}(),
+			"",
+		},
+		{
+			"Specify --ready-endpoints without service",
+			func() *options {
+				o := NewOptions(streams)
+				o.resources = []string{"deployment/nginx"}
+				o.readyEndpoints = true
+
+				return o
+			}(),
+			"--ready-endpoints requires the service/<name> query",
+		},
+		{
+			"Specify --ready-endpoints with service",
+			func() *options {
+				o := NewOptions(streams)
+				o.resources = []string{"deployment/nginx", "svc/nginx"}
+				o.readyEndpoints = true
+
+				return o
+			}(),
+			"",
+		},
+		{
+			"Specify --context-lines without --include",
+			func() *options {
+				o := NewOptions(streams)
+				o.podQueries = []string{"."}
+				o.contextLines = 3
+
+				return o
+			}(),
+			"--before-context, --after-context and --context-lines require --include, --include-scoped or --field-filter",
+		},
+		{
+			"Specify --until without --no-follow",
+			func() *options {
+				o := NewOptions(streams)
+				o.podQueries = []string{"."}
+				o.until = "2023-02-13T21:20:30Z"
+
+				return o
+			}(),
+			"--until requires --no-follow",
+		},
+		{
+			"Specify --previous-on-restart with --no-follow",
+			func() *options {
+				o := NewOptions(streams)
+				o.podQueries = []string{"."}
+				o.previousOnRestart = true
+				o.noFollow = true
+
+				return o
+			}(),
+			"--previous-on-restart cannot be used with --no-follow",
+		},
+		{
+			"Specify --lifecycle-events with --no-follow",
+			func() *options {
+				o := NewOptions(streams)
+				o.podQueries = []string{"."}
+				o.lifecycleEvents = true
+				o.noFollow = true
+
+				return o
+			}(),
+			"--lifecycle-events cannot be used with --no-follow",
+		},
+		{
+			"Specify --include-scoped with --stdin",
+			func() *options {
+				o := NewOptions(streams)
+				o.stdin = true
+				o.includeScoped = []string{"container=app:error"}
+
+				return o
+			}(),
+			"--exclude-scoped, --include-scoped and --highlight-scoped cannot be used with --stdin",
+		},
+		{
+			"Specify negative --before-context",
+			func() *options {
+				o := NewOptions(streams)
+				o.podQueries = []string{"."}
+				o.include = []string{"error"}
+				o.beforeContext = -1
+
+				return o
+			}(),
+			"--before-context, --after-context and --context-lines must not be negative",
+		},
+		{
+			"Specify negative --reorder-window",
+			func() *options {
+				o := NewOptions(streams)
+				o.podQueries = []string{"."}
+				o.ordered = true
+				o.reorderWindow = -time.Second
+
+				return o
+			}(),
+			"--reorder-window must not be negative",
+		},
+		{
+			"Specify zero --output-buffer-size",
+			func() *options {
+				o := NewOptions(streams)
+				o.podQueries = []string{"."}
+				o.outputBufferSize = 0
+
+				return o
+			}(),
+			"--output-buffer-size must be positive",
+		},
+		{
+			"Specify --after-context with --include",
+			func() *options {
+				o := NewOptions(streams)
+				o.podQueries = []string{"."}
+				o.include = []string{"error"}
+				o.afterContext = 3
+
+				return o
+			}(),
+			"",
+		},
 		{
 			"Specify both --no-follow and --tail=0",
 			func() *options {
 				o := NewOptions(streams)
-				o.podQuery = "."
+				o.podQueries = []string{"."}
 				o.noFollow = true
 				o.tail = 0
 
@@ -138,7 +302,7 @@ func TestOptionsValidate(t *testing.T) {
 			"Specify --condition without --tail=0 and no --no-follow",
 			func() *options {
 				o := NewOptions(streams)
-				o.podQuery = "."
+				o.podQueries = []string{"."}
 				o.condition = "ready=false"
 
 				return o
@@ -149,7 +313,7 @@ func TestOptionsValidate(t *testing.T) {
 			"Specify --condition without --no-follow and no --tail=0",
 			func() *options {
 				o := NewOptions(streams)
-				o.podQuery = "."
+				o.podQueries = []string{"."}
 				o.condition = "ready=false"
 
 				return o
@@ -170,7 +334,7 @@ func TestOptionsValidate(t *testing.T) {
 			"Specify pod-query",
 			func() *options {
 				o := NewOptions(streams)
-				o.podQuery = "."
+				o.podQueries = []string{"."}
 
 				return o
 			}(),
@@ -250,6 +414,60 @@ func TestOptionsGenerateTemplate(t *testing.T) {
 			"ns1 pod1 container1 default message\n",
 			false,
 		},
+		{
+			"output=default+namespaceRegex",
+			func() *options {
+				o := NewOptions(streams)
+				o.output = "default"
+				o.namespaceRegex = []string{"ns.*"}
+
+				return o
+			}(),
+			"default message",
+			"ns1 pod1 container1 default message\n",
+			false,
+		},
+		{
+			"output=default+multipleContexts",
+			func() *options {
+				o := NewOptions(streams)
+				o.output = "default"
+				o.clusters = []*cluster{{context: "ctx1"}, {context: "ctx2"}}
+
+				return o
+			}(),
+			"default message",
+			"ctx1 pod1 container1 default message\n",
+			false,
+		},
+		{
+			"output=default+allNamespaces+multipleContexts",
+			func() *options {
+				o := NewOptions(streams)
+				o.output = "default"
+				o.allNamespaces = true
+				o.clusters = []*cluster{{context: "ctx1"}, {context: "ctx2"}}
+
+				return o
+			}(),
+			"default message",
+			"ctx1 ns1 pod1 container1 default message\n",
+			false,
+		},
+		{
+			"output=json+multipleContexts",
+			func() *options {
+				o := NewOptions(streams)
+				o.output = "json"
+				o.clusters = []*cluster{{context: "ctx1"}, {context: "ctx2"}}
+
+				return o
+			}(),
+			"json message",
+			`{"message":"json message","context":"ctx1","nodeName":"node1","namespace":"ns1","podName":"pod1","containerName":"container1","labels":{"app":"nginx","env":"prod"},"annotations":{"version":"1.23.4"}}
+`,
+			false,
+		},
 		{
 			"output=raw",
 			func() *options {
@@ -299,6 +517,20 @@ func TestOptionsGenerateTemplate(t *testing.T) {
 			}(),
 			`{"msg":"extjson message"}`,
 			`{"namespace": "ns1", "pod": "pod1", "container": "container1", "message": {"msg":"extjson message"}}
+`,
+			false,
+		},
+		{
+			"output=extjson+multipleContexts",
+			func() *options {
+				o := NewOptions(streams)
+				o.output = "extjson"
+				o.clusters = []*cluster{{context: "ctx1"}, {context: "ctx2"}}
+
+				return o
+			}(),
+			`{"msg":"extjson message"}`,
+			`{"context": "ctx1", "pod": "pod1", "container": "container1", "message": {"msg":"extjson message"}}
 `,
 			false,
 		},
@@ -335,6 +567,25 @@ func TestOptionsGenerateTemplate(t *testing.T) {
   "container": "container1",
   "message": {"msg":"ppextjson message"}
 }
+`,
+			false,
+		},
+		{
+			"output=ppextjson+multipleContexts",
+			func() *options {
+				o := NewOptions(streams)
+				o.output = "ppextjson"
+				o.clusters = []*cluster{{context: "ctx1"}, {context: "ctx2"}}
+
+				return o
+			}(),
+			`{"msg":"ppextjson message"}`,
+			`{
+  "context": "ctx1",
+  "pod": "pod1",
+  "container": "container1",
+  "message": {"msg":"ppextjson message"}
+}
 `,
 			false,
 		},
@@ -513,6 +764,10 @@ func TestOptionsGenerateTemplate(t *testing.T) {
 				PodColor:       color.New(color.FgRed),
 				ContainerColor: color.New(color.FgBlue),
 			}
+			if len(tt.o.clusters) > 1 {
+				// the context is set only when multiple contexts are specified
+				log.Context = "ctx1"
+			}
 			tmpl, err := tt.o.generateTemplate()
 
 			if tt.wantError {
@@ -538,6 +793,89 @@ func TestOptionsGenerateTemplate(t *testing.T) {
 	}
 }
 
+func TestOptionsGenerateTemplateEventKind(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	streams := genericclioptions.NewTestIOStreamsDiscard()
+
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{"default", "pod1 container1 Event level=warn reason=BackOff\n"},
+		{"raw", "Event level=warn reason=BackOff\n"},
+		{"extjson", `{"pod": "pod1", "container": "container1", "kind": "Event", "message": "level=warn reason=BackOff"}` + "\n"},
+		{"ppextjson", "{\n  \"pod\": \"pod1\",\n  \"container\": \"container1\",\n  \"kind\": \"Event\",\n  \"message\": \"level=warn reason=BackOff\"\n}\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.output, func(t *testing.T) {
+			o := NewOptions(streams)
+			o.output = tt.output
+			tmpl, err := o.generateTemplate()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var buf bytes.Buffer
+			log := stern.Log{
+				Message:        "level=warn reason=BackOff",
+				Kind:           stern.EventKind,
+				PodName:        "pod1",
+				ContainerName:  "container1",
+				PodColor:       color.New(color.FgRed),
+				ContainerColor: color.New(color.FgBlue),
+			}
+			if err := tmpl.Execute(&buf, log); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("want %q, but got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestOptionsGenerateTemplateLevel(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	streams := genericclioptions.NewTestIOStreamsDiscard()
+
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{"json", `"level":"warn"`},
+		{"extjson", `{"pod": "pod1", "container": "container1", "level": "warn", "message": "level=warn"}` + "\n"},
+		{"ppextjson", "{\n  \"pod\": \"pod1\",\n  \"container\": \"container1\",\n  \"level\": \"warn\",\n  \"message\": \"level=warn\"\n}\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.output, func(t *testing.T) {
+			o := NewOptions(streams)
+			o.output = tt.output
+			tmpl, err := o.generateTemplate()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var buf bytes.Buffer
+			log := stern.Log{
+				Message:        "level=warn",
+				Level:          "warn",
+				PodName:        "pod1",
+				ContainerName:  "container1",
+				PodColor:       color.New(color.FgRed),
+				ContainerColor: color.New(color.FgBlue),
+			}
+			if err := tmpl.Execute(&buf, log); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := buf.String(); !strings.Contains(got, tt.want) {
+				t.Errorf("want %q in %q", tt.want, got)
+			}
+		})
+	}
+}
+
 func TestOptionsSternConfig(t *testing.T) {
 	streams := genericclioptions.NewTestIOStreamsDiscard()
 
@@ -551,6 +889,7 @@ func TestOptionsSternConfig(t *testing.T) {
 	defaultConfig := func() *stern.Config {
 		return &stern.Config{
 			Namespaces:            []string{},
+			NamespaceSelector:     labels.Everything(),
 			PodQuery:              re(""),
 			ExcludePodQuery:       nil,
 			Timestamps:            false,
@@ -562,16 +901,25 @@ func TestOptionsSternConfig(t *testing.T) {
 			Exclude:               nil,
 			Include:               nil,
 			Highlight:             nil,
+			UnstructuredPolicy:    stern.UnstructuredDrop,
+			MultilineTimeout:      time.Second,
+			RepeatWindow:          30 * time.Second,
 			InitContainers:        true,
 			EphemeralContainers:   true,
 			Since:                 48 * time.Hour,
 			AllNamespaces:         false,
 			LabelSelector:         labels.Everything(),
 			FieldSelector:         fields.Everything(),
+			NodeSelector:          labels.Everything(),
+			AnnotationSelector:    labels.Everything(),
+			NodeLabels:            []string{},
 			TailLines:             nil,
 			Template:              nil, // ignore when comparing
 			Follow:                true,
-			Resource:              "",
+			ReorderWindow:         2 * time.Second,
+			OutputBufferSize:      1024,
+			OutputOverflow:        stern.OverflowBlock,
+			Resources:             []string{},
 			OnlyLogLines:          false,
 			MaxLogRequests:        50,
 
@@ -597,7 +945,7 @@ func TestOptionsSternConfig(t *testing.T) {
 			func() *options {
 				o := NewOptions(streams)
 				o.namespaces = []string{"ns1", "ns2"}
-				o.podQuery = "query1"
+				o.podQueries = []string{"query1"}
 				o.excludePod = []string{"exp1", "exp2"}
 				o.timestamps = "default"
 				o.timezone = "UTC" // Location
@@ -607,6 +955,8 @@ func TestOptionsSternConfig(t *testing.T) {
 				o.exclude = []string{"ex1", "ex2"}
 				o.include = []string{"in1", "in2"}
 				o.highlight = []string{"hi1", "hi2"}
+				o.beforeContext = 2
+				o.contextLines = 3
 				o.initContainers = false
 				o.ephemeralContainers = false
 				o.since = 1 * time.Hour
@@ -616,15 +966,25 @@ func TestOptionsSternConfig(t *testing.T) {
 				o.tail = 10
 				o.noFollow = true // Follow = false
 				o.maxLogRequests = 30
-				o.resource = "res1"
+				o.resources = []string{"res1"}
+				o.onlyOwned = true
+				o.readyEndpoints = true
 				o.onlyLogLines = true
 				o.node = "node1"
+				o.namespaceRegex = []string{"team-.*"}
+				o.namespaceSelector = "env=preview"
+				o.excludeNamespace = []string{"kube-.*"}
+				o.nodeSelector = "zone=a"
+				o.nodeLabels = []string{"zone", "region", "zone"}
 
 				return o
 			}(),
 			func() *stern.Config {
 				c := defaultConfig()
 				c.Namespaces = []string{"ns1", "ns2"}
+				c.NamespaceQuery = []*regexp.Regexp{re("team-.*")}
+				c.NamespaceSelector, _ = labels.Parse("env=preview")
+				c.ExcludeNamespaceQuery = []*regexp.Regexp{re("kube-.*")}
 				c.PodQuery = re("query1")
 				c.ExcludePodQuery = []*regexp.Regexp{re("exp1"), re("exp2")}
 				c.Timestamps = true
@@ -636,15 +996,21 @@ func TestOptionsSternConfig(t *testing.T) {
 				c.Exclude = []*regexp.Regexp{re("ex1"), re("ex2")}
 				c.Include = []*regexp.Regexp{re("in1"), re("in2")}
 				c.Highlight = []*regexp.Regexp{re("hi1"), re("hi2")}
+				c.BeforeContext = 2
+				c.AfterContext = 3
 				c.InitContainers = false
 				c.EphemeralContainers = false
 				c.Since = 1 * time.Hour
 				c.AllNamespaces = true
 				c.LabelSelector = labelSelector
 				c.FieldSelector = fieldSelector
+				c.NodeSelector, _ = labels.Parse("zone=a")
+				c.NodeLabels = []string{"zone", "region"}
 				c.TailLines = ptr.To[int64](10)
 				c.Follow = false
-				c.Resource = "res1"
+				c.Resources = []string{"res1"}
+				c.OnlyOwned = true
+				c.ReadyEndpoints = true
 				c.OnlyLogLines = true
 				c.MaxLogRequests = 30
 
@@ -742,11 +1108,40 @@ func TestOptionsSternConfig(t *testing.T) {
 			}(),
 			false,
 		},
+		{
+			"multiple pod queries",
+			func() *options {
+				o := NewOptions(streams)
+				o.podQueries = []string{"query1", "query2", "query1"}
+				o.resources = []string{"deploy/res1", "sts/res2", "deploy/res1"}
+
+				return o
+			}(),
+			func() *stern.Config {
+				c := defaultConfig()
+				c.PodQuery = re("(?:query1)|(?:query2)")
+				c.Resources = []string{"deploy/res1", "sts/res2"}
+
+				return c
+			}(),
+			false,
+		},
 		{
 			"error podQuery",
 			func() *options {
 				o := NewOptions(streams)
-				o.podQuery = "[invalid"
+				o.podQueries = []string{"[invalid"}
+
+				return o
+			}(),
+			nil,
+			true,
+		},
+		{
+			"error one of podQueries",
+			func() *options {
+				o := NewOptions(streams)
+				o.podQueries = []string{"query1", "[invalid"}
 
 				return o
 			}(),
@@ -853,10 +1248,10 @@ func TestOptionsSternConfig(t *testing.T) {
 			true,
 		},
 		{
-			"error color",
+			"error namespaceRegex",
 			func() *options {
 				o := NewOptions(streams)
-				o.color = "invalid"
+				o.namespaceRegex = []string{"("}
 
 				return o
 			}(),
@@ -864,10 +1259,10 @@ func TestOptionsSternConfig(t *testing.T) {
 			true,
 		},
 		{
-			"error output",
+			"error excludeNamespace",
 			func() *options {
 				o := NewOptions(streams)
-				o.output = "invalid"
+				o.excludeNamespace = []string{"("}
 
 				return o
 			}(),
@@ -875,10 +1270,10 @@ func TestOptionsSternConfig(t *testing.T) {
 			true,
 		},
 		{
-			"error timezone",
+			"error namespaceSelector",
 			func() *options {
 				o := NewOptions(streams)
-				o.timezone = "invalid"
+				o.namespaceSelector = "-"
 
 				return o
 			}(),
@@ -886,14 +1281,513 @@ func TestOptionsSternConfig(t *testing.T) {
 			true,
 		},
 		{
-			"error timestamps",
+			"pod filters",
 			func() *options {
 				o := NewOptions(streams)
-				o.timestamps = "invalid"
+				o.annotationSelector = "team=payments"
+				o.podFilters = []string{"phase=Running", "image=~^nginx:"}
 
 				return o
 			}(),
-			nil,
+			func() *stern.Config {
+				c := defaultConfig()
+				c.AnnotationSelector, _ = labels.Parse("team=payments")
+				c.PodFilters = []*stern.PodFilter{
+					mustNewPodFilter("phase=Running"),
+					mustNewPodFilter("image=~^nginx:"),
+				}
+
+				return c
+			}(),
+			false,
+		},
+		{
+			"field filters",
+			func() *options {
+				o := NewOptions(streams)
+				o.fieldFilters = []string{"level in (error,fatal)", `path =~ "^/api"`}
+				o.unstructured = "keep"
+
+				return o
+			}(),
+			func() *stern.Config {
+				c := defaultConfig()
+				c.FieldFilters = []*stern.FieldFilter{
+					mustNewFieldFilter("level in (error,fatal)"),
+					mustNewFieldFilter(`path =~ "^/api"`),
+				}
+				c.UnstructuredPolicy = stern.UnstructuredKeep
+
+				return c
+			}(),
+			false,
+		},
+		{
+			"min level",
+			func() *options {
+				o := NewOptions(streams)
+				o.minLevel = "warning"
+
+				return o
+			}(),
+			func() *stern.Config {
+				c := defaultConfig()
+				c.MinLevel = stern.LevelWarn
+
+				return c
+			}(),
+			false,
+		},
+		{
+			"time window",
+			func() *options {
+				o := NewOptions(streams)
+				o.timezone = "Asia/Tokyo"
+				o.sinceTime = "2024-01-02T03:04:05.5Z"
+				o.until = "2024-01-02 12:30"
+
+				return o
+			}(),
+			func() *stern.Config {
+				c := defaultConfig()
+				tokyo, _ := time.LoadLocation("Asia/Tokyo")
+				c.Location = tokyo
+				c.SinceTime = ptr.To(time.Date(2024, 1, 2, 3, 4, 5, 500000000, time.UTC))
+				c.Until = ptr.To(time.Date(2024, 1, 2, 12, 30, 0, 0, tokyo))
+
+				return c
+			}(),
+			false,
+		},
+		{
+			"previous on restart",
+			func() *options {
+				o := NewOptions(streams)
+				o.previousOnRestart = true
+
+				return o
+			}(),
+			func() *stern.Config {
+				c := defaultConfig()
+				c.PreviousOnRestart = true
+
+				return c
+			}(),
+			false,
+		},
+		{
+			"lifecycle events",
+			func() *options {
+				o := NewOptions(streams)
+				o.lifecycleEvents = true
+
+				return o
+			}(),
+			func() *stern.Config {
+				c := defaultConfig()
+				c.LifecycleEvents = true
+
+				return c
+			}(),
+			false,
+		},
+		{
+			"events of owners",
+			func() *options {
+				o := NewOptions(streams)
+				o.events = "owners"
+
+				return o
+			}(),
+			func() *stern.Config {
+				c := defaultConfig()
+				c.Events = stern.EventsOwners
+
+				return c
+			}(),
+			false,
+		},
+		{
+			"summary",
+			func() *options {
+				o := NewOptions(streams)
+				o.summary = "json"
+
+				return o
+			}(),
+			func() *stern.Config {
+				c := defaultConfig()
+				c.Summary = stern.NewSummary(stern.SummaryJSON, local)
+
+				return c
+			}(),
+			false,
+		},
+		{
+			"context separator is omitted with json",
+			func() *options {
+				o := NewOptions(streams)
+				o.output = "json"
+				o.include = []string{"error"}
+				o.contextLines = 1
+
+				return o
+			}(),
+			func() *stern.Config {
+				c := defaultConfig()
+				c.Include = []*regexp.Regexp{regexp.MustCompile("error")}
+				c.BeforeContext = 1
+				c.AfterContext = 1
+				c.OmitContextSeparator = true
+
+				return c
+			}(),
+			false,
+		},
+		{
+			"summary is ignored with stdin",
+			func() *options {
+				o := NewOptions(streams)
+				o.summary = "table"
+				o.stdin = true
+
+				return o
+			}(),
+			func() *stern.Config {
+				c := defaultConfig()
+				c.Stdin = true
+
+				return c
+			}(),
+			false,
+		},
+		{
+			"ordered",
+			func() *options {
+				o := NewOptions(streams)
+				o.ordered = true
+				o.reorderWindow = 5 * time.Second
+
+				return o
+			}(),
+			func() *stern.Config {
+				c := defaultConfig()
+				c.Ordered = true
+				c.ReorderWindow = 5 * time.Second
+
+				return c
+			}(),
+			false,
+		},
+		{
+			"output buffer",
+			func() *options {
+				o := NewOptions(streams)
+				o.outputBufferSize = 10
+				o.outputOverflow = "drop"
+
+				return o
+			}(),
+			func() *stern.Config {
+				c := defaultConfig()
+				c.OutputBufferSize = 10
+				c.OutputOverflow = stern.OverflowDrop
+
+				return c
+			}(),
+			false,
+		},
+		{
+			"multiline",
+			func() *options {
+				o := NewOptions(streams)
+				o.multiline = []string{"java", "python", "java"}
+				o.multilineStart = `^\d{4}-`
+				o.multilineTimeout = 0
+
+				return o
+			}(),
+			func() *stern.Config {
+				c := defaultConfig()
+				c.Multiline, _ = stern.NewMultiline([]string{"java", "python"}, `^\d{4}-`)
+				c.MultilineTimeout = 0
+
+				return c
+			}(),
+			false,
+		},
+		{
+			"error multiline",
+			func() *options {
+				o := NewOptions(streams)
+				o.multiline = []string{"cobol"}
+
+				return o
+			}(),
+			nil,
+			true,
+		},
+		{
+			"error multilineStart",
+			func() *options {
+				o := NewOptions(streams)
+				o.multilineStart = "[invalid"
+
+				return o
+			}(),
+			nil,
+			true,
+		},
+		{
+			"collapse repeats",
+			func() *options {
+				o := NewOptions(streams)
+				o.collapseRepeats = "normalized"
+				o.collapseWindow = time.Minute
+
+				return o
+			}(),
+			func() *stern.Config {
+				c := defaultConfig()
+				c.RepeatMode = stern.RepeatNormalized
+				c.RepeatWindow = time.Minute
+
+				return c
+			}(),
+			false,
+		},
+		{
+			"scoped filters",
+			func() *options {
+				o := NewOptions(streams)
+				o.exclude = []string{"pod=api:ex1"}
+				o.excludeScoped = []string{"container=istio-proxy:GET /healthz"}
+				o.includeScoped = []string{"pod=api-.*:error"}
+				o.highlightScoped = []string{"namespace=prod:timeout"}
+
+				return o
+			}(),
+			func() *stern.Config {
+				c := defaultConfig()
+				c.Exclude = []*regexp.Regexp{re("pod=api:ex1")}
+				c.ScopedFilters = []*stern.ScopedFilter{
+					mustParseScopedFilter(stern.FilterExclude, "container=istio-proxy:GET /healthz"),
+					mustParseScopedFilter(stern.FilterInclude, "pod=api-.*:error"),
+					mustParseScopedFilter(stern.FilterHighlight, "namespace=prod:timeout"),
+				}
+
+				return c
+			}(),
+			false,
+		},
+		{
+			"error scoped filter",
+			func() *options {
+				o := NewOptions(streams)
+				o.excludeScoped = []string{"container=app:[invalid"}
+
+				return o
+			}(),
+			nil,
+			true,
+		},
+		{
+			"error redact",
+			func() *options {
+				o := NewOptions(streams)
+				o.redact = []string{"[invalid=>x"}
+
+				return o
+			}(),
+			nil,
+			true,
+		},
+		{
+			"error redactPreset",
+			func() *options {
+				o := NewOptions(streams)
+				o.redactPresets = []string{"ssn"}
+
+				return o
+			}(),
+			nil,
+			true,
+		},
+		{
+			"error collapseRepeats",
+			func() *options {
+				o := NewOptions(streams)
+				o.collapseRepeats = "fuzzy"
+
+				return o
+			}(),
+			nil,
+			true,
+		},
+		{
+			"error sinceTime",
+			func() *options {
+				o := NewOptions(streams)
+				o.sinceTime = "yesterday"
+
+				return o
+			}(),
+			nil,
+			true,
+		},
+		{
+			"error until before sinceTime",
+			func() *options {
+				o := NewOptions(streams)
+				o.sinceTime = "2024-01-02T03:04:05Z"
+				o.until = "2024-01-02T03:00:00Z"
+
+				return o
+			}(),
+			nil,
+			true,
+		},
+		{
+			"error outputOverflow",
+			func() *options {
+				o := NewOptions(streams)
+				o.outputOverflow = "wait"
+
+				return o
+			}(),
+			nil,
+			true,
+		},
+		{
+			"error events",
+			func() *options {
+				o := NewOptions(streams)
+				o.events = "nodes"
+
+				return o
+			}(),
+			nil,
+			true,
+		},
+		{
+			"error summary",
+			func() *options {
+				o := NewOptions(streams)
+				o.summary = "yaml"
+
+				return o
+			}(),
+			nil,
+			true,
+		},
+		{
+			"error minLevel",
+			func() *options {
+				o := NewOptions(streams)
+				o.minLevel = "loud"
+
+				return o
+			}(),
+			nil,
+			true,
+		},
+		{
+			"error fieldFilter",
+			func() *options {
+				o := NewOptions(streams)
+				o.fieldFilters = []string{"level in error"}
+
+				return o
+			}(),
+			nil,
+			true,
+		},
+		{
+			"error unstructured",
+			func() *options {
+				o := NewOptions(streams)
+				o.unstructured = "ignore"
+
+				return o
+			}(),
+			nil,
+			true,
+		},
+		{
+			"error podFilter",
+			func() *options {
+				o := NewOptions(streams)
+				o.podFilters = []string{"phase"}
+
+				return o
+			}(),
+			nil,
+			true,
+		},
+		{
+			"error annotationSelector",
+			func() *options {
+				o := NewOptions(streams)
+				o.annotationSelector = "-"
+
+				return o
+			}(),
+			nil,
+			true,
+		},
+		{
+			"error nodeSelector",
+			func() *options {
+				o := NewOptions(streams)
+				o.nodeSelector = "-"
+
+				return o
+			}(),
+			nil,
+			true,
+		},
+		{
+			"error color",
+			func() *options {
+				o := NewOptions(streams)
+				o.color = "invalid"
+
+				return o
+			}(),
+			nil,
+			true,
+		},
+		{
+			"error output",
+			func() *options {
+				o := NewOptions(streams)
+				o.output = "invalid"
+
+				return o
+			}(),
+			nil,
+			true,
+		},
+		{
+			"error timezone",
+			func() *options {
+				o := NewOptions(streams)
+				o.timezone = "invalid"
+
+				return o
+			}(),
+			nil,
+			true,
+		},
+		{
+			"error timestamps",
+			func() *options {
+				o := NewOptions(streams)
+				o.timestamps = "invalid"
+
+				return o
+			}(),
+			nil,
 			true,
 		},
 	}
@@ -1065,3 +1959,91 @@ func TestOptionsOverrideFlagSetDefaultFromConfigArray(t *testing.T) {
 	}
 
 }
+
+func TestOptionsOverrideFlagSetDefaultFromConfigScopedFilter(t *testing.T) {
+	tests := []struct {
+		config  string
+		want    []string
+		wantErr bool
+	}{
+		{
+			config: "testdata/config-scoped-filter.yaml",
+			want:   []string{"container=istio-proxy:GET /healthz", `namespace=prod,pod=api-.*|web\:v2:level=debug`, "pod=api:noise"},
+		},
+		{
+			config:  "testdata/config-scoped-filter-invalid.yaml",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.config, func(t *testing.T) {
+			o := NewOptions(genericclioptions.NewTestIOStreamsDiscard())
+			fs := pflag.NewFlagSet("", pflag.ExitOnError)
+			o.AddFlags(fs)
+			if err := fs.Parse([]string{"--config=" + tt.config}); err != nil {
+				t.Fatal(err)
+			}
+			err := o.overrideFlagSetDefaultFromConfig(fs)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected err, but got no err")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(tt.want, o.excludeScoped) {
+				t.Errorf("expected %v, but got %v", tt.want, o.excludeScoped)
+			}
+		})
+	}
+}
+
+func TestOptionsRedactFromConfig(t *testing.T) {
+	o := NewOptions(genericclioptions.NewTestIOStreamsDiscard())
+	fs := pflag.NewFlagSet("", pflag.ExitOnError)
+	o.AddFlags(fs)
+	if err := fs.Parse([]string{"--config=testdata/config-redact.yaml"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.overrideFlagSetDefaultFromConfig(fs); err != nil {
+		t.Fatal(err)
+	}
+	config, err := o.sternConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options := &stern.TailOptions{Redact: config.Redact}
+	msg := "login user=alice@example.com password=hunter2 key=sk_live_abc123 token=eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0.sig"
+	want := "login user=[REDACTED] password=*** key=[REDACTED] token=[REDACTED]"
+	if actual := options.RedactMessage(msg); actual != want {
+		t.Errorf("expected %q, but got %q", want, actual)
+	}
+}
+
+func mustNewPodFilter(expr string) *stern.PodFilter {
+	f, err := stern.NewPodFilter(expr)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+func mustParseScopedFilter(filterType stern.FilterType, expr string) *stern.ScopedFilter {
+	f, err := stern.ParseScopedFilter(filterType, expr)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+func mustNewFieldFilter(expr string) *stern.FieldFilter {
+	f, err := stern.NewFieldFilter(expr)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
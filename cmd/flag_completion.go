@@ -293,6 +293,14 @@ func retrieveNamesFromResource(ctx context.Context, client kubernetes.Interface,
 		for _, item := range l.Items {
 			names = append(names, item.GetName())
 		}
+	case stern.CronJobMatcher.Matches(kind):
+		l, err := client.BatchV1().CronJobs(namespace).List(ctx, opt)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range l.Items {
+			names = append(names, item.GetName())
+		}
 	default:
 		return nil, fmt.Errorf("resource type %s is not supported", kind)
 	}
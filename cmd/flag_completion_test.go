@@ -36,6 +36,7 @@ func TestRetrieveNamesFromResource(t *testing.T) {
 		&appsv1.StatefulSet{ObjectMeta: genMeta("sts2")},
 		&batchv1.Job{ObjectMeta: genMeta("job1")},
 		&batchv1.Job{ObjectMeta: genMeta("job2")},
+		&batchv1.CronJob{ObjectMeta: genMeta("cj1")},
 	}
 	client := fake.NewSimpleClientset(objs...)
 	tests := []struct {
@@ -82,6 +83,11 @@ func TestRetrieveNamesFromResource(t *testing.T) {
 			kinds:    []string{"job", "jobs"},
 			expected: []string{"job1", "job2"},
 		},
+		{
+			desc:     "cronjobs",
+			kinds:    []string{"cj", "cronjobs", "cronjob"},
+			expected: []string{"cj1"},
+		},
 		// invalid
 		{
 			desc:      "invalid",
@@ -20,13 +20,19 @@ import (
 	"text/template"
 	"time"
 
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/dynamic"
 )
 
 // Config contains the config for stern
 type Config struct {
+	Context               string
 	Namespaces            []string
+	NamespaceQuery        []*regexp.Regexp
+	ExcludeNamespaceQuery []*regexp.Regexp
+	NamespaceSelector     labels.Selector
 	PodQuery              *regexp.Regexp
 	ExcludePodQuery       []*regexp.Regexp
 	Timestamps            bool
@@ -39,21 +45,59 @@ type Config struct {
 	Exclude               []*regexp.Regexp
 	Include               []*regexp.Regexp
 	Highlight             []*regexp.Regexp
+	ScopedFilters         []*ScopedFilter
+	FieldFilters          []*FieldFilter
+	UnstructuredPolicy    UnstructuredPolicy
+	MinLevel              Level
+	Multiline             *Multiline
+	MultilineTimeout      time.Duration
+	Redact                []*RedactRule
+	RepeatMode            RepeatMode
+	RepeatWindow          time.Duration
+	BeforeContext         int
+	AfterContext          int
+	OmitContextSeparator  bool
 	InitContainers        bool
 	EphemeralContainers   bool
 	Since                 time.Duration
+	SinceTime             *time.Time
+	Until                 *time.Time
 	AllNamespaces         bool
 	LabelSelector         labels.Selector
 	FieldSelector         fields.Selector
+	AnnotationSelector    labels.Selector
+	PodFilters            []*PodFilter
+	NodeSelector          labels.Selector
+	NodeLabels            []string
 	TailLines             *int64
 	Template              *template.Template
 	Follow                bool
-	Resource              string
+	PreviousOnRestart     bool
+	LifecycleEvents       bool
+	Events                EventsMode
+	Summary               *Summary
+	Metrics               *Metrics
+	Ordered               bool
+	ReorderWindow         time.Duration
+	OutputBufferSize      int
+	OutputOverflow        OverflowPolicy
+	Resources             []string
+	OnlyOwned             bool
+	ReadyEndpoints        bool
 	OnlyLogLines          bool
 	MaxLogRequests        int
 	Stdin                 bool
 	DiffContainer         bool
 
+	// DynamicClient and RESTMapper are used to resolve the <resource>/<name>
+	// query for resources other than the built-in ones such as custom resources.
+	// The query is limited to the built-in resources when they are nil.
+	DynamicClient dynamic.Interface
+	RESTMapper    meta.RESTMapper
+
+	// Out is wrapped by an OutputWriter with OutputBufferSize and
+	// OutputOverflow unless it is an OutputWriter already. It can also be an
+	// OrderedWriter wrapping an OutputWriter, which is used when Ordered.
 	Out    io.Writer
 	ErrOut io.Writer
 }
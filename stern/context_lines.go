@@ -0,0 +1,107 @@
+package stern
+
+// ContextSeparator is printed between non-contiguous groups of lines when
+// context lines are shown
+const ContextSeparator = "--"
+
+// contextLine is a line kept to be shown as a context line
+type contextLine struct {
+	msg       string
+	timestamp string
+	level     Level // LevelUnknown unless the level is detected
+}
+
+// contextLines shows lines before and after the lines matching the include
+// and field filters like grep -B and -A. It keeps the recent lines in a ring buffer.
+type contextLines struct {
+	before int
+	after  int
+
+	buffer     []contextLine // ring buffer of the lines before a match
+	head       int           // the index of the oldest line in the buffer
+	size       int           // the number of lines in the buffer
+	afterLeft  int           // the number of lines to show after the last match
+	printedAny bool          // whether any line has been printed
+	gap        bool          // whether a line was dropped since the last printed line
+}
+
+// newContextLines returns nil if no context line is required. Context lines
+// are shown only around the lines matching the include or field filters.
+func newContextLines(options *TailOptions) *contextLines {
+	before, after := max(options.BeforeContext, 0), max(options.AfterContext, 0)
+	if (len(options.Include) == 0 && len(options.FieldFilters) == 0) || (before == 0 && after == 0) {
+		return nil
+	}
+	return &contextLines{
+		before: before,
+		after:  after,
+		buffer: make([]contextLine, before),
+	}
+}
+
+// add passes the line to print if it matches or is within the context of
+// a matching line. It calls separate before a group of lines that is not
+// contiguous to the previous group, and drop for each line that will never be
+// printed if drop is not nil.
+func (c *contextLines) add(line contextLine, matched bool, print func(contextLine), separate, drop func()) {
+	if c == nil {
+		if matched {
+			print(line)
+		} else if drop != nil {
+			drop()
+		}
+		return
+	}
+
+	if matched {
+		if c.printedAny && c.gap {
+			separate()
+		}
+		for i := 0; i < c.size; i++ {
+			print(c.buffer[(c.head+i)%c.before])
+		}
+		c.head, c.size = 0, 0
+		print(line)
+		c.afterLeft = c.after
+		c.printedAny = true
+		c.gap = false
+		return
+	}
+
+	if c.afterLeft > 0 {
+		print(line)
+		c.afterLeft--
+		return
+	}
+
+	if c.before == 0 {
+		c.gap = true
+		if drop != nil {
+			drop()
+		}
+		return
+	}
+	if c.size == c.before {
+		// the oldest line is dropped
+		c.head = (c.head + 1) % c.before
+		c.size--
+		c.gap = true
+		if drop != nil {
+			drop()
+		}
+	}
+	c.buffer[(c.head+c.size)%c.before] = line
+	c.size++
+}
+
+// discard drops the lines kept before a match when the stream ends, and calls
+// drop for each of them
+func (c *contextLines) discard(drop func()) {
+	if c == nil {
+		return
+	}
+	for range c.size {
+		drop()
+	}
+	c.head, c.size = 0, 0
+}
@@ -0,0 +1,131 @@
+package stern
+
+import (
+	"context"
+	"fmt"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// dynamicResolver resolves resources that are not supported by the built-in
+// matchers, such as custom resources, using discovery and the dynamic client.
+type dynamicResolver struct {
+	client dynamic.Interface
+	mapper meta.RESTMapper
+}
+
+func newDynamicResolver(client dynamic.Interface, mapper meta.RESTMapper) *dynamicResolver {
+	if client == nil || mapper == nil {
+		return nil
+	}
+	return &dynamicResolver{client: client, mapper: mapper}
+}
+
+// resourceFor returns the fully specified resource for the kind, which can be
+// a resource name in singular or plural, a short name, or "<resource>.<group>".
+func (r *dynamicResolver) resourceFor(kind string) (schema.GroupVersionResource, error) {
+	gvr, err := r.mapper.ResourceFor(schema.ParseGroupResource(kind).WithVersion(""))
+	if err != nil {
+		return schema.GroupVersionResource{}, fmt.Errorf("resource type %s is not supported: %w", kind, err)
+	}
+	return gvr, nil
+}
+
+// kindFor returns the group and kind of the resource e.g. "Rollout.argoproj.io"
+func (r *dynamicResolver) kindFor(kind string) (schema.GroupKind, error) {
+	gvr, err := r.resourceFor(kind)
+	if err != nil {
+		return schema.GroupKind{}, err
+	}
+	gvk, err := r.mapper.KindFor(gvr)
+	if err != nil {
+		return schema.GroupKind{}, err
+	}
+	return gvk.GroupKind(), nil
+}
+
+// retrieveSelector returns the selector of the resource. It reads the
+// following fields in order and uses the first one found:
+//
+//  1. spec.selector
+//  2. status.selector of the scale subresource
+//  3. spec.template.metadata.labels
+func (r *dynamicResolver) retrieveSelector(ctx context.Context, namespace, kind, name string) (labels.Selector, error) {
+	gvr, err := r.resourceFor(kind)
+	if err != nil {
+		return nil, err
+	}
+	ri := r.client.Resource(gvr).Namespace(namespace)
+	o, err := ri.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	if v, found, err := unstructured.NestedFieldNoCopy(o.Object, "spec", "selector"); err == nil && found {
+		selector, err := selectorFromField(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse spec.selector of %s/%s: %w", kind, name, err)
+		}
+		if !selector.Empty() {
+			return selector, nil
+		}
+	}
+
+	scale, err := ri.Get(ctx, name, metav1.GetOptions{}, "scale")
+	switch {
+	case err == nil:
+		if v, found, _ := unstructured.NestedString(scale.Object, "status", "selector"); found && v != "" {
+			selector, err := labels.Parse(v)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse status.selector of the scale of %s/%s: %w", kind, name, err)
+			}
+			return selector, nil
+		}
+	case kerrors.IsNotFound(err), kerrors.IsMethodNotSupported(err):
+		// the resource does not have the scale subresource
+	default:
+		return nil, err
+	}
+
+	if m, found, err := unstructured.NestedStringMap(o.Object, "spec", "template", "metadata", "labels"); err == nil && found && len(m) > 0 {
+		return labels.SelectorFromSet(m), nil
+	}
+
+	return nil, fmt.Errorf("resource %s/%s has no selector to select pods", kind, name)
+}
+
+// selectorFromField converts the value of a selector field into a selector.
+// The field can be a LabelSelector, a map of labels, or a label query string.
+func selectorFromField(v any) (labels.Selector, error) {
+	switch s := v.(type) {
+	case string:
+		return labels.Parse(s)
+	case map[string]any:
+		_, hasMatchLabels := s["matchLabels"]
+		_, hasMatchExpressions := s["matchExpressions"]
+		if hasMatchLabels || hasMatchExpressions {
+			var ls metav1.LabelSelector
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(s, &ls); err != nil {
+				return nil, err
+			}
+			return metav1.LabelSelectorAsSelector(&ls)
+		}
+		set := make(labels.Set, len(s))
+		for k, v := range s {
+			str, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("unexpected value type %T for %q", v, k)
+			}
+			set[k] = str
+		}
+		return labels.SelectorFromSet(set), nil
+	}
+	return nil, fmt.Errorf("unexpected selector type %T", v)
+}
@@ -0,0 +1,157 @@
+package stern
+
+import (
+	"context"
+	"testing"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestDynamicResolverRetrieveSelector(t *testing.T) {
+	gv := schema.GroupVersion{Group: "example.com", Version: "v1"}
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{gv})
+	for _, kind := range []string{"Rollout", "Revision", "Widget", "Legacy", "Scaled"} {
+		mapper.Add(gv.WithKind(kind), meta.RESTScopeNamespace)
+	}
+
+	genObj := func(kind, name string, spec map[string]any) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": gv.String(),
+			"kind":       kind,
+			"metadata": map[string]any{
+				"name":      name,
+				"namespace": "ns1",
+			},
+			"spec": spec,
+		}}
+	}
+	objs := []runtime.Object{
+		genObj("Rollout", "rollout1", map[string]any{
+			"selector": map[string]any{
+				"matchLabels": map[string]any{"app": "rollout"},
+			},
+		}),
+		genObj("Legacy", "legacy1", map[string]any{
+			"selector": map[string]any{"app": "legacy"},
+		}),
+		genObj("Revision", "rev1", map[string]any{
+			"template": map[string]any{
+				"metadata": map[string]any{
+					"labels": map[string]any{"app": "revision"},
+				},
+			},
+		}),
+		genObj("Scaled", "scaled1", map[string]any{}),
+		genObj("Widget", "widget1", map[string]any{}),
+	}
+	scheme := runtime.NewScheme()
+	client := dynamicfake.NewSimpleDynamicClient(scheme, objs...)
+	client.PrependReactor("get", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "scale" {
+			return false, nil, nil
+		}
+		if action.GetResource().Resource != "scaleds" {
+			return true, nil, kerrors.NewNotFound(action.GetResource().GroupResource(), "scale")
+		}
+		return true, &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "autoscaling/v1",
+			"kind":       "Scale",
+			"status": map[string]any{
+				"selector": "app=scaled",
+			},
+		}}, nil
+	})
+
+	r := newDynamicResolver(client, mapper)
+	tests := []struct {
+		desc      string
+		kinds     []string
+		name      string
+		expected  string
+		wantError bool
+	}{
+		{
+			desc:     "spec.selector as LabelSelector",
+			kinds:    []string{"rollout", "rollouts", "Rollout", "rollouts.example.com"},
+			name:     "rollout1",
+			expected: "app=rollout",
+		},
+		{
+			desc:     "spec.selector as map",
+			kinds:    []string{"legacy"},
+			name:     "legacy1",
+			expected: "app=legacy",
+		},
+		{
+			desc:     "status.selector of the scale subresource",
+			kinds:    []string{"scaled"},
+			name:     "scaled1",
+			expected: "app=scaled",
+		},
+		{
+			desc:     "spec.template.metadata.labels",
+			kinds:    []string{"revision", "revisions"},
+			name:     "rev1",
+			expected: "app=revision",
+		},
+		{
+			desc:      "no selector",
+			kinds:     []string{"widget"},
+			name:      "widget1",
+			wantError: true,
+		},
+		{
+			desc:      "not found",
+			kinds:     []string{"rollout"},
+			name:      "not-found",
+			wantError: true,
+		},
+		{
+			desc:      "unknown resource",
+			kinds:     []string{"unknown"},
+			name:      "dummy",
+			wantError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			for _, kind := range tt.kinds {
+				selector, err := r.retrieveSelector(context.Background(), "ns1", kind, tt.name)
+				if tt.wantError {
+					if err == nil {
+						t.Errorf("%s: expected error, but got no error", kind)
+					}
+					continue
+				}
+				if err != nil {
+					t.Errorf("%s: unexpected error: %v", kind, err)
+					continue
+				}
+				if tt.expected != selector.String() {
+					t.Errorf("%s: expected %v, but actual %v", kind, tt.expected, selector)
+				}
+			}
+		})
+	}
+}
+
+func TestDynamicResolverKindFor(t *testing.T) {
+	gv := schema.GroupVersion{Group: "example.com", Version: "v1"}
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{gv})
+	mapper.Add(gv.WithKind("Rollout"), meta.RESTScopeNamespace)
+	r := newDynamicResolver(dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()), mapper)
+
+	kind, err := r.kindFor("rollouts")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := (schema.GroupKind{Group: "example.com", Kind: "Rollout"}); kind != expected {
+		t.Errorf("expected %v, but actual %v", expected, kind)
+	}
+}
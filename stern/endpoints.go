@@ -0,0 +1,142 @@
+package stern
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+// endpointsWatcher watches the EndpointSlices of a Service and holds the pods
+// that are ready endpoints. It notifies the subscribers when they change.
+type endpointsWatcher struct {
+	notifier
+
+	service string
+	metrics *Metrics
+
+	mu sync.RWMutex
+	// slices holds the ready pods of each EndpointSlice. Both the slices
+	// and the pods are keyed by "<namespace>/<name>".
+	slices map[string]map[string]struct{}
+}
+
+func newEndpointsWatcher(service string) *endpointsWatcher {
+	return &endpointsWatcher{
+		service: service,
+		slices:  make(map[string]map[string]struct{}),
+	}
+}
+
+// list lists the EndpointSlices of the Service in the namespace, and returns
+// the resource version to watch them from
+func (w *endpointsWatcher) list(ctx context.Context, client kubernetes.Interface, namespace string) (string, error) {
+	list, err := client.DiscoveryV1().EndpointSlices(namespace).List(ctx, metav1.ListOptions{LabelSelector: w.selector()})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to list endpointslices")
+	}
+	for idx := range list.Items {
+		w.set(&list.Items[idx])
+	}
+	return list.ResourceVersion, nil
+}
+
+// start lists the EndpointSlices of the Service in the namespace and starts
+// watching them
+func (w *endpointsWatcher) start(ctx context.Context, client kubernetes.Interface, namespace string) error {
+	resourceVersion, err := w.list(ctx, client, namespace)
+	if err != nil {
+		return err
+	}
+
+	i := client.DiscoveryV1().EndpointSlices(namespace)
+	selector := w.selector()
+	_, err = retryWatch(ctx, "endpointslices", resourceVersion, w.metrics, func(options metav1.ListOptions) (watch.Interface, error) {
+		options.LabelSelector = selector
+		return i.Watch(ctx, options)
+	}, func(e watch.Event) {
+		slice, ok := e.Object.(*discoveryv1.EndpointSlice)
+		if !ok {
+			return
+		}
+		switch e.Type {
+		case watch.Added, watch.Modified:
+			if w.set(slice) {
+				w.notify()
+			}
+		case watch.Deleted:
+			if w.delete(slice) {
+				w.notify()
+			}
+		}
+	})
+	return err
+}
+
+func (w *endpointsWatcher) selector() string {
+	return labels.SelectorFromSet(labels.Set{discoveryv1.LabelServiceName: w.service}).String()
+}
+
+// set updates the ready pods of the EndpointSlice and returns if they changed
+func (w *endpointsWatcher) set(slice *discoveryv1.EndpointSlice) bool {
+	pods := make(map[string]struct{})
+	for _, ep := range slice.Endpoints {
+		// nil should be interpreted as ready
+		if ep.Conditions.Ready != nil && !*ep.Conditions.Ready {
+			continue
+		}
+		if ep.TargetRef == nil || ep.TargetRef.Kind != "Pod" {
+			continue
+		}
+		namespace := ep.TargetRef.Namespace
+		if namespace == "" {
+			namespace = slice.Namespace
+		}
+		pods[namespace+"/"+ep.TargetRef.Name] = struct{}{}
+	}
+
+	key := slice.Namespace + "/" + slice.Name
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	last, found := w.slices[key]
+	w.slices[key] = pods
+	if !found || len(last) != len(pods) {
+		return true
+	}
+	for pod := range pods {
+		if _, ok := last[pod]; !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// delete removes the EndpointSlice and returns if the ready pods changed
+func (w *endpointsWatcher) delete(slice *discoveryv1.EndpointSlice) bool {
+	key := slice.Namespace + "/" + slice.Name
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	last, found := w.slices[key]
+	delete(w.slices, key)
+	return found && len(last) > 0
+}
+
+// Has returns if the pod is a ready endpoint of the Service
+func (w *endpointsWatcher) Has(pod *corev1.Pod) bool {
+	key := pod.Namespace + "/" + pod.Name
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	for _, pods := range w.slices {
+		if _, ok := pods[key]; ok {
+			return true
+		}
+	}
+	return false
+}
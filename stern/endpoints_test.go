@@ -0,0 +1,121 @@
+package stern
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/utils/ptr"
+)
+
+func TestEndpointsWatcher(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	type endpoint struct {
+		pod   string
+		ready *bool
+	}
+	genSlice := func(name string, endpoints ...endpoint) *discoveryv1.EndpointSlice {
+		slice := &discoveryv1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:       "ns1",
+				Name:            name,
+				ResourceVersion: "1",
+				Labels:          map[string]string{discoveryv1.LabelServiceName: "svc1"},
+			},
+		}
+		for _, ep := range endpoints {
+			slice.Endpoints = append(slice.Endpoints, discoveryv1.Endpoint{
+				Conditions: discoveryv1.EndpointConditions{Ready: ep.ready},
+				TargetRef:  &corev1.ObjectReference{Kind: "Pod", Name: ep.pod},
+			})
+		}
+		return slice
+	}
+	genPod := func(name string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: name}}
+	}
+
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("list", "endpointslices", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &discoveryv1.EndpointSliceList{
+			ListMeta: metav1.ListMeta{ResourceVersion: "1"},
+			Items: []discoveryv1.EndpointSlice{
+				*genSlice("svc1-a",
+					endpoint{pod: "pod1", ready: ptr.To(true)},
+					endpoint{pod: "pod2", ready: ptr.To(false)},
+					endpoint{pod: "pod3", ready: nil},
+				),
+			},
+		}, nil
+	})
+	fw := watch.NewFake()
+	client.PrependWatchReactor("endpointslices", k8stesting.DefaultWatchReactor(fw, nil))
+
+	w := newEndpointsWatcher("svc1")
+	notified := w.subscribe()
+	if err := w.start(ctx, client, "ns1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	check := func(expected map[string]bool) {
+		t.Helper()
+		for pod, want := range expected {
+			if actual := w.Has(genPod(pod)); want != actual {
+				t.Errorf("%s: expected %v, but actual %v", pod, want, actual)
+			}
+		}
+	}
+	waitNotified := func() {
+		t.Helper()
+		select {
+		case <-notified:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out")
+		}
+	}
+
+	check(map[string]bool{"pod1": true, "pod2": false, "pod3": true, "pod4": false})
+
+	// pod2 becomes ready
+	fw.Modify(genSlice("svc1-a",
+		endpoint{pod: "pod1", ready: ptr.To(true)},
+		endpoint{pod: "pod2", ready: ptr.To(true)},
+		endpoint{pod: "pod3", ready: nil},
+	))
+	waitNotified()
+	check(map[string]bool{"pod1": true, "pod2": true, "pod3": true})
+
+	// another slice is added
+	fw.Add(genSlice("svc1-b", endpoint{pod: "pod4", ready: ptr.To(true)}))
+	waitNotified()
+	check(map[string]bool{"pod4": true})
+
+	// pod1 becomes unready
+	fw.Modify(genSlice("svc1-a",
+		endpoint{pod: "pod1", ready: ptr.To(false)},
+		endpoint{pod: "pod2", ready: ptr.To(true)},
+		endpoint{pod: "pod3", ready: nil},
+	))
+	waitNotified()
+	check(map[string]bool{"pod1": false, "pod2": true})
+
+	// the same endpoints are not notified
+	fw.Modify(genSlice("svc1-b", endpoint{pod: "pod4", ready: ptr.To(true)}))
+	fw.Delete(genSlice("svc1-b", endpoint{pod: "pod4", ready: ptr.To(true)}))
+	waitNotified()
+	check(map[string]bool{"pod4": false})
+	select {
+	case <-notified:
+		t.Errorf("expected no more notifications")
+	default:
+	}
+}
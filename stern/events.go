@@ -0,0 +1,408 @@
+package stern
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+// EventKind is the kind of the records of Kubernetes events, while it is
+// empty for log lines
+const EventKind = "Event"
+
+// EventsMode decides which Kubernetes events are printed with the logs
+type EventsMode string
+
+const (
+	// EventsOff prints no events
+	EventsOff EventsMode = ""
+	// EventsPods prints the events of the pods
+	EventsPods EventsMode = "pods"
+	// EventsOwners prints the events of the pods and their owners such as
+	// ReplicaSets and Jobs
+	EventsOwners EventsMode = "owners"
+)
+
+// NewEventsMode returns the mode of the name
+func NewEventsMode(name string) (EventsMode, error) {
+	switch m := EventsMode(name); m {
+	case EventsOff, EventsPods, EventsOwners:
+		return m, nil
+	}
+	return EventsOff, fmt.Errorf("events should be one of '%s', '%s'", EventsPods, EventsOwners)
+}
+
+// eventWatcher prints the Kubernetes events of the pods matching the filter
+// through the tails, so that the events are filtered and formatted like log
+// lines. The pods are registered when they are visited by the filter, which
+// includes pending pods without containers to tail.
+type eventWatcher struct {
+	mode    EventsMode
+	metrics *Metrics
+	since   time.Time  // events older than this are ignored
+	until   *time.Time // events newer than this are ignored if not nil
+	newTail func(t *Target) *Tail
+
+	mu     sync.Mutex
+	pods   map[types.UID]*corev1.Pod
+	tails  map[string]*Tail        // the tails printing the events of each object
+	seen   map[types.UID]seenEvent // the printed events
+	scopes []*eventScope           // the namespaces being watched
+}
+
+// seenEvent is the resource version of a printed event and the key of the
+// tail that printed it
+type seenEvent struct {
+	resourceVersion string
+	key             string
+}
+
+// eventScope is a namespace being watched, which is empty for all namespaces
+type eventScope struct {
+	ctx       context.Context
+	client    kubernetes.Interface
+	namespace string
+	kinds     sets.Set[string] // the kinds of the objects being watched
+}
+
+func newEventWatcher(mode EventsMode, since time.Time, until *time.Time, newTail func(t *Target) *Tail) *eventWatcher {
+	return &eventWatcher{
+		mode:    mode,
+		since:   since,
+		until:   until,
+		newTail: newTail,
+		pods:    make(map[types.UID]*corev1.Pod),
+		tails:   make(map[string]*Tail),
+		seen:    make(map[types.UID]seenEvent),
+	}
+}
+
+// add registers the pod to print its events. In the owners mode, the events
+// of the kinds of its owners are watched if they are not yet.
+func (w *eventWatcher) add(pod *corev1.Pod) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	w.pods[pod.UID] = pod
+	type start struct {
+		scope *eventScope
+		kind  string
+	}
+	var starts []start
+	for _, scope := range w.scopes {
+		if scope.namespace != "" && scope.namespace != pod.Namespace {
+			continue
+		}
+		for _, kind := range w.claimLocked(scope, w.ownerKinds(pod)) {
+			starts = append(starts, start{scope, kind})
+		}
+	}
+	w.mu.Unlock()
+
+	for _, s := range starts {
+		if err := w.watchKind(s.scope, s.kind); err != nil {
+			klog.V(7).InfoS("Failed to watch events", "namespace", s.scope.namespace, "kind", s.kind, "err", err)
+		}
+	}
+}
+
+// remove unregisters the pod, and forgets the events of the pod and its
+// owners that no other pod has
+func (w *eventWatcher) remove(podUID string) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	pod, ok := w.pods[types.UID(podUID)]
+	if !ok {
+		return
+	}
+	delete(w.pods, pod.UID)
+	prefixes := []string{eventTailKey(pod.Namespace, "Pod", pod.Name, "")}
+	for _, owner := range pod.OwnerReferences {
+		if !w.ownedLocked(pod.Namespace, owner) {
+			prefixes = append(prefixes, eventTailKey(pod.Namespace, owner.Kind, owner.Name, ""))
+		}
+	}
+	removed := func(key string) bool {
+		return slices.ContainsFunc(prefixes, func(prefix string) bool { return strings.HasPrefix(key, prefix) })
+	}
+	for key, t := range w.tails {
+		if removed(key) {
+			t.repeats.flush()
+			t.ordered.close()
+			delete(w.tails, key)
+		}
+	}
+	for uid, e := range w.seen {
+		if removed(e.key) {
+			delete(w.seen, uid)
+		}
+	}
+}
+
+// ownedLocked returns true if a registered pod in the namespace is owned by
+// the owner
+func (w *eventWatcher) ownedLocked(namespace string, owner metav1.OwnerReference) bool {
+	for _, pod := range w.pods {
+		if pod.Namespace != namespace {
+			continue
+		}
+		for _, o := range pod.OwnerReferences {
+			if o.Kind == owner.Kind && o.Name == owner.Name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// end prints the repeated events suppressed so far and ends the tails, as
+// no more events are printed after they are listed
+func (w *eventWatcher) end() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, t := range w.tails {
+		t.repeats.flush()
+		t.ordered.close()
+	}
+}
+
+// watch prints the events in the namespace until the context is done. The
+// events are watched for each kind of the objects because the field selector
+// cannot select multiple kinds.
+func (w *eventWatcher) watch(ctx context.Context, client kubernetes.Interface, namespace string) error {
+	scope := &eventScope{ctx: ctx, client: client, namespace: namespace, kinds: sets.New[string]()}
+	w.mu.Lock()
+	w.scopes = append(w.scopes, scope)
+	kinds := []string{"Pod"}
+	for _, pod := range w.pods {
+		if namespace == "" || pod.Namespace == namespace {
+			kinds = append(kinds, w.ownerKinds(pod)...)
+		}
+	}
+	kinds = w.claimLocked(scope, kinds)
+	w.mu.Unlock()
+
+	for _, kind := range kinds {
+		if err := w.watchKind(scope, kind); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// claimLocked marks the kinds as watched in the scope and returns the ones
+// that were not
+func (w *eventWatcher) claimLocked(scope *eventScope, kinds []string) []string {
+	var claimed []string
+	for _, kind := range kinds {
+		if !scope.kinds.Has(kind) {
+			scope.kinds.Insert(kind)
+			claimed = append(claimed, kind)
+		}
+	}
+	return claimed
+}
+
+// watchKind prints the events of the objects of the kind in the scope
+func (w *eventWatcher) watchKind(scope *eventScope, kind string) error {
+	ctx := scope.ctx
+	fieldSelector := kindSelector(kind)
+	// Events are listed again when the watch is restarted, and the printed
+	// ones are skipped by their resource versions
+	_, err := retryWatch(ctx, "events", "1", w.metrics, func(options metav1.ListOptions) (watch.Interface, error) {
+		return scope.client.CoreV1().Events(scope.namespace).Watch(ctx, metav1.ListOptions{FieldSelector: fieldSelector})
+	}, func(e watch.Event) {
+		event, ok := e.Object.(*corev1.Event)
+		if !ok {
+			return
+		}
+		switch e.Type {
+		case watch.Added, watch.Modified:
+			w.handle(event)
+		case watch.Deleted:
+			w.mu.Lock()
+			delete(w.seen, event.UID)
+			w.mu.Unlock()
+		}
+	})
+	return err
+}
+
+// list prints the events of the pods in the namespace and their owners
+func (w *eventWatcher) list(ctx context.Context, client kubernetes.Interface, namespace string) error {
+	kinds := sets.New("Pod")
+	w.mu.Lock()
+	for _, pod := range w.pods {
+		if namespace == "" || pod.Namespace == namespace {
+			kinds.Insert(w.ownerKinds(pod)...)
+		}
+	}
+	w.mu.Unlock()
+
+	for _, kind := range sets.List(kinds) {
+		events, err := client.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{FieldSelector: kindSelector(kind)})
+		if err != nil {
+			return errors.Wrap(err, "failed to list events")
+		}
+		for i := range events.Items {
+			w.handle(&events.Items[i])
+		}
+	}
+	return nil
+}
+
+// ownerKinds returns the kinds of the owners of the pod whose events are
+// printed in the owners mode
+func (w *eventWatcher) ownerKinds(pod *corev1.Pod) []string {
+	if w.mode != EventsOwners {
+		return nil
+	}
+	var kinds []string
+	for _, owner := range pod.OwnerReferences {
+		kinds = append(kinds, owner.Kind)
+	}
+	return kinds
+}
+
+func kindSelector(kind string) string {
+	return fields.OneTermEqualSelector("involvedObject.kind", kind).String()
+}
+
+// handle prints the event if it is of a registered pod or its owner
+func (w *eventWatcher) handle(event *corev1.Event) {
+	ts := eventTime(event)
+	if ts.Before(w.since) || (w.until != nil && ts.After(*w.until)) {
+		return
+	}
+
+	w.mu.Lock()
+	if w.seen[event.UID].resourceVersion == event.ResourceVersion {
+		w.mu.Unlock()
+		return
+	}
+	target := w.findTarget(event.InvolvedObject)
+	if target == nil {
+		w.mu.Unlock()
+		return
+	}
+	ref := event.InvolvedObject
+	key := eventTailKey(ref.Namespace, ref.Kind, ref.Name, target.Container)
+	w.seen[event.UID] = seenEvent{resourceVersion: event.ResourceVersion, key: key}
+	tail, ok := w.tails[key]
+	if !ok {
+		tail = w.newTail(target)
+		tail.kind = EventKind
+		w.tails[key] = tail
+	}
+	w.mu.Unlock()
+
+	klog.V(7).InfoS("Print event", "object", key, "reason", event.Reason)
+	tail.consumeEntry(ts.UTC().Format(time.RFC3339Nano), eventMessage(event))
+}
+
+// findTarget returns the target to print the event of the object. Events of
+// an owner are printed as if they were the logs of a pod named after the
+// owner like "replicaset/api-7d4b9c".
+func (w *eventWatcher) findTarget(ref corev1.ObjectReference) *Target {
+	if ref.Kind == "Pod" {
+		for _, pod := range w.pods {
+			if pod.Namespace == ref.Namespace && pod.Name == ref.Name && (ref.UID == "" || ref.UID == pod.UID) {
+				return &Target{Pod: pod, Container: eventContainer(ref.FieldPath)}
+			}
+		}
+		return nil
+	}
+	if w.mode != EventsOwners {
+		return nil
+	}
+	for _, pod := range w.pods {
+		if pod.Namespace != ref.Namespace {
+			continue
+		}
+		for _, owner := range pod.OwnerReferences {
+			if owner.Kind == ref.Kind && owner.Name == ref.Name && (ref.UID == "" || ref.UID == owner.UID) {
+				return &Target{Pod: &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: ref.Namespace,
+						Name:      strings.ToLower(ref.Kind) + "/" + ref.Name,
+					},
+				}}
+			}
+		}
+	}
+	return nil
+}
+
+func eventTailKey(namespace, kind, name, container string) string {
+	return fmt.Sprintf("%s/%s/%s/%s", namespace, kind, name, container)
+}
+
+// eventContainer returns the container name of the field path like
+// "spec.containers{nginx}"
+func eventContainer(fieldPath string) string {
+	i := strings.IndexByte(fieldPath, '{')
+	if i < 0 || !strings.HasSuffix(fieldPath, "}") {
+		return ""
+	}
+	return fieldPath[i+1 : len(fieldPath)-1]
+}
+
+// eventTime returns the time when the event was observed last
+func eventTime(event *corev1.Event) time.Time {
+	switch {
+	case event.Series != nil && !event.Series.LastObservedTime.IsZero():
+		return event.Series.LastObservedTime.Time
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	case !event.FirstTimestamp.IsZero():
+		return event.FirstTimestamp.Time
+	}
+	return event.CreationTimestamp.Time
+}
+
+// eventMessage returns the event in logfmt, so that the level is detected
+// and the fields can be filtered by --field-filter
+func eventMessage(event *corev1.Event) string {
+	level := LevelInfo
+	if event.Type == corev1.EventTypeWarning {
+		level = LevelWarn
+	}
+	ref := event.InvolvedObject
+	parts := []string{
+		"level=" + level.String(),
+		"reason=" + event.Reason,
+		"object=" + strings.ToLower(ref.Kind) + "/" + ref.Name,
+	}
+	count := event.Count
+	if event.Series != nil {
+		count = event.Series.Count
+	}
+	if count > 1 {
+		parts = append(parts, "count="+strconv.Itoa(int(count)))
+	}
+	parts = append(parts, "message="+strconv.Quote(strings.TrimSpace(event.Message)))
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,281 @@
+package stern
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"regexp"
+	"slices"
+	"testing"
+	"text/template"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestEventMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		event    corev1.Event
+		expected string
+	}{
+		{
+			name: "warning",
+			event: corev1.Event{
+				Type:           corev1.EventTypeWarning,
+				Reason:         "BackOff",
+				Message:        "Back-off restarting failed container",
+				InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "pod1"},
+				Count:          5,
+			},
+			expected: `level=warn reason=BackOff object=pod/pod1 count=5 message="Back-off restarting failed container"`,
+		},
+		{
+			name: "normal with series",
+			event: corev1.Event{
+				Type:           corev1.EventTypeNormal,
+				Reason:         "ScalingReplicaSet",
+				Message:        "Scaled up replica set api-7d4b9c to 3\n",
+				InvolvedObject: corev1.ObjectReference{Kind: "Deployment", Name: "api"},
+				Series:         &corev1.EventSeries{Count: 2},
+			},
+			expected: `level=info reason=ScalingReplicaSet object=deployment/api count=2 message="Scaled up replica set api-7d4b9c to 3"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if actual := eventMessage(&tt.event); actual != tt.expected {
+				t.Errorf("expected %q, but actual %q", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestEventContainer(t *testing.T) {
+	tests := []struct {
+		fieldPath string
+		expected  string
+	}{
+		{"spec.containers{nginx}", "nginx"},
+		{"spec.initContainers{init}", "init"},
+		{"", ""},
+		{"spec.containers", ""},
+	}
+
+	for _, tt := range tests {
+		if actual := eventContainer(tt.fieldPath); actual != tt.expected {
+			t.Errorf("%q: expected %q, but actual %q", tt.fieldPath, tt.expected, actual)
+		}
+	}
+}
+
+func TestEventWatcherList(t *testing.T) {
+	since := time.Date(2023, 2, 13, 21, 0, 0, 0, time.UTC)
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "ns1",
+			Name:            "pod1",
+			UID:             "uid1",
+			OwnerReferences: []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "rs1", UID: "rs-uid1"}},
+		},
+	}
+	newEvent := func(name string, ref corev1.ObjectReference, reason string, ts time.Time) corev1.Event {
+		return corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Namespace: "ns1", Name: name, UID: types.UID("uid-" + name), ResourceVersion: "1"},
+			InvolvedObject: ref,
+			Type:           corev1.EventTypeWarning,
+			Reason:         reason,
+			LastTimestamp:  metav1.NewTime(ts),
+		}
+	}
+	events := &corev1.EventList{Items: []corev1.Event{
+		newEvent("e1", corev1.ObjectReference{Namespace: "ns1", Kind: "Pod", Name: "pod1", FieldPath: "spec.containers{c1}"}, "BackOff", since.Add(time.Minute)),
+		newEvent("e2", corev1.ObjectReference{Namespace: "ns1", Kind: "Pod", Name: "pod1"}, "Old", since.Add(-time.Minute)),
+		newEvent("e3", corev1.ObjectReference{Namespace: "ns1", Kind: "Pod", Name: "pod2"}, "OtherPod", since.Add(time.Minute)),
+		newEvent("e4", corev1.ObjectReference{Namespace: "ns1", Kind: "ReplicaSet", Name: "rs1", UID: "rs-uid1"}, "FailedCreate", since.Add(2*time.Minute)),
+	}}
+
+	tests := []struct {
+		mode              EventsMode
+		expected          string
+		expectedSelectors []string
+	}{
+		{
+			mode:              EventsPods,
+			expected:          `Event pod1 c1 02-13 21:01:00 level=warn reason=BackOff object=pod/pod1 message=""` + "\n",
+			expectedSelectors: []string{"involvedObject.kind=Pod"},
+		},
+		{
+			mode: EventsOwners,
+			expected: `Event pod1 c1 02-13 21:01:00 level=warn reason=BackOff object=pod/pod1 message=""` + "\n" +
+				`Event replicaset/rs1  02-13 21:02:00 level=warn reason=FailedCreate object=replicaset/rs1 message=""` + "\n",
+			expectedSelectors: []string{"involvedObject.kind=Pod", "involvedObject.kind=ReplicaSet"},
+		},
+	}
+
+	tmpl := template.Must(template.New("").Parse(`{{.Kind}} {{.PodName}} {{.ContainerName}} {{.Timestamp}} {{.Message}}` + "\n"))
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			out := new(bytes.Buffer)
+			clientset := fake.NewSimpleClientset(events)
+			// the fake clientset ignores field selectors
+			var selectors []string
+			clientset.PrependReactor("list", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				selector := action.(k8stesting.ListAction).GetListRestrictions().Fields.String()
+				selectors = append(selectors, selector)
+				list := &corev1.EventList{}
+				for _, e := range events.Items {
+					if selector == kindSelector(e.InvolvedObject.Kind) {
+						list.Items = append(list.Items, e)
+					}
+				}
+				return true, list, nil
+			})
+			w := newEventWatcher(tt.mode, since, nil, func(target *Target) *Tail {
+				return NewTail(clientset.CoreV1(), target.Pod, target.Container, tmpl, out, io.Discard,
+					&TailOptions{Timestamps: true, TimestampFormat: TimestampFormatShort, Location: time.UTC}, false)
+			})
+			w.add(pod)
+
+			if err := w.list(context.Background(), clientset, "ns1"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			// the printed events are not printed again
+			if err := w.list(context.Background(), clientset, "ns1"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if out.String() != tt.expected {
+				t.Errorf("expected %q, but actual %q", tt.expected, out)
+			}
+			if !slices.Equal(tt.expectedSelectors, selectors[:len(selectors)/2]) {
+				t.Errorf("expected selectors %v, but actual %v", tt.expectedSelectors, selectors)
+			}
+
+			w.remove("uid1")
+			if len(w.seen) != 0 || len(w.tails) != 0 {
+				t.Errorf("expected the events of the removed pod to be forgotten, but got %v and %v", w.seen, w.tails)
+			}
+			out.Reset()
+			w.handle(&corev1.Event{
+				ObjectMeta:     metav1.ObjectMeta{UID: "uid-e5", ResourceVersion: "1"},
+				InvolvedObject: corev1.ObjectReference{Namespace: "ns1", Kind: "Pod", Name: "pod1"},
+				LastTimestamp:  metav1.NewTime(since.Add(3 * time.Minute)),
+			})
+			if out.Len() != 0 {
+				t.Errorf("expected no events of the removed pod, but got %q", out)
+			}
+		})
+	}
+}
+
+func TestEventWatcherWatchOwnerKinds(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	selectors := make(chan string, 10)
+	clientset.PrependWatchReactor("events", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		selectors <- action.(k8stesting.WatchAction).GetWatchRestrictions().Fields.String()
+		return true, watch.NewFake(), nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w := newEventWatcher(EventsOwners, time.Now(), nil, nil)
+	if err := w.watch(ctx, clientset, "ns1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	newPod := func(name, ownerKind string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "ns1",
+			Name:            name,
+			UID:             types.UID(name),
+			OwnerReferences: []metav1.OwnerReference{{Kind: ownerKind, Name: "owner"}},
+		}}
+	}
+	w.add(newPod("pod1", "ReplicaSet"))
+	w.add(newPod("pod2", "ReplicaSet"))
+	w.add(newPod("pod3", "Job"))
+
+	var actual []string
+	for range 3 {
+		select {
+		case s := <-selectors:
+			actual = append(actual, s)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected 3 watches, but got %v", actual)
+		}
+	}
+	slices.Sort(actual)
+	expected := []string{"involvedObject.kind=Job", "involvedObject.kind=Pod", "involvedObject.kind=ReplicaSet"}
+	if !slices.Equal(expected, actual) {
+		t.Errorf("expected %v, but actual %v", expected, actual)
+	}
+	select {
+	case s := <-selectors:
+		t.Errorf("expected each kind to be watched once, but got %q", s)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestRunEventsOrdered(t *testing.T) {
+	base := time.Date(2023, 2, 13, 21, 20, 30, 0, time.UTC)
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "pod1", UID: "uid1"},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+			Name:        "c1",
+			ContainerID: "containerd://c1",
+			State:       corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+		}}},
+	}
+	event := &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Namespace: "ns1", Name: "e1", UID: "uid-e1", ResourceVersion: "1"},
+		InvolvedObject: corev1.ObjectReference{Namespace: "ns1", Kind: "Pod", Name: "pod1", FieldPath: "spec.containers{c1}"},
+		Type:           corev1.EventTypeWarning,
+		Reason:         "Unhealthy",
+		LastTimestamp:  metav1.NewTime(base.Add(2 * time.Second)),
+	}
+	clientset := fake.NewSimpleClientset(pod, event)
+	clientset.PrependReactor("get", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "log" {
+			return false, nil, nil
+		}
+		return true, &runtime.Unknown{Raw: []byte(base.Add(time.Second).Format(time.RFC3339Nano) + " before\n" +
+			base.Add(3*time.Second).Format(time.RFC3339Nano) + " after\n")}, nil
+	})
+
+	out := new(bytes.Buffer)
+	since := base.Add(-time.Hour)
+	err := Run(context.TODO(), clientset, &Config{
+		Namespaces:      []string{"ns1"},
+		PodQuery:        regexp.MustCompile(""),
+		ContainerQuery:  regexp.MustCompile(""),
+		ContainerStates: []ContainerState{RUNNING},
+		LabelSelector:   labels.Everything(),
+		FieldSelector:   fields.Everything(),
+		SinceTime:       &since,
+		Template:        template.Must(template.New("").Parse(`{{.Kind}} {{.Message}}` + "\n")),
+		MaxLogRequests:  1,
+		Ordered:         true,
+		Events:          EventsPods,
+		Out:             out,
+		ErrOut:          io.Discard,
+	})
+	if err != nil {
+		t.Fatalf("unexpected err %v", err)
+	}
+
+	// the event is merged with the logs by its timestamp
+	expected := " before\n" +
+		`Event level=warn reason=Unhealthy object=pod/pod1 message=""` + "\n" +
+		" after\n"
+	if out.String() != expected {
+		t.Errorf("expected `%s`, but actual `%s`", expected, out)
+	}
+}
@@ -0,0 +1,356 @@
+package stern
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// UnstructuredPolicy decides how the field filters treat log lines that are
+// neither JSON nor logfmt
+type UnstructuredPolicy string
+
+const (
+	// UnstructuredDrop drops unstructured lines
+	UnstructuredDrop UnstructuredPolicy = "drop"
+	// UnstructuredKeep shows unstructured lines as if they matched
+	UnstructuredKeep UnstructuredPolicy = "keep"
+)
+
+// NewUnstructuredPolicy returns the policy of the name
+func NewUnstructuredPolicy(name string) (UnstructuredPolicy, error) {
+	switch p := UnstructuredPolicy(name); p {
+	case UnstructuredDrop, UnstructuredKeep:
+		return p, nil
+	}
+	return "", fmt.Errorf("unstructured policy should be one of '%s', '%s'", UnstructuredDrop, UnstructuredKeep)
+}
+
+// FieldFilter is a predicate on the fields of structured log lines such as
+// `level in (error,fatal) && status >= 500 && path =~ "^/api"`
+type FieldFilter struct {
+	expr string
+	root fieldExpr
+}
+
+// NewFieldFilter parses the expression of a field filter.
+//
+// A predicate is "<field> <operator> <value>" where the operator is one of
+// =, ==, !=, =~, !~, <, <=, > and >=, "<field> in (<value>,...)",
+// "<field> not in (<value>,...)" or just "<field>" that matches if the field
+// exists. Nested fields are referred by dots like "http.status". Values may be
+// quoted by double or single quotes. Predicates are combined by &&, || and !
+// with parentheses.
+func NewFieldFilter(expr string) (*FieldFilter, error) {
+	tokens, err := lexFieldFilter(expr)
+	if err != nil {
+		return nil, fmt.Errorf("field filter %q: %w", expr, err)
+	}
+	p := &fieldFilterParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err == nil && p.peek().kind != tokenEOF {
+		err = fmt.Errorf("unexpected %s", p.peek())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("field filter %q: %w", expr, err)
+	}
+	return &FieldFilter{expr: expr, root: root}, nil
+}
+
+// String returns the expression of the filter
+func (f *FieldFilter) String() string {
+	return f.expr
+}
+
+// Matches returns if the fields of the structured log line match the filter.
+// Predicates on missing fields are false except for the negative operators
+// !=, !~ and not in.
+func (f *FieldFilter) Matches(fields map[string]any) bool {
+	return f.root.eval(fields)
+}
+
+type fieldExpr interface {
+	eval(fields map[string]any) bool
+}
+
+type andExpr struct{ left, right fieldExpr }
+
+func (e andExpr) eval(fields map[string]any) bool {
+	return e.left.eval(fields) && e.right.eval(fields)
+}
+
+type orExpr struct{ left, right fieldExpr }
+
+func (e orExpr) eval(fields map[string]any) bool {
+	return e.left.eval(fields) || e.right.eval(fields)
+}
+
+type notExpr struct{ expr fieldExpr }
+
+func (e notExpr) eval(fields map[string]any) bool {
+	return !e.expr.eval(fields)
+}
+
+type existsExpr struct{ field string }
+
+func (e existsExpr) eval(fields map[string]any) bool {
+	_, ok := lookupField(fields, e.field)
+	return ok
+}
+
+type predicateExpr struct {
+	valuePredicate
+	field string
+}
+
+func (e predicateExpr) eval(fields map[string]any) bool {
+	values, ok := lookupField(fields, e.field)
+	if !ok {
+		return e.negative()
+	}
+	return e.matches(values)
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenString
+	tokenOperator
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+type fieldFilterToken struct {
+	kind  tokenKind
+	value string
+}
+
+func (t fieldFilterToken) String() string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.value)
+}
+
+// fieldFilterSpecialChars are the characters that cannot appear in unquoted
+// fields and values
+const fieldFilterSpecialChars = "()!,=<>~&|\"' \t"
+
+func lexFieldFilter(expr string) ([]fieldFilterToken, error) {
+	var tokens []fieldFilterToken
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		two := ""
+		if i+1 < len(expr) {
+			two = expr[i : i+2]
+		}
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(':
+			tokens = append(tokens, fieldFilterToken{tokenLParen, "("})
+			i++
+		case c == ')':
+			tokens = append(tokens, fieldFilterToken{tokenRParen, ")"})
+			i++
+		case c == ',':
+			tokens = append(tokens, fieldFilterToken{tokenComma, ","})
+			i++
+		case two == "&&":
+			tokens = append(tokens, fieldFilterToken{tokenAnd, two})
+			i += 2
+		case two == "||":
+			tokens = append(tokens, fieldFilterToken{tokenOr, two})
+			i += 2
+		case two == "==" || two == "!=" || two == "=~" || two == "!~" || two == "<=" || two == ">=":
+			tokens = append(tokens, fieldFilterToken{tokenOperator, two})
+			i += 2
+		case c == '=' || c == '<' || c == '>':
+			tokens = append(tokens, fieldFilterToken{tokenOperator, string(c)})
+			i++
+		case c == '!':
+			tokens = append(tokens, fieldFilterToken{tokenNot, "!"})
+			i++
+		case c == '"':
+			end := quotedStringEnd(expr[i:])
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			s, err := strconv.Unquote(expr[i : i+end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at %d: %w", i, err)
+			}
+			tokens = append(tokens, fieldFilterToken{tokenString, s})
+			i += end
+		case c == '\'':
+			end := strings.IndexByte(expr[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			tokens = append(tokens, fieldFilterToken{tokenString, expr[i+1 : i+1+end]})
+			i += end + 2
+		default:
+			end := strings.IndexAny(expr[i:], fieldFilterSpecialChars)
+			if end == 0 {
+				return nil, fmt.Errorf("unexpected %q at %d", c, i)
+			}
+			if end < 0 {
+				end = len(expr) - i
+			}
+			tokens = append(tokens, fieldFilterToken{tokenWord, expr[i : i+end]})
+			i += end
+		}
+	}
+	return append(tokens, fieldFilterToken{kind: tokenEOF}), nil
+}
+
+type fieldFilterParser struct {
+	tokens []fieldFilterToken
+	pos    int
+}
+
+func (p *fieldFilterParser) peek() fieldFilterToken {
+	return p.tokens[p.pos]
+}
+
+func (p *fieldFilterParser) next() fieldFilterToken {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *fieldFilterParser) parseOr() (fieldExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orExpr{left, right}
+	}
+	return left, nil
+}
+
+func (p *fieldFilterParser) parseAnd() (fieldExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenAnd {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andExpr{left, right}
+	}
+	return left, nil
+}
+
+func (p *fieldFilterParser) parseUnary() (fieldExpr, error) {
+	switch p.peek().kind {
+	case tokenNot:
+		p.next()
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{e}, nil
+	case tokenLParen:
+		p.next()
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != tokenRParen {
+			return nil, fmt.Errorf("expected \")\", but got %s", t)
+		}
+		return e, nil
+	}
+	return p.parsePredicate()
+}
+
+func (p *fieldFilterParser) parsePredicate() (fieldExpr, error) {
+	field := p.next()
+	if field.kind != tokenWord && field.kind != tokenString {
+		return nil, fmt.Errorf("expected a field, but got %s", field)
+	}
+
+	var operator string
+	var values []string
+	switch t := p.peek(); {
+	case t.kind == tokenOperator:
+		p.next()
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		operator, values = t.value, []string{value}
+	case t.kind == tokenWord && t.value == "in":
+		p.next()
+		list, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		operator, values = "in", list
+	case t.kind == tokenWord && t.value == "not":
+		p.next()
+		if t := p.next(); t.kind != tokenWord || t.value != "in" {
+			return nil, fmt.Errorf("expected \"in\" after \"not\", but got %s", t)
+		}
+		list, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		operator, values = "not in", list
+	default:
+		return existsExpr{field: field.value}, nil
+	}
+
+	predicate, err := newValuePredicate(operator, values...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", field.value, err)
+	}
+	return predicateExpr{valuePredicate: predicate, field: field.value}, nil
+}
+
+func (p *fieldFilterParser) parseValue() (string, error) {
+	t := p.next()
+	if t.kind != tokenWord && t.kind != tokenString {
+		return "", fmt.Errorf("expected a value, but got %s", t)
+	}
+	return t.value, nil
+}
+
+func (p *fieldFilterParser) parseList() ([]string, error) {
+	if t := p.next(); t.kind != tokenLParen {
+		return nil, fmt.Errorf("expected \"(\", but got %s", t)
+	}
+	var values []string
+	for {
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		switch t := p.next(); t.kind {
+		case tokenComma:
+		case tokenRParen:
+			return values, nil
+		default:
+			return nil, fmt.Errorf("expected \",\" or \")\", but got %s", t)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package stern
+
+import (
+	"testing"
+)
+
+func TestNewFieldFilter(t *testing.T) {
+	tests := []struct {
+		expr    string
+		isError bool
+	}{
+		{"level=error", false},
+		{"level == error", false},
+		{`level in (error,fatal) && status >= 500 && path =~ "^/api"`, false},
+		{"level not in (debug, info) || !(status < 400)", false},
+		{"http.status>=500", false},
+		{`"user id" = 'a b'`, false},
+		{"error", false},
+		{"", true},
+		{"level =", true},
+		{"level in error", true},
+		{"level in (error,", true},
+		{"level not (error)", true},
+		{"status > high", true},
+		{"path =~ (", true},
+		{`path =~ "(`, true},
+		{"(level=error", true},
+		{"level=error)", true},
+		{"level=error && ", true},
+		{"level=error & status=500", true},
+	}
+
+	for _, tt := range tests {
+		_, err := NewFieldFilter(tt.expr)
+		if tt.isError && err == nil {
+			t.Errorf("%s: expected error, but got no error", tt.expr)
+		}
+		if !tt.isError && err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.expr, err)
+		}
+	}
+}
+
+func TestFieldFilterMatches(t *testing.T) {
+	fields, ok := parseStructuredLog(`{"level":"error","status":503,"path":"/api/users","http":{"method":"GET"},"tags":["a","b"],"user id":"a b","retry":false}`)
+	if !ok {
+		t.Fatal("failed to parse the log")
+	}
+
+	tests := []struct {
+		expr     string
+		expected bool
+	}{
+		{"level=error", true},
+		{"level==warn", false},
+		{"level!=warn", true},
+		{"level in (error,fatal)", true},
+		{"level in (warn, info)", false},
+		{"level not in (debug, info)", true},
+		{"level not in (error)", false},
+		{"status>=500", true},
+		{"status<500", false},
+		{"status>502.5", true},
+		{`path =~ "^/api"`, true},
+		{"path !~ ^/api", false},
+		{"http.method=GET", true},
+		{"tags=b", true},
+		{"tags!=b", false},
+		{`"user id" = 'a b'`, true},
+		{"retry=false", true},
+		{`level in (error,fatal) && status >= 500 && path =~ "^/api"`, true},
+		{"level=warn || status=503", true},
+		{"level=warn || status=404", false},
+		{"!(level=error)", false},
+		{"!level=warn && status=503", true},
+		{"level=warn && status=503 || path=/api/users", true},
+		{"level=warn && (status=503 || path=/api/users)", false},
+		// existence
+		{"http", true},
+		{"trace", false},
+		{"!trace", true},
+		// missing fields
+		{"trace=abc", false},
+		{"trace!=abc", true},
+		{"trace!~abc", true},
+		{"trace not in (abc)", true},
+		{"trace>0", false},
+		{"http.status=200", false},
+	}
+
+	for _, tt := range tests {
+		f, err := NewFieldFilter(tt.expr)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.expr, err)
+			continue
+		}
+		if actual := f.Matches(fields); tt.expected != actual {
+			t.Errorf("%s: expected %v, but actual %v", tt.expr, tt.expected, actual)
+		}
+	}
+}
+
+func mustNewFieldFilter(expr string) *FieldFilter {
+	f, err := NewFieldFilter(expr)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
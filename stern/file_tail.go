@@ -12,22 +12,29 @@ import (
 )
 
 type FileTail struct {
-	Options *TailOptions
-	tmpl    *template.Template
-	in      io.Reader
-	out     io.Writer
-	errOut  io.Writer
+	Options      *TailOptions
+	tmpl         *template.Template
+	contextLines *contextLines
+	multiline    *multilineBuffer
+	repeats      *repeatCollapser
+	in           io.Reader
+	out          io.Writer
+	errOut       io.Writer
 }
 
 // NewFileTail returns a new tail of the input reader
 func NewFileTail(tmpl *template.Template, in io.Reader, out, errOut io.Writer, options *TailOptions) *FileTail {
-	return &FileTail{
-		Options: options,
-		tmpl:    tmpl,
-		in:      in,
-		out:     out,
-		errOut:  errOut,
+	t := &FileTail{
+		Options:      options,
+		tmpl:         tmpl,
+		contextLines: newContextLines(options),
+		in:           in,
+		out:          out,
+		errOut:       errOut,
 	}
+	t.multiline = newMultilineBuffer(options, func(_, msg string) { t.consumeEntry(msg) })
+	t.repeats = newRepeatCollapser(options, func(msg, _ string, level Level) { t.printLevel(msg, level) }, t.printRepeated)
+	return t
 }
 
 // Start starts tailing
@@ -41,6 +48,9 @@ func (t *FileTail) Start() error {
 // ConsumeReader reads the data from the reader and writes into the out
 // writer.
 func (t *FileTail) ConsumeReader(reader *bufio.Reader) error {
+	defer t.repeats.flush()
+	defer t.multiline.flush()
+
 	for {
 		line, err := reader.ReadBytes('\n')
 		if len(line) != 0 {
@@ -56,10 +66,12 @@ func (t *FileTail) ConsumeReader(reader *bufio.Reader) error {
 	}
 }
 
-func (t *FileTail) sprint(msg string, timestamp string) (string, error) {
+func (t *FileTail) sprint(msg string, timestamp string, level Level, repeatCount int) (string, error) {
 	vm := Log{
-		Message:        msg,
+		Message:        t.Options.RedactMessage(msg),
 		Timestamp:      timestamp,
+		RepeatCount:    repeatCount,
+		Level:          level.String(),
 		NodeName:       "",
 		Namespace:      "",
 		PodName:        "",
@@ -78,7 +90,12 @@ func (t *FileTail) sprint(msg string, timestamp string) (string, error) {
 
 // Print prints a color coded log message
 func (t *FileTail) Print(msg string) {
-	buf, err := t.sprint(msg, "")
+	t.printLevel(msg, t.Options.detectLevel(msg))
+}
+
+// printLevel is like Print, but with the level detected beforehand
+func (t *FileTail) printLevel(msg string, level Level) {
+	buf, err := t.sprint(msg, "", level, 0)
 	if err != nil {
 		fmt.Fprintf(t.errOut, "%s\n", err)
 		return
@@ -89,7 +106,7 @@ func (t *FileTail) Print(msg string) {
 
 // PrintWithoutHighlight prints a log message without applying any highlight.
 func (t *FileTail) PrintWithoutHighlight(msg string) {
-	buf, err := t.sprint(msg, "")
+	buf, err := t.sprint(msg, "", LevelUnknown, 0)
 	if err != nil {
 		fmt.Fprintf(t.errOut, "%s\n", err)
 		return
@@ -99,11 +116,55 @@ func (t *FileTail) PrintWithoutHighlight(msg string) {
 }
 
 func (t *FileTail) consumeLine(line string) {
-	content := line
+	if t.multiline != nil {
+		t.multiline.add("", line)
+		return
+	}
+	t.consumeEntry(line)
+}
+
+// consumeEntry filters and prints a log entry, which consists of multiple
+// lines when multi-line entries are joined
+func (t *FileTail) consumeEntry(content string) {
+	level := t.Options.detectLevel(content)
+	if t.Options.IsExclude(content) || t.Options.isBelowMinLevel(level) {
+		return
+	}
+
+	matched := t.Options.IsInclude(content) && t.Options.IsFieldMatch(content)
+	t.contextLines.add(contextLine{msg: content, level: level}, matched,
+		t.printEntry,
+		t.printSeparator,
+		nil)
+}
 
-	if t.Options.IsExclude(content) || !t.Options.IsInclude(content) {
+// printSeparator prints ContextSeparator through the template like a log
+// line
+func (t *FileTail) printSeparator() {
+	t.repeats.flush()
+	if t.Options.OmitContextSeparator {
 		return
 	}
+	t.PrintWithoutHighlight(ContextSeparator)
+}
+
+// printEntry prints the entry unless it repeats the last one when repeated
+// messages are collapsed
+func (t *FileTail) printEntry(l contextLine) {
+	if t.repeats != nil {
+		t.repeats.add(l.msg, "", l.level)
+		return
+	}
+	t.printLevel(l.msg, l.level)
+}
 
-	t.Print(content)
+// printRepeated prints the number of the suppressed repeated messages
+func (t *FileTail) printRepeated(count int, _ string) {
+	buf, err := t.sprint(repeatMessage(count), "", LevelUnknown, count)
+	if err != nil {
+		fmt.Fprintf(t.errOut, "%s\n", err)
+		return
+	}
+
+	fmt.Fprint(t.out, buf)
 }
@@ -4,9 +4,12 @@ import (
 	"bufio"
 	"bytes"
 	"io"
+	"regexp"
 	"strings"
 	"testing"
 	"text/template"
+
+	"github.com/fatih/color"
 )
 
 func TestConsumeFileTail(t *testing.T) {
@@ -19,6 +22,7 @@ line 4`
 	tests := []struct {
 		name      string
 		resumeReq *ResumeRequest
+		options   *TailOptions
 		expected  []byte
 	}{
 		{
@@ -27,6 +31,18 @@ line 4`
 line 2
 line 3
 line 4
+`),
+		},
+		{
+			name: "context lines",
+			options: &TailOptions{
+				Include:       []*regexp.Regexp{regexp.MustCompile("line [14]")},
+				BeforeContext: 1,
+			},
+			expected: []byte(`line 1
+--
+line 3
+line 4
 `),
 		},
 	}
@@ -34,7 +50,11 @@ line 4
 	for i, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			out := new(bytes.Buffer)
-			tail := NewFileTail(tmpl, nil, out, io.Discard, &TailOptions{})
+			options := tt.options
+			if options == nil {
+				options = &TailOptions{}
+			}
+			tail := NewFileTail(tmpl, nil, out, io.Discard, options)
 			if err := tail.ConsumeReader(bufio.NewReader(strings.NewReader(logLines))); err != nil {
 				t.Fatalf("%d: unexpected err %v", i, err)
 			}
@@ -45,3 +65,30 @@ line 4
 		})
 	}
 }
+
+func TestConsumeFileTailRedact(t *testing.T) {
+	color.NoColor = false
+	defer func() { color.NoColor = true }()
+
+	rules, err := RedactPreset("email")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	options := &TailOptions{
+		Redact:    rules,
+		Highlight: []*regexp.Regexp{regexp.MustCompile("alice")},
+	}
+	tmpl := template.Must(template.New("").Parse(`{{printf "%s\n" .Message}}`))
+
+	out := new(bytes.Buffer)
+	tail := NewFileTail(tmpl, nil, out, io.Discard, options)
+	if err := tail.ConsumeReader(bufio.NewReader(strings.NewReader("sent to alice@example.com"))); err != nil {
+		t.Fatalf("unexpected err %v", err)
+	}
+
+	// the redacted string is not highlighted
+	expected := "sent to [REDACTED]\n"
+	if expected != out.String() {
+		t.Errorf("expected %q, but actual %q", expected, out)
+	}
+}
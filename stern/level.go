@@ -0,0 +1,136 @@
+package stern
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Level is the severity of a log line
+type Level int
+
+const (
+	// LevelUnknown means the level is not detected
+	LevelUnknown Level = iota
+	LevelTrace
+	LevelDebug
+	LevelInfo
+	LevelWarn
+	LevelError
+	LevelFatal
+)
+
+var levelNames = map[Level]string{
+	LevelTrace: "trace",
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelWarn:  "warn",
+	LevelError: "error",
+	LevelFatal: "fatal",
+}
+
+// levelAliases maps the level names used by various loggers to Level
+var levelAliases = map[string]Level{
+	"trace":       LevelTrace,
+	"debug":       LevelDebug,
+	"dbg":         LevelDebug,
+	"verbose":     LevelDebug,
+	"info":        LevelInfo,
+	"information": LevelInfo,
+	"notice":      LevelInfo,
+	"warn":        LevelWarn,
+	"warning":     LevelWarn,
+	"error":       LevelError,
+	"err":         LevelError,
+	"dpanic":      LevelFatal,
+	"panic":       LevelFatal,
+	"fatal":       LevelFatal,
+	"critical":    LevelFatal,
+	"crit":        LevelFatal,
+	"alert":       LevelFatal,
+	"emerg":       LevelFatal,
+	"emergency":   LevelFatal,
+}
+
+// String returns the normalized name of the level, or an empty string if
+// the level is unknown
+func (l Level) String() string {
+	return levelNames[l]
+}
+
+// ParseLevel returns the level of the name. It accepts the aliases such as
+// "warning" and "err" in any case.
+func ParseLevel(name string) (Level, error) {
+	if l, ok := levelAliases[strings.ToLower(name)]; ok {
+		return l, nil
+	}
+	return LevelUnknown, fmt.Errorf("unknown level %q: it should be one of trace, debug, info, warn, error and fatal", name)
+}
+
+var (
+	// zap, logrus and other JSON loggers, or bunyan and pino with numeric levels
+	jsonLevelRegexp = regexp.MustCompile(`"(?:level|lvl|severity|log\.level)"\s*:\s*(?:"([A-Za-z]+)"|(\d+))`)
+	// klog and glog like "E1017 12:00:00.000000 ..."
+	klogLevelRegexp = regexp.MustCompile(`^([IWEF])\d{4} \d{2}:\d{2}:\d{2}\.\d{6}\s`)
+	// logrus text formatter and other logfmt loggers
+	logfmtLevelRegexp = regexp.MustCompile(`(?:^|\s)(?:level|lvl|severity)="?([A-Za-z]+)"?(?:\s|$)`)
+	// plain prefix like "WARN ...", "[ERROR] ...", "INFO[0000] ..." or
+	// "2024-01-01 12:00:00.000 WARN ...", allowing up to two tokens of the
+	// date and time before the level
+	prefixLevelRegexp = regexp.MustCompile(`^(?:\S*\d\S*\s+){0,2}\[?(TRACE|DEBUG|INFO|WARN|WARNING|ERROR|ERR|FATAL|PANIC|CRITICAL)\]?(?:[\s:\[]|$)`)
+)
+
+var klogLevels = map[string]Level{
+	"I": LevelInfo,
+	"W": LevelWarn,
+	"E": LevelError,
+	"F": LevelFatal,
+}
+
+// DetectLevel detects the level of the log line. It supports JSON lines with
+// a level field in a name or in a bunyan number, klog headers, logfmt level
+// fields and plain level prefixes.
+func DetectLevel(msg string) Level {
+	if strings.HasPrefix(strings.TrimSpace(msg), "{") {
+		m := jsonLevelRegexp.FindStringSubmatch(msg)
+		if m == nil {
+			return LevelUnknown
+		}
+		if m[2] != "" {
+			return bunyanLevel(m[2])
+		}
+		return levelAliases[strings.ToLower(m[1])]
+	}
+	if m := klogLevelRegexp.FindStringSubmatch(msg); m != nil {
+		return klogLevels[m[1]]
+	}
+	if m := logfmtLevelRegexp.FindStringSubmatch(msg); m != nil {
+		return levelAliases[strings.ToLower(m[1])]
+	}
+	if m := prefixLevelRegexp.FindStringSubmatch(msg); m != nil {
+		return levelAliases[strings.ToLower(m[1])]
+	}
+	return LevelUnknown
+}
+
+// bunyanLevel converts the numeric level of bunyan and pino
+func bunyanLevel(s string) Level {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return LevelUnknown
+	}
+	switch {
+	case n < 20:
+		return LevelTrace
+	case n < 30:
+		return LevelDebug
+	case n < 40:
+		return LevelInfo
+	case n < 50:
+		return LevelWarn
+	case n < 60:
+		return LevelError
+	}
+	return LevelFatal
+}
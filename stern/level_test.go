@@ -0,0 +1,89 @@
+package stern
+
+import "testing"
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected Level
+		isError  bool
+	}{
+		{"trace", LevelTrace, false},
+		{"debug", LevelDebug, false},
+		{"info", LevelInfo, false},
+		{"WARN", LevelWarn, false},
+		{"warning", LevelWarn, false},
+		{"err", LevelError, false},
+		{"Fatal", LevelFatal, false},
+		{"critical", LevelFatal, false},
+		{"", LevelUnknown, true},
+		{"verbosest", LevelUnknown, true},
+	}
+
+	for _, tt := range tests {
+		actual, err := ParseLevel(tt.name)
+		if tt.isError {
+			if err == nil {
+				t.Errorf("%q: expected error, but got no error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if actual != tt.expected {
+			t.Errorf("%q: expected %v, but actual %v", tt.name, tt.expected, actual)
+		}
+	}
+}
+
+func TestDetectLevel(t *testing.T) {
+	tests := []struct {
+		msg      string
+		expected Level
+	}{
+		// zap and other JSON loggers
+		{`{"level":"info","ts":1697500000.0,"msg":"started"}`, LevelInfo},
+		{`{"level": "WARN", "msg": "slow"}`, LevelWarn},
+		{`{"severity":"ERROR","message":"failed"}`, LevelError},
+		{`{"level":"dpanic","msg":"oops"}`, LevelFatal},
+		{`{"msg":"no level"}`, LevelUnknown},
+		{`{"level":"unknown","msg":"oops"}`, LevelUnknown},
+		// bunyan and pino
+		{`{"name":"app","level":10,"msg":"trace"}`, LevelTrace},
+		{`{"name":"app","level":20,"msg":"debug"}`, LevelDebug},
+		{`{"name":"app","level":30,"msg":"info"}`, LevelInfo},
+		{`{"name":"app","level":40,"msg":"warn"}`, LevelWarn},
+		{`{"name":"app","level":50,"msg":"error"}`, LevelError},
+		{`{"name":"app","level":60,"msg":"fatal"}`, LevelFatal},
+		// klog
+		{"I1017 12:00:00.000000       1 main.go:10] started", LevelInfo},
+		{"W1017 12:00:00.000000       1 main.go:10] slow", LevelWarn},
+		{"E1017 12:00:00.000000       1 main.go:10] failed", LevelError},
+		{"F1017 12:00:00.000000       1 main.go:10] died", LevelFatal},
+		// logrus text and logfmt
+		{`time="2024-01-01T00:00:00Z" level=warning msg="slow"`, LevelWarn},
+		{`ts=2024-01-01T00:00:00Z lvl=debug msg=started`, LevelDebug},
+		{`level="error" msg="failed"`, LevelError},
+		{`INFO[0000] started`, LevelInfo},
+		// plain prefixes
+		{"WARN disk is almost full", LevelWarn},
+		{"[ERROR] failed to connect", LevelError},
+		{"ERROR: failed to connect", LevelError},
+		{"2024-01-01 12:00:00.000 DEBUG loading", LevelDebug},
+		{"2024-01-01T12:00:00Z FATAL died", LevelFatal},
+		{"TRACE", LevelTrace},
+		// no level
+		{"error connecting to the database", LevelUnknown},
+		{"the WARN is not a prefix", LevelUnknown},
+		{"INFORMATIONAL message", LevelUnknown},
+		{"", LevelUnknown},
+	}
+
+	for _, tt := range tests {
+		if actual := DetectLevel(tt.msg); actual != tt.expected {
+			t.Errorf("%s: expected %q, but actual %q", tt.msg, tt.expected, actual)
+		}
+	}
+}
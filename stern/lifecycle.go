@@ -0,0 +1,208 @@
+package stern
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ContainerEventType is the type of the lifecycle transition of a container
+type ContainerEventType string
+
+const (
+	// ContainerWaiting is emitted when the container starts waiting, such as
+	// ImagePullBackOff and CrashLoopBackOff
+	ContainerWaiting ContainerEventType = "Waiting"
+	// ContainerTerminated is emitted when the container terminates
+	ContainerTerminated ContainerEventType = "Terminated"
+	// ContainerRestarted is emitted when the restart count increases
+	ContainerRestarted ContainerEventType = "Restarted"
+	// ContainerReady is emitted when the running container becomes ready
+	ContainerReady ContainerEventType = "Ready"
+	// ContainerUnready is emitted when the running container becomes unready,
+	// which is usually caused by the failures of the readiness probe
+	ContainerUnready ContainerEventType = "Unready"
+)
+
+// ContainerEvent is a lifecycle transition of a container printed in the log
+// stream with --lifecycle-events
+type ContainerEvent struct {
+	Type         ContainerEventType `json:"type"`
+	Reason       string             `json:"reason,omitempty"`
+	Message      string             `json:"message,omitempty"`
+	ExitCode     *int32             `json:"exitCode,omitempty"`
+	Signal       int32              `json:"signal,omitempty"`
+	RestartCount int32              `json:"restartCount"`
+
+	time time.Time
+}
+
+// String returns the human-readable description of the event
+func (e ContainerEvent) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[lifecycle] %s", e.Type)
+	var details []string
+	if e.Reason != "" {
+		details = append(details, e.Reason)
+	}
+	if e.ExitCode != nil {
+		details = append(details, fmt.Sprintf("exit code %d", *e.ExitCode))
+	}
+	if e.Signal != 0 {
+		details = append(details, fmt.Sprintf("signal %d", e.Signal))
+	}
+	if e.Type == ContainerRestarted {
+		details = append(details, fmt.Sprintf("restart count %d", e.RestartCount))
+	}
+	if len(details) > 0 {
+		fmt.Fprintf(&b, " (%s)", strings.Join(details, ", "))
+	}
+	if e.Message != "" {
+		fmt.Fprintf(&b, ": %s", strings.TrimSpace(e.Message))
+	}
+	return b.String()
+}
+
+// level returns the level of the event, which is error for the abnormal
+// terminations and warn for the waiting and unready containers
+func (e ContainerEvent) level() Level {
+	switch e.Type {
+	case ContainerTerminated:
+		if e.ExitCode != nil && *e.ExitCode != 0 {
+			return LevelError
+		}
+	case ContainerWaiting, ContainerUnready, ContainerRestarted:
+		return LevelWarn
+	}
+	return LevelInfo
+}
+
+// lifecyclePrinter prints the lifecycle transitions of the containers
+// through a tail of each target, which is kept until the pod is removed. The
+// methods do nothing if it is nil.
+type lifecyclePrinter struct {
+	newTail func(t *Target) *Tail
+
+	mu    sync.Mutex
+	tails map[string]*Tail
+}
+
+func newLifecyclePrinter(newTail func(t *Target) *Tail) *lifecyclePrinter {
+	return &lifecyclePrinter{
+		newTail: newTail,
+		tails:   make(map[string]*Tail),
+	}
+}
+
+// print prints the events of the target
+func (p *lifecyclePrinter) print(t *Target, events []ContainerEvent) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	tail, ok := p.tails[t.GetID()]
+	if ok && tail.Pod.UID != t.Pod.UID {
+		// a new pod of the same name
+		tail.ordered.close()
+		ok = false
+	}
+	if !ok {
+		tail = p.newTail(t)
+		p.tails[t.GetID()] = tail
+	}
+	for _, e := range events {
+		tail.PrintEvent(e)
+	}
+}
+
+// remove closes the tails of the pod
+func (p *lifecyclePrinter) remove(podUID string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for id, tail := range p.tails {
+		if string(tail.Pod.UID) == podUID {
+			tail.ordered.close()
+			delete(p.tails, id)
+		}
+	}
+}
+
+// containerEvents returns the lifecycle transitions from the last status of
+// the container to the current one
+func containerEvents(last, current corev1.ContainerStatus) []ContainerEvent {
+	var events []ContainerEvent
+	now := time.Now()
+
+	// A terminated container is reported by the state, or only by the last
+	// termination state when it restarts before the terminated state is seen
+	if t := terminatedState(current); t != nil && !sameTermination(t, terminatedState(last)) {
+		exitCode := t.ExitCode
+		ts := t.FinishedAt.Time
+		if ts.IsZero() {
+			ts = now
+		}
+		events = append(events, ContainerEvent{
+			Type:         ContainerTerminated,
+			Reason:       t.Reason,
+			Message:      t.Message,
+			ExitCode:     &exitCode,
+			Signal:       t.Signal,
+			RestartCount: current.RestartCount,
+			time:         ts,
+		})
+	}
+	if current.RestartCount > last.RestartCount {
+		events = append(events, ContainerEvent{
+			Type:         ContainerRestarted,
+			RestartCount: current.RestartCount,
+			time:         now,
+		})
+	}
+	if w := current.State.Waiting; w != nil && (last.State.Waiting == nil || last.State.Waiting.Reason != w.Reason) {
+		events = append(events, ContainerEvent{
+			Type:         ContainerWaiting,
+			Reason:       w.Reason,
+			Message:      w.Message,
+			RestartCount: current.RestartCount,
+			time:         now,
+		})
+	}
+	// Only the flips of the same running container are reported because the
+	// container also becomes unready when it terminates or restarts
+	if current.Ready != last.Ready && current.ContainerID == last.ContainerID && current.State.Running != nil && last.State.Running != nil {
+		e := ContainerEvent{Type: ContainerUnready, RestartCount: current.RestartCount, time: now}
+		if current.Ready {
+			e.Type = ContainerReady
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+// terminatedState returns the terminated state of the container, or the last
+// termination state if the container has restarted
+func terminatedState(cs corev1.ContainerStatus) *corev1.ContainerStateTerminated {
+	if cs.State.Terminated != nil {
+		return cs.State.Terminated
+	}
+	return cs.LastTerminationState.Terminated
+}
+
+func sameTermination(a, b *corev1.ContainerStateTerminated) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	if a.ContainerID != "" || b.ContainerID != "" {
+		return a.ContainerID == b.ContainerID
+	}
+	return a.FinishedAt.Equal(&b.FinishedAt)
+}
@@ -0,0 +1,218 @@
+package stern
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"reflect"
+	"regexp"
+	"testing"
+	"text/template"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+)
+
+func TestContainerEvents(t *testing.T) {
+	running := corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}
+	oomKilled := &corev1.ContainerStateTerminated{
+		ContainerID: "cid1",
+		ExitCode:    137,
+		Reason:      "OOMKilled",
+		Message:     "out of memory\n",
+		FinishedAt:  metav1.NewTime(time.Date(2023, 2, 13, 21, 20, 30, 0, time.UTC)),
+	}
+	crashLoop := corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff", Message: "back-off 10s restarting failed container"}}
+
+	tests := []struct {
+		name     string
+		last     corev1.ContainerStatus
+		current  corev1.ContainerStatus
+		expected []ContainerEvent
+	}{
+		{
+			name:    "no change",
+			last:    corev1.ContainerStatus{ContainerID: "cid1", State: running, Ready: true},
+			current: corev1.ContainerStatus{ContainerID: "cid1", State: running, Ready: true},
+		},
+		{
+			name:    "terminated",
+			last:    corev1.ContainerStatus{ContainerID: "cid1", State: running, Ready: true},
+			current: corev1.ContainerStatus{ContainerID: "cid1", State: corev1.ContainerState{Terminated: oomKilled}},
+			expected: []ContainerEvent{
+				{Type: ContainerTerminated, Reason: "OOMKilled", Message: "out of memory\n", ExitCode: ptr.To[int32](137)},
+			},
+		},
+		{
+			name:    "waiting after terminated",
+			last:    corev1.ContainerStatus{ContainerID: "cid1", State: corev1.ContainerState{Terminated: oomKilled}},
+			current: corev1.ContainerStatus{ContainerID: "cid1", State: crashLoop, LastTerminationState: corev1.ContainerState{Terminated: oomKilled}, RestartCount: 1},
+			expected: []ContainerEvent{
+				{Type: ContainerRestarted, RestartCount: 1},
+				{Type: ContainerWaiting, Reason: "CrashLoopBackOff", Message: "back-off 10s restarting failed container", RestartCount: 1},
+			},
+		},
+		{
+			name:    "restarted before terminated is seen",
+			last:    corev1.ContainerStatus{ContainerID: "cid1", State: running, Ready: true},
+			current: corev1.ContainerStatus{ContainerID: "cid2", State: running, LastTerminationState: corev1.ContainerState{Terminated: oomKilled}, RestartCount: 1},
+			expected: []ContainerEvent{
+				{Type: ContainerTerminated, Reason: "OOMKilled", Message: "out of memory\n", ExitCode: ptr.To[int32](137), RestartCount: 1},
+				{Type: ContainerRestarted, RestartCount: 1},
+			},
+		},
+		{
+			name:    "same waiting reason",
+			last:    corev1.ContainerStatus{State: crashLoop, RestartCount: 1},
+			current: corev1.ContainerStatus{State: crashLoop, RestartCount: 1},
+		},
+		{
+			name:     "unready",
+			last:     corev1.ContainerStatus{ContainerID: "cid1", State: running, Ready: true},
+			current:  corev1.ContainerStatus{ContainerID: "cid1", State: running, Ready: false},
+			expected: []ContainerEvent{{Type: ContainerUnready}},
+		},
+		{
+			name:     "ready",
+			last:     corev1.ContainerStatus{ContainerID: "cid1", State: running, Ready: false},
+			current:  corev1.ContainerStatus{ContainerID: "cid1", State: running, Ready: true},
+			expected: []ContainerEvent{{Type: ContainerReady}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := containerEvents(tt.last, tt.current)
+			for i := range actual {
+				if actual[i].time.IsZero() {
+					t.Errorf("expected the time of %v to be set", actual[i])
+				}
+				actual[i].time = time.Time{}
+			}
+			if !reflect.DeepEqual(tt.expected, actual) {
+				t.Errorf("expected %v, but actual %v", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestContainerEventString(t *testing.T) {
+	tests := []struct {
+		event    ContainerEvent
+		expected string
+	}{
+		{
+			ContainerEvent{Type: ContainerTerminated, Reason: "OOMKilled", Message: "out of memory\n", ExitCode: ptr.To[int32](137)},
+			"[lifecycle] Terminated (OOMKilled, exit code 137): out of memory",
+		},
+		{
+			ContainerEvent{Type: ContainerWaiting, Reason: "ImagePullBackOff", Message: `Back-off pulling image "nginx:typo"`},
+			`[lifecycle] Waiting (ImagePullBackOff): Back-off pulling image "nginx:typo"`,
+		},
+		{
+			ContainerEvent{Type: ContainerRestarted, RestartCount: 3},
+			"[lifecycle] Restarted (restart count 3)",
+		},
+		{
+			ContainerEvent{Type: ContainerUnready},
+			"[lifecycle] Unready",
+		},
+	}
+
+	for _, tt := range tests {
+		if actual := tt.event.String(); actual != tt.expected {
+			t.Errorf("expected %q, but actual %q", tt.expected, actual)
+		}
+	}
+}
+
+func TestPrintEvent(t *testing.T) {
+	tmpl := template.Must(template.New("").Parse(`{{.Timestamp}} {{.Level}} {{.Message}} {{.Event.ExitCode}}` + "\n"))
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "my-namespace", Name: "my-pod"}}
+	out := new(bytes.Buffer)
+	redact, _ := NewRedactRule(`password=\S+`)
+	tail := NewTail(fake.NewSimpleClientset().CoreV1(), pod, "my-container", tmpl, out, io.Discard,
+		&TailOptions{Timestamps: true, TimestampFormat: TimestampFormatShort, Location: time.UTC, Redact: []*RedactRule{redact}}, false)
+
+	tail.PrintEvent(ContainerEvent{
+		Type:     ContainerTerminated,
+		Reason:   "Error",
+		Message:  "invalid password=secret",
+		ExitCode: ptr.To[int32](1),
+		time:     time.Date(2023, 2, 13, 21, 20, 30, 0, time.UTC),
+	})
+
+	expected := "02-13 21:20:30 error [lifecycle] Terminated (Error, exit code 1): invalid [REDACTED] 1\n"
+	if out.String() != expected {
+		t.Errorf("expected %q, but actual %q", expected, out)
+	}
+}
+
+func TestTargetFilterLifecycle(t *testing.T) {
+	out := new(bytes.Buffer)
+	tmpl := template.Must(template.New("").Parse(`{{.PodName}} {{.Event.Type}}` + "\n"))
+	var created int
+	newFilter := func(condition Condition) *targetFilter {
+		return newTargetFilter(targetFilterConfig{
+			podFilter:       regexp.MustCompile(`.*`),
+			containerFilter: regexp.MustCompile(`.*`),
+			containerStates: []ContainerState{ALL_STATES},
+			condition:       condition,
+			lifecycle: newLifecyclePrinter(func(t *Target) *Tail {
+				created++
+				return NewTail(fake.NewSimpleClientset().CoreV1(), t.Pod, t.Container, tmpl, out, io.Discard, &TailOptions{}, false)
+			}),
+		})
+	}
+	createPod := func(uid string, cs corev1.ContainerStatus) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "pod1", UID: types.UID(uid)},
+			Status:     corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{cs}},
+		}
+	}
+	running := corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}
+	notReady := corev1.ContainerStatus{Name: "c1", ContainerID: "cid1", State: running}
+	ready := corev1.ContainerStatus{Name: "c1", ContainerID: "cid1", State: running, Ready: true}
+	expectOutput := func(expected string) {
+		t.Helper()
+		if out.String() != expected {
+			t.Errorf("expected %q, but actual %q", expected, out)
+		}
+		out.Reset()
+	}
+
+	filter := newFilter(Condition{})
+	visit := func(pod *corev1.Pod) {
+		filter.visit(context.Background(), pod, func(*Target, bool) {})
+	}
+	// the first status is not reported
+	visit(createPod("uid1", notReady))
+	expectOutput("")
+	visit(createPod("uid1", ready))
+	visit(createPod("uid1", notReady))
+	expectOutput("pod1 Ready\npod1 Unready\n")
+	if created != 1 {
+		t.Errorf("expected a tail for the target, but created %d", created)
+	}
+	// a new pod of the same name is seen the first time
+	visit(createPod("uid2", ready))
+	expectOutput("")
+	// the removed pod is seen the first time again
+	filter.remove("uid2")
+	visit(createPod("uid2", notReady))
+	expectOutput("")
+
+	// the statuses are kept while the condition does not match
+	created = 0
+	filter = newFilter(Condition{Name: corev1.PodReady, Value: corev1.ConditionTrue})
+	visit(createPod("uid3", notReady))
+	visit(createPod("uid3", ready))
+	expectOutput("pod1 Ready\n")
+	if created != 1 {
+		t.Errorf("expected a tail for the target, but created %d", created)
+	}
+}
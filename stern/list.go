@@ -79,7 +79,7 @@ func ListTargets(ctx context.Context, i corev1client.PodInterface, labelSelector
 	}
 	var targets []*Target
 	for i := range list.Items {
-		filter.visit(&list.Items[i], func(t *Target, conditionFound bool) {
+		filter.visit(ctx, &list.Items[i], func(t *Target, conditionFound bool) {
 			if conditionFound {
 				targets = append(targets, t)
 			}
@@ -0,0 +1,180 @@
+package stern
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+var containerLabels = []string{"context", "namespace", "pod", "container"}
+
+// Metrics is the Prometheus metrics of the tails for long-running sessions.
+// It is shared by the runs of multiple contexts. The methods do nothing if
+// it is nil.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	activeTails     prometheus.Gauge
+	maxLogRequests  prometheus.Gauge
+	lines           *prometheus.CounterVec
+	bytes           *prometheus.CounterVec
+	retries         *prometheus.CounterVec
+	watchReconnects *prometheus.CounterVec
+	templateErrors  prometheus.Counter
+	patternMatches  *prometheus.CounterVec
+}
+
+// NewMetrics returns the metrics registered to a new registry with the Go
+// runtime and process metrics
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		activeTails: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "stern_active_tails",
+			Help: "Number of the log streams being read.",
+		}),
+		maxLogRequests: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "stern_max_log_requests",
+			Help: "Maximum number of the concurrent log requests set by --max-log-requests.",
+		}),
+		lines: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "stern_log_lines_total",
+			Help: "Number of the log lines read from the containers.",
+		}, containerLabels),
+		bytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "stern_log_bytes_total",
+			Help: "Size of the log lines read from the containers including timestamps.",
+		}, containerLabels),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "stern_tail_retries_total",
+			Help: "Number of the retries after the log streams of the containers fail.",
+		}, containerLabels),
+		watchReconnects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "stern_watch_reconnects_total",
+			Help: "Number of the reconnects of the watches after they are closed by the API server, and the restarts of the pod watches with new selectors.",
+		}, []string{"resource"}),
+		templateErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "stern_template_errors_total",
+			Help: "Number of the failures to expand the template.",
+		}),
+		patternMatches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "stern_pattern_matched_lines_total",
+			Help: "Number of the log entries matching each pattern of --include, and of the printed ones highlighted by each pattern of --highlight.",
+		}, []string{"flag", "pattern"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.activeTails,
+		m.maxLogRequests,
+		m.lines,
+		m.bytes,
+		m.retries,
+		m.watchReconnects,
+		m.templateErrors,
+		m.patternMatches,
+	)
+	return m
+}
+
+// Handler returns the HTTP handler serving the metrics
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+func (m *Metrics) setMaxLogRequests(n int) {
+	if m == nil {
+		return
+	}
+	m.maxLogRequests.Set(float64(n))
+}
+
+func (m *Metrics) tailStarted() {
+	if m == nil {
+		return
+	}
+	m.activeTails.Inc()
+}
+
+func (m *Metrics) tailStopped() {
+	if m == nil {
+		return
+	}
+	m.activeTails.Dec()
+}
+
+func (m *Metrics) readLine(t *Tail, bytes int) {
+	if m == nil {
+		return
+	}
+	labels := []string{t.Options.Context, t.Pod.Namespace, t.Pod.Name, t.ContainerName}
+	m.lines.WithLabelValues(labels...).Inc()
+	m.bytes.WithLabelValues(labels...).Add(float64(bytes))
+}
+
+func (m *Metrics) retry(context string, t *Target) {
+	if m == nil {
+		return
+	}
+	m.retries.WithLabelValues(context, t.Pod.Namespace, t.Pod.Name, t.Container).Inc()
+}
+
+// forget deletes the series of the target that is no longer tailed
+func (m *Metrics) forget(context string, t *Target) {
+	if m == nil {
+		return
+	}
+	labels := []string{context, t.Pod.Namespace, t.Pod.Name, t.Container}
+	m.lines.DeleteLabelValues(labels...)
+	m.bytes.DeleteLabelValues(labels...)
+	m.retries.DeleteLabelValues(labels...)
+}
+
+func (m *Metrics) templateError() {
+	if m == nil {
+		return
+	}
+	m.templateErrors.Inc()
+}
+
+// patternCounter returns the function counting the entries matching each
+// pattern of the flag, or nil if m is nil
+func (m *Metrics) patternCounter(flag string) func(re *regexp.Regexp) {
+	if m == nil {
+		return nil
+	}
+	return func(re *regexp.Regexp) {
+		m.patternMatches.WithLabelValues(flag, re.String()).Inc()
+	}
+}
+
+// countWatchRestart counts the restart of the watch, which is not counted by
+// countReconnects because a new RetryWatcher is created
+func (m *Metrics) countWatchRestart(resource string) {
+	if m == nil {
+		return
+	}
+	m.watchReconnects.WithLabelValues(resource).Inc()
+}
+
+// countReconnects returns the watch function of a RetryWatcher counting the
+// calls after the first one, which are the reconnects
+func (m *Metrics) countReconnects(resource string, watchFunc func(options metav1.ListOptions) (watch.Interface, error)) func(options metav1.ListOptions) (watch.Interface, error) {
+	if m == nil {
+		return watchFunc
+	}
+	// RetryWatcher calls the function sequentially
+	started := false
+	return func(options metav1.ListOptions) (watch.Interface, error) {
+		if started {
+			m.watchReconnects.WithLabelValues(resource).Inc()
+		}
+		started = true
+		return watchFunc(options)
+	}
+}
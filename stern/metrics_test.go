@@ -0,0 +1,117 @@
+package stern
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestMetricsTail(t *testing.T) {
+	metrics := NewMetrics()
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "pod1"}}
+	newTail := func(tmpl string) *Tail {
+		tail := NewTail(fake.NewSimpleClientset().CoreV1(), pod, "c1", template.Must(template.New("").Parse(tmpl)), io.Discard, io.Discard, &TailOptions{
+			Context:   "ctx1",
+			Include:   []*regexp.Regexp{regexp.MustCompile("line"), regexp.MustCompile("error")},
+			Highlight: []*regexp.Regexp{regexp.MustCompile("error")},
+		}, false)
+		tail.metrics = metrics
+		return tail
+	}
+
+	logLines := `2023-02-13T21:20:30.000000001Z line 1
+2023-02-13T21:20:30.000000002Z error line 2
+2023-02-13T21:20:31.000000001Z log 3
+`
+	tail := newTail(`{{.Message}}` + "\n")
+	if err := tail.ConsumeRequest(context.TODO(), &responseWrapperMock{data: bytes.NewBufferString(logLines)}); err != nil {
+		t.Fatalf("unexpected err %v", err)
+	}
+	// the template fails with the field that does not exist
+	tail = newTail(`{{.Message.Foo}}` + "\n")
+	tail.Print("line", "")
+	metrics.retry("ctx1", &Target{Pod: pod, Container: "c1"})
+	// the events are not counted as log entries
+	tail = newTail(`{{.Message}}` + "\n")
+	tail.kind = EventKind
+	tail.consumeEntry("2023-02-13T21:20:32.000000001Z", "reason=BackOff message=\"error line\"")
+
+	tests := []struct {
+		name     string
+		actual   float64
+		expected float64
+	}{
+		{"lines", testutil.ToFloat64(metrics.lines.WithLabelValues("ctx1", "ns1", "pod1", "c1")), 3},
+		{"bytes", testutil.ToFloat64(metrics.bytes.WithLabelValues("ctx1", "ns1", "pod1", "c1")), float64(len(logLines))},
+		{"retries", testutil.ToFloat64(metrics.retries.WithLabelValues("ctx1", "ns1", "pod1", "c1")), 1},
+		{"include line", testutil.ToFloat64(metrics.patternMatches.WithLabelValues("include", "line")), 2},
+		{"include error", testutil.ToFloat64(metrics.patternMatches.WithLabelValues("include", "error")), 1},
+		{"highlight error", testutil.ToFloat64(metrics.patternMatches.WithLabelValues("highlight", "error")), 1},
+		{"template errors", testutil.ToFloat64(metrics.templateErrors), 1},
+		{"active tails", testutil.ToFloat64(metrics.activeTails), 0},
+	}
+	for _, tt := range tests {
+		if tt.actual != tt.expected {
+			t.Errorf("%s: expected %v, but actual %v", tt.name, tt.expected, tt.actual)
+		}
+	}
+}
+
+func TestMetricsForget(t *testing.T) {
+	metrics := NewMetrics()
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "pod1"}}
+	for _, container := range []string{"c1", "c2"} {
+		target := &Target{Pod: pod, Container: container}
+		metrics.retry("ctx1", target)
+		metrics.readLine(&Tail{Pod: pod, ContainerName: container, Options: &TailOptions{Context: "ctx1"}}, 10)
+	}
+
+	metrics.forget("ctx1", &Target{Pod: pod, Container: "c1"})
+	for _, c := range []*prometheus.CounterVec{metrics.lines, metrics.bytes, metrics.retries} {
+		if actual := testutil.CollectAndCount(c); actual != 1 {
+			t.Errorf("expected the series of c2 to be kept, but got %d series", actual)
+		}
+	}
+}
+
+func TestMetricsCountReconnects(t *testing.T) {
+	metrics := NewMetrics()
+	watchFunc := metrics.countReconnects("pods", func(options metav1.ListOptions) (watch.Interface, error) {
+		return watch.NewEmptyWatch(), nil
+	})
+	for range 3 {
+		if _, err := watchFunc(metav1.ListOptions{}); err != nil {
+			t.Fatalf("unexpected err %v", err)
+		}
+	}
+	if actual := testutil.ToFloat64(metrics.watchReconnects.WithLabelValues("pods")); actual != 2 {
+		t.Errorf("expected 2 reconnects, but actual %v", actual)
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	metrics := NewMetrics()
+	metrics.setMaxLogRequests(50)
+	metrics.tailStarted()
+
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, expected := range []string{"stern_active_tails 1\n", "stern_max_log_requests 50\n", "go_goroutines "} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected %q in the metrics, but got\n%s", expected, body)
+		}
+	}
+}
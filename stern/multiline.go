@@ -0,0 +1,148 @@
+package stern
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxMultilineLines is the maximum number of lines joined into one entry to
+// bound the memory when the start of the next entry never comes
+const maxMultilineLines = 1000
+
+// multilinePresets are the patterns of lines that continue the entry of
+// well-known multi-line logs
+var multilinePresets = map[string]*regexp.Regexp{
+	// stack frames, causes and the exception line following the log message
+	"java": regexp.MustCompile(`^\s+at\s|^\s*\.\.\. \d+ (more|common frames omitted)|^\s*(Caused by|Suppressed): |^[\w$.]+(Exception|Error|Throwable)(: .*)?$`),
+	// tracebacks following the log message, chained exceptions and the
+	// exception line at the end
+	"python": regexp.MustCompile(`^(\s|$)|^Traceback \(most recent call last\):|^During handling of the above exception|^The above exception was the direct cause|^([\w.]+(Error|Exception|Warning)|KeyboardInterrupt|SystemExit|StopIteration|GeneratorExit)(: |$)`),
+	// goroutine dumps following "panic: ..."
+	"go": regexp.MustCompile(`^(\s|$)|^goroutine \d+ \[|^created by |^\[signal |^panic: .* \[recovered\]|^[\w./*()\[\]-]+\(.*\)$|^exit status \d+$`),
+}
+
+// Multiline decides which lines are joined into one multi-line log entry
+// such as a stack trace
+type Multiline struct {
+	start         *regexp.Regexp
+	continuations []*regexp.Regexp
+}
+
+// NewMultiline returns a Multiline for the presets and the regular
+// expression of the lines starting an entry. Lines that match any of the
+// presets or do not match the start continue the previous entry. It returns
+// nil if neither is specified.
+func NewMultiline(presets []string, start string) (*Multiline, error) {
+	if len(presets) == 0 && start == "" {
+		return nil, nil
+	}
+	m := &Multiline{}
+	for _, name := range presets {
+		re, ok := multilinePresets[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown multiline preset %q: it should be one of %s", name, strings.Join(MultilinePresetNames(), ", "))
+		}
+		m.continuations = append(m.continuations, re)
+	}
+	if start != "" {
+		re, err := regexp.Compile(start)
+		if err != nil {
+			return nil, err
+		}
+		m.start = re
+	}
+	return m, nil
+}
+
+// MultilinePresetNames returns the names of the multiline presets
+func MultilinePresetNames() []string {
+	names := make([]string, 0, len(multilinePresets))
+	for name := range multilinePresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (m *Multiline) continues(line string) bool {
+	for _, re := range m.continuations {
+		if re.MatchString(line) {
+			return true
+		}
+	}
+	return m.start != nil && !m.start.MatchString(line)
+}
+
+// multilineBuffer joins lines into entries per container. The pending entry
+// is passed to emit when the next entry starts, when it is flushed, or when
+// no line comes within the timeout.
+type multilineBuffer struct {
+	multiline *Multiline
+	timeout   time.Duration
+	emit      func(timestamp, msg string)
+
+	mu        sync.Mutex
+	timestamp string // the timestamp of the first line of the pending entry
+	lines     []string
+	timer     *time.Timer
+}
+
+// newMultilineBuffer returns nil if multi-line entries are not joined
+func newMultilineBuffer(options *TailOptions, emit func(timestamp, msg string)) *multilineBuffer {
+	if options.Multiline == nil {
+		return nil
+	}
+	return &multilineBuffer{
+		multiline: options.Multiline,
+		timeout:   options.MultilineTimeout,
+		emit:      emit,
+	}
+}
+
+func (b *multilineBuffer) add(timestamp, line string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.lines) > 0 && (!b.multiline.continues(line) || len(b.lines) >= maxMultilineLines) {
+		b.flushLocked()
+	}
+	if len(b.lines) == 0 {
+		b.timestamp = timestamp
+	}
+	b.lines = append(b.lines, line)
+
+	if b.timeout > 0 {
+		if b.timer == nil {
+			b.timer = time.AfterFunc(b.timeout, b.flush)
+		} else {
+			b.timer.Reset(b.timeout)
+		}
+	}
+}
+
+// flush emits the pending entry if any
+func (b *multilineBuffer) flush() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.flushLocked()
+}
+
+func (b *multilineBuffer) flushLocked() {
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+	if len(b.lines) == 0 {
+		return
+	}
+	msg := strings.Join(b.lines, "\n")
+	b.lines = b.lines[:0]
+	b.emit(b.timestamp, msg)
+}
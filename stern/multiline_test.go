@@ -0,0 +1,253 @@
+package stern
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"text/template"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNewMultiline(t *testing.T) {
+	tests := []struct {
+		presets []string
+		start   string
+		isNil   bool
+		isError bool
+	}{
+		{nil, "", true, false},
+		{[]string{"java"}, "", false, false},
+		{[]string{"java", "python", "go"}, "", false, false},
+		{nil, `^\d{4}-`, false, false},
+		{[]string{"cobol"}, "", false, true},
+		{nil, "[invalid", false, true},
+	}
+
+	for i, tt := range tests {
+		m, err := NewMultiline(tt.presets, tt.start)
+		if tt.isError {
+			if err == nil {
+				t.Errorf("%d: expected error, but got no error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: unexpected error: %v", i, err)
+			continue
+		}
+		if (m == nil) != tt.isNil {
+			t.Errorf("%d: expected nil=%v, but actual %v", i, tt.isNil, m)
+		}
+	}
+}
+
+func TestMultilineBuffer(t *testing.T) {
+	tests := []struct {
+		name     string
+		presets  []string
+		start    string
+		lines    string
+		expected []string
+	}{
+		{
+			name:    "java",
+			presets: []string{"java"},
+			lines: `2024-01-01 12:00:00 INFO started
+2024-01-01 12:00:01 ERROR request failed
+java.lang.IllegalStateException: boom
+	at com.example.Foo.bar(Foo.java:10)
+	at com.example.Main.main(Main.java:5)
+Caused by: java.io.IOException: closed
+	at com.example.Io.read(Io.java:3)
+	... 2 more
+2024-01-01 12:00:02 INFO recovered`,
+			expected: []string{
+				"2024-01-01 12:00:00 INFO started",
+				`2024-01-01 12:00:01 ERROR request failed
+java.lang.IllegalStateException: boom
+	at com.example.Foo.bar(Foo.java:10)
+	at com.example.Main.main(Main.java:5)
+Caused by: java.io.IOException: closed
+	at com.example.Io.read(Io.java:3)
+	... 2 more`,
+				"2024-01-01 12:00:02 INFO recovered",
+			},
+		},
+		{
+			name:    "python",
+			presets: []string{"python"},
+			lines: `ERROR:root:request failed
+Traceback (most recent call last):
+  File "app.py", line 3, in <module>
+    main()
+ZeroDivisionError: division by zero
+
+During handling of the above exception, another exception occurred:
+
+Traceback (most recent call last):
+  File "app.py", line 5, in <module>
+    raise RuntimeError("boom")
+RuntimeError: boom
+INFO:root:recovered`,
+			expected: []string{
+				`ERROR:root:request failed
+Traceback (most recent call last):
+  File "app.py", line 3, in <module>
+    main()
+ZeroDivisionError: division by zero
+
+During handling of the above exception, another exception occurred:
+
+Traceback (most recent call last):
+  File "app.py", line 5, in <module>
+    raise RuntimeError("boom")
+RuntimeError: boom`,
+				"INFO:root:recovered",
+			},
+		},
+		{
+			name:    "go",
+			presets: []string{"go"},
+			lines: `starting server
+panic: runtime error: index out of range [5] with length 3
+
+goroutine 1 [running]:
+main.main()
+	/app/main.go:8 +0x1d
+exit status 2
+restarted`,
+			expected: []string{
+				"starting server",
+				`panic: runtime error: index out of range [5] with length 3
+
+goroutine 1 [running]:
+main.main()
+	/app/main.go:8 +0x1d
+exit status 2`,
+				"restarted",
+			},
+		},
+		{
+			name:  "start",
+			start: `^\d{4}-`,
+			lines: `2024-01-01 first
+  continued
+still continued
+2024-01-02 second`,
+			expected: []string{
+				"2024-01-01 first\n  continued\nstill continued",
+				"2024-01-02 second",
+			},
+		},
+		{
+			name:  "continuation lines before the first start",
+			start: `^\d{4}-`,
+			lines: `orphan
+2024-01-01 first`,
+			expected: []string{
+				"orphan",
+				"2024-01-01 first",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewMultiline(tt.presets, tt.start)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var actual []string
+			b := newMultilineBuffer(&TailOptions{Multiline: m}, func(_, msg string) {
+				actual = append(actual, msg)
+			})
+			for line := range strings.SplitSeq(tt.lines, "\n") {
+				b.add("", line)
+			}
+			b.flush()
+
+			if !reflect.DeepEqual(tt.expected, actual) {
+				t.Errorf("expected %q, but actual %q", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestMultilineBufferTimeout(t *testing.T) {
+	m, err := NewMultiline([]string{"java"}, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var mu sync.Mutex
+	var actual []string
+	emitted := make(chan struct{}, 1)
+	b := newMultilineBuffer(&TailOptions{Multiline: m, MultilineTimeout: 10 * time.Millisecond}, func(timestamp, msg string) {
+		mu.Lock()
+		defer mu.Unlock()
+		actual = append(actual, timestamp+" "+msg)
+		emitted <- struct{}{}
+	})
+
+	b.add("ts1", "java.lang.IllegalStateException: boom")
+	b.add("ts2", "	at com.example.Foo.bar(Foo.java:10)")
+
+	select {
+	case <-emitted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out")
+	}
+
+	// nothing is pending after the timeout
+	b.flush()
+
+	mu.Lock()
+	defer mu.Unlock()
+	expected := []string{"ts1 java.lang.IllegalStateException: boom\n	at com.example.Foo.bar(Foo.java:10)"}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %q, but actual %q", expected, actual)
+	}
+}
+
+func TestConsumeStreamTailMultiline(t *testing.T) {
+	logLines := `2023-02-13T21:20:30.000000001Z INFO started
+2023-02-13T21:20:31.000000001Z ERROR request failed
+2023-02-13T21:20:31.000000002Z java.lang.IllegalStateException: boom
+2023-02-13T21:20:31.000000003Z 	at com.example.Foo.bar(Foo.java:10)
+2023-02-13T21:20:32.000000001Z INFO recovered`
+	tmpl := template.Must(template.New("").Parse(`{{printf "%s %q\n" .Timestamp .Message}}`))
+
+	m, err := NewMultiline([]string{"java"}, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	options := &TailOptions{
+		Timestamps:      true,
+		TimestampFormat: TimestampFormatDefault,
+		Location:        time.UTC,
+		Include:         []*regexp.Regexp{regexp.MustCompile("Exception")},
+		Multiline:       m,
+	}
+
+	out := new(bytes.Buffer)
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "my-namespace", Name: "my-pod"}}
+	tail := NewTail(fake.NewSimpleClientset().CoreV1(), pod, "my-container", tmpl, out, io.Discard, options, false)
+	if err := tail.ConsumeRequest(context.TODO(), &responseWrapperMock{data: bytes.NewBufferString(logLines)}); err != nil {
+		t.Fatalf("unexpected err %v", err)
+	}
+
+	expected := `2023-02-13T21:20:31.000000001Z "ERROR request failed\njava.lang.IllegalStateException: boom\n\tat com.example.Foo.bar(Foo.java:10)"
+`
+	if expected != out.String() {
+		t.Errorf("expected `%s`, but actual `%s`", expected, out)
+	}
+}
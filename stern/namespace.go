@@ -0,0 +1,35 @@
+package stern
+
+import (
+	"regexp"
+)
+
+// namespaceFilter filters namespaces by regular expressions and a label selector
+type namespaceFilter struct {
+	query   []*regexp.Regexp // namespaces to include, all namespaces if empty
+	exclude []*regexp.Regexp // namespaces to exclude
+	labeled *objectWatcher   // namespaces matching the label selector, nil if not specified
+}
+
+// Matches returns if the namespace passes the filter
+func (f *namespaceFilter) Matches(namespace string) bool {
+	if len(f.query) > 0 && !matchAny(f.query, namespace) {
+		return false
+	}
+	if matchAny(f.exclude, namespace) {
+		return false
+	}
+	if f.labeled != nil && !f.labeled.Has(namespace) {
+		return false
+	}
+	return true
+}
+
+func matchAny(res []*regexp.Regexp, s string) bool {
+	for _, re := range res {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
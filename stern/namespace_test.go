@@ -0,0 +1,87 @@
+package stern
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestNamespaceFilterMatches(t *testing.T) {
+	labeled := &objectWatcher{objects: map[string]map[string]string{
+		"preview-1":     {"env": "preview"},
+		"team-payments": {"env": "preview"},
+	}}
+
+	tests := []struct {
+		desc     string
+		filter   *namespaceFilter
+		expected map[string]bool
+	}{
+		{
+			desc: "query",
+			filter: &namespaceFilter{
+				query: []*regexp.Regexp{regexp.MustCompile(`^team-payments-`), regexp.MustCompile(`^ops$`)},
+			},
+			expected: map[string]bool{
+				"team-payments-api": true,
+				"ops":               true,
+				"team-search":       false,
+				"ops-2":             false,
+			},
+		},
+		{
+			desc: "exclude",
+			filter: &namespaceFilter{
+				exclude: []*regexp.Regexp{regexp.MustCompile(`^kube-`)},
+			},
+			expected: map[string]bool{
+				"kube-system": false,
+				"default":     true,
+			},
+		},
+		{
+			desc: "query and exclude",
+			filter: &namespaceFilter{
+				query:   []*regexp.Regexp{regexp.MustCompile(`^team-`)},
+				exclude: []*regexp.Regexp{regexp.MustCompile(`-staging$`)},
+			},
+			expected: map[string]bool{
+				"team-payments":         true,
+				"team-payments-staging": false,
+				"default":               false,
+			},
+		},
+		{
+			desc: "selector",
+			filter: &namespaceFilter{
+				labeled: labeled,
+			},
+			expected: map[string]bool{
+				"preview-1":     true,
+				"team-payments": true,
+				"default":       false,
+			},
+		},
+		{
+			desc: "selector and query",
+			filter: &namespaceFilter{
+				query:   []*regexp.Regexp{regexp.MustCompile(`^team-`)},
+				labeled: labeled,
+			},
+			expected: map[string]bool{
+				"preview-1":     false,
+				"team-payments": true,
+				"team-search":   false,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			for namespace, expected := range tt.expected {
+				if actual := tt.filter.Matches(namespace); expected != actual {
+					t.Errorf("%s: expected %v, but actual %v", namespace, expected, actual)
+				}
+			}
+		})
+	}
+}
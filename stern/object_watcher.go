@@ -0,0 +1,142 @@
+package stern
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+// objectWatcher watches cluster-scoped objects such as nodes and namespaces
+// matching a label selector, and holds their labels. It notifies the
+// subscribers when an object joins or leaves.
+type objectWatcher struct {
+	notifier
+
+	kind string // the kind of the objects for logging e.g. "Node"
+
+	mu      sync.RWMutex
+	objects map[string]map[string]string // labels keyed by the object name
+}
+
+// newNodeWatcher lists the nodes matching the selector and starts watching them
+func newNodeWatcher(ctx context.Context, client kubernetes.Interface, selector labels.Selector, metrics *Metrics) (*objectWatcher, error) {
+	i := client.CoreV1().Nodes()
+	return newObjectWatcher(ctx, "Node", selector, metrics,
+		func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) { return i.List(ctx, opts) },
+		i.Watch,
+	)
+}
+
+// newNamespaceWatcher lists the namespaces matching the selector and starts watching them
+func newNamespaceWatcher(ctx context.Context, client kubernetes.Interface, selector labels.Selector, metrics *Metrics) (*objectWatcher, error) {
+	i := client.CoreV1().Namespaces()
+	return newObjectWatcher(ctx, "Namespace", selector, metrics,
+		func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) { return i.List(ctx, opts) },
+		i.Watch,
+	)
+}
+
+// newObjectWatcher lists the objects and starts watching them. The reconnects
+// of the watch are counted by metrics if not nil.
+func newObjectWatcher(ctx context.Context, kind string, selector labels.Selector, metrics *Metrics,
+	listFunc func(context.Context, metav1.ListOptions) (runtime.Object, error),
+	watchFunc func(context.Context, metav1.ListOptions) (watch.Interface, error),
+) (*objectWatcher, error) {
+	list, err := listFunc(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list %s", kind)
+	}
+	listMeta, err := meta.ListAccessor(list)
+	if err != nil {
+		return nil, err
+	}
+	w := &objectWatcher{kind: kind, objects: make(map[string]map[string]string)}
+	err = meta.EachListItem(list, func(o runtime.Object) error {
+		m, err := meta.Accessor(o)
+		if err != nil {
+			return err
+		}
+		w.objects[m.GetName()] = m.GetLabels()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = retryWatch(ctx, strings.ToLower(kind)+"s", listMeta.GetResourceVersion(), metrics, func(options metav1.ListOptions) (watch.Interface, error) {
+		options.LabelSelector = selector.String()
+		return watchFunc(ctx, options)
+	}, func(e watch.Event) {
+		m, err := meta.Accessor(e.Object)
+		if err != nil {
+			return
+		}
+		switch e.Type {
+		case watch.Added, watch.Modified:
+			w.set(m.GetName(), m.GetLabels())
+		case watch.Deleted:
+			w.delete(m.GetName())
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *objectWatcher) set(name string, objectLabels map[string]string) {
+	w.mu.Lock()
+	_, found := w.objects[name]
+	w.objects[name] = objectLabels
+	w.mu.Unlock()
+	if !found {
+		klog.V(7).InfoS("Object joined", "kind", w.kind, "name", name)
+		w.notify()
+	}
+}
+
+func (w *objectWatcher) delete(name string) {
+	w.mu.Lock()
+	_, found := w.objects[name]
+	delete(w.objects, name)
+	w.mu.Unlock()
+	if found {
+		klog.V(7).InfoS("Object left", "kind", w.kind, "name", name)
+		w.notify()
+	}
+}
+
+// Has returns if the object matches the selector
+func (w *objectWatcher) Has(name string) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	_, found := w.objects[name]
+	return found
+}
+
+// Labels returns the labels of the object with the keys
+func (w *objectWatcher) Labels(name string, keys []string) map[string]string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	objectLabels := w.objects[name]
+	if len(objectLabels) == 0 || len(keys) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(keys))
+	for _, key := range keys {
+		if v, ok := objectLabels[key]; ok {
+			m[key] = v
+		}
+	}
+	return m
+}
@@ -0,0 +1,93 @@
+package stern
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestObjectWatcher(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	genNode := func(name, zone string) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            name,
+				ResourceVersion: "1",
+				Labels: map[string]string{
+					"kubernetes.io/hostname":      name,
+					"topology.kubernetes.io/zone": zone,
+				},
+			},
+		}
+	}
+
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &corev1.NodeList{
+			ListMeta: metav1.ListMeta{ResourceVersion: "1"},
+			Items:    []corev1.Node{*genNode("node1", "a")},
+		}, nil
+	})
+	fw := watch.NewFake()
+	client.PrependWatchReactor("nodes", k8stesting.DefaultWatchReactor(fw, nil))
+
+	w, err := newNodeWatcher(ctx, client, labels.Everything(), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	notified := w.subscribe()
+
+	if !w.Has("node1") {
+		t.Errorf("expected node1 to be listed")
+	}
+	if w.Has("node2") {
+		t.Errorf("expected node2 not to be listed")
+	}
+	expected := map[string]string{"topology.kubernetes.io/zone": "a"}
+	if actual := w.Labels("node1", []string{"topology.kubernetes.io/zone", "unknown"}); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %v, but actual %v", expected, actual)
+	}
+	if actual := w.Labels("node1", nil); actual != nil {
+		t.Errorf("expected nil without keys, but actual %v", actual)
+	}
+
+	waitNotified := func() {
+		select {
+		case <-notified:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out")
+		}
+	}
+
+	fw.Add(genNode("node2", "b"))
+	waitNotified()
+	if !w.Has("node2") {
+		t.Errorf("expected node2 to join")
+	}
+
+	fw.Delete(genNode("node1", "a"))
+	waitNotified()
+	if w.Has("node1") {
+		t.Errorf("expected node1 to leave")
+	}
+
+	// a change of the labels is not notified
+	fw.Modify(genNode("node2", "c"))
+	fw.Add(genNode("node3", "c"))
+	waitNotified()
+	expected = map[string]string{"topology.kubernetes.io/zone": "c"}
+	if actual := w.Labels("node2", []string{"topology.kubernetes.io/zone"}); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %v, but actual %v", expected, actual)
+	}
+}
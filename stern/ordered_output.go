@@ -0,0 +1,325 @@
+package stern
+
+import (
+	"container/heap"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+)
+
+// maxOrderedRecords is the maximum number of records held to bound the
+// memory when many lines come at once. The oldest records are written when
+// it is exceeded even if older ones might come later.
+const maxOrderedRecords = 10000
+
+// orderedRecord is an output of a tail stamped with the timestamp of the log
+// line. seq breaks ties and keeps the order of the writes of a source.
+type orderedRecord struct {
+	timestamp time.Time
+	seq       uint64
+	deadline  time.Time // the time to be released in the follow mode
+	data      []byte
+}
+
+func (r *orderedRecord) before(o *orderedRecord) bool {
+	if r.timestamp.Equal(o.timestamp) {
+		return r.seq < o.seq
+	}
+	return r.timestamp.Before(o.timestamp)
+}
+
+// orderedRecords is a min-heap of the records by the timestamp
+type orderedRecords []*orderedRecord
+
+func (h orderedRecords) Len() int           { return len(h) }
+func (h orderedRecords) Less(i, j int) bool { return h[i].before(h[j]) }
+func (h orderedRecords) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *orderedRecords) Push(x any)        { *h = append(*h, x.(*orderedRecord)) }
+func (h *orderedRecords) Pop() any {
+	old := *h
+	r := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return r
+}
+
+// OrderedWriter merges the outputs of the tails by the timestamps of the log
+// lines. In the follow mode, a record is held for the reorder window after
+// it is written and released with the records older than it. Otherwise, the
+// records of the sources are merged. Once all the runs have opened the
+// sources of their targets, the oldest record is written when every open
+// source has passed its timestamp, i.e. has a record or has consumed a line
+// as new as it, or has ended. The rest are written when the writer is closed.
+//
+// Run creates one when Config.Ordered unless Config.Out is an OrderedWriter,
+// which is shared by the runs of multiple contexts.
+type OrderedWriter struct {
+	out    io.Writer
+	errOut io.Writer
+	window time.Duration
+	follow bool
+
+	mu       sync.Mutex
+	seq      uint64
+	records  orderedRecords
+	arrivals []*orderedRecord // the records in the written order to find expired ones
+	sources  []*orderedSource // the sources to merge unless follow
+	pending  int              // the number of the runs that have not opened the sources of all their targets
+	held     int              // the number of the records held by the sources
+	warned   bool             // the records have been written out of order because of maxOrderedRecords
+	closed   bool
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewOrderedWriter returns a writer merging the records of the tails of the
+// runs into out, which is usually an OutputWriter. It warns errOut when the
+// records are written out of order to bound the memory.
+func NewOrderedWriter(out, errOut io.Writer, window time.Duration, follow bool, runs int) *OrderedWriter {
+	w := &OrderedWriter{
+		out:     out,
+		errOut:  errOut,
+		window:  window,
+		follow:  follow,
+		pending: runs,
+		done:    make(chan struct{}),
+	}
+	if follow {
+		w.wg.Add(1)
+		go w.run()
+	}
+	return w
+}
+
+// source returns a writer for a tail
+func (w *OrderedWriter) source() *orderedSource {
+	s := &orderedSource{w: w}
+	if !w.follow {
+		w.mu.Lock()
+		w.sources = append(w.sources, s)
+		w.mu.Unlock()
+	}
+	return s
+}
+
+// listed tells that a run has opened the sources of all its targets. No
+// record is written by the timestamps until all the runs call it, because
+// the sources opened later might write older records.
+func (w *OrderedWriter) listed() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.pending = max(w.pending-1, 0)
+	if !w.follow {
+		w.releaseLocked(false)
+	}
+}
+
+func (w *OrderedWriter) run() {
+	defer w.wg.Done()
+	interval := max(w.window/4, 10*time.Millisecond)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.mu.Lock()
+			w.releaseExpiredLocked(time.Now())
+			w.mu.Unlock()
+		case <-w.done:
+			return
+		}
+	}
+}
+
+func (w *OrderedWriter) pushLocked(r *orderedRecord) {
+	r.deadline = time.Now().Add(w.window)
+	heap.Push(&w.records, r)
+	w.arrivals = append(w.arrivals, r)
+	for w.records.Len() > maxOrderedRecords {
+		w.warnLocked()
+		w.writeLocked(heap.Pop(&w.records).(*orderedRecord))
+	}
+}
+
+// releaseExpiredLocked writes the records whose window has passed, and the
+// records older than them
+func (w *OrderedWriter) releaseExpiredLocked(now time.Time) {
+	var threshold *orderedRecord
+	i := 0
+	for ; i < len(w.arrivals) && !w.arrivals[i].deadline.After(now); i++ {
+		if threshold == nil || threshold.before(w.arrivals[i]) {
+			threshold = w.arrivals[i]
+		}
+		w.arrivals[i] = nil
+	}
+	w.arrivals = w.arrivals[i:]
+	if threshold == nil {
+		return
+	}
+	for w.records.Len() > 0 && !threshold.before(w.records[0]) {
+		w.writeLocked(heap.Pop(&w.records).(*orderedRecord))
+	}
+}
+
+// releaseLocked writes the records of the sources in the order of the
+// timestamps unless follow. The records newer than the timestamps of the
+// open sources are held unless force or too many records are held.
+func (w *OrderedWriter) releaseLocked(force bool) {
+	var watermark *time.Time
+	if !force && w.pending == 0 {
+		for _, s := range w.sources {
+			if !s.closed && (watermark == nil || s.timestamp.Before(*watermark)) {
+				watermark = &s.timestamp
+			}
+		}
+	}
+	for {
+		var oldest *orderedSource
+		for _, s := range w.sources {
+			if len(s.records) > 0 && (oldest == nil || s.records[0].before(oldest.records[0])) {
+				oldest = s
+			}
+		}
+		if oldest == nil {
+			break
+		}
+		r := oldest.records[0]
+		if !force && (w.pending != 0 || (watermark != nil && r.timestamp.After(*watermark))) {
+			if w.held <= maxOrderedRecords {
+				break
+			}
+			w.warnLocked()
+		}
+		w.writeLocked(r)
+		oldest.records[0] = nil
+		oldest.records = oldest.records[1:]
+		w.held--
+	}
+	// the sources that never write again are dropped
+	w.sources = slices.DeleteFunc(w.sources, func(s *orderedSource) bool {
+		return s.closed && len(s.records) == 0
+	})
+}
+
+// warnLocked tells once that the records are written before older ones
+// might come
+func (w *OrderedWriter) warnLocked() {
+	if w.warned {
+		return
+	}
+	w.warned = true
+	fmt.Fprintf(w.errOut, "more than %d log lines are held to order them, so the oldest ones are printed and the order is not guaranteed\n", maxOrderedRecords)
+}
+
+func (w *OrderedWriter) writeLocked(r *orderedRecord) {
+	_, _ = w.out.Write(r.data)
+}
+
+// Write writes the record to out without merging it, as it has no timestamp
+func (w *OrderedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.out.Write(p)
+}
+
+// Close writes all the records held. It can be called more than once.
+func (w *OrderedWriter) Close() {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+	w.closed = true
+	w.mu.Unlock()
+
+	close(w.done)
+	w.wg.Wait()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.follow {
+		for w.records.Len() > 0 {
+			w.writeLocked(heap.Pop(&w.records).(*orderedRecord))
+		}
+		w.arrivals = nil
+		return
+	}
+
+	// k-way merge of the sources, each of which is sorted by the timestamp
+	w.releaseLocked(true)
+	w.sources = nil
+}
+
+// orderedSource is the writer of a tail. Each write is a record stamped with
+// the latest timestamp of the log lines of the tail, so the records of a
+// source are always sorted.
+type orderedSource struct {
+	w         *OrderedWriter
+	timestamp time.Time
+	records   []*orderedRecord // the records to merge unless follow
+	closed    bool             // the tail has ended, so the source never writes again
+}
+
+// advance updates the timestamp of the following writes. The timestamp never
+// goes back so that the records of the source keep their order.
+func (s *orderedSource) advance(rfc3339Nano string) {
+	if s == nil {
+		return
+	}
+	ts, err := time.Parse(time.RFC3339Nano, rfc3339Nano)
+	if err != nil {
+		return
+	}
+	s.w.mu.Lock()
+	defer s.w.mu.Unlock()
+
+	if ts.After(s.timestamp) {
+		s.timestamp = ts
+		if !s.w.follow {
+			s.w.releaseLocked(false)
+		}
+	}
+}
+
+// close tells that the tail has ended, so the records of the other sources
+// are no longer held for this source
+func (s *orderedSource) close() {
+	if s == nil {
+		return
+	}
+	s.w.mu.Lock()
+	defer s.w.mu.Unlock()
+
+	s.closed = true
+	if !s.w.follow {
+		s.w.releaseLocked(false)
+	}
+}
+
+func (s *orderedSource) Write(p []byte) (int, error) {
+	s.w.mu.Lock()
+	defer s.w.mu.Unlock()
+
+	s.w.seq++
+	r := &orderedRecord{
+		timestamp: s.timestamp,
+		seq:       s.w.seq,
+		data:      append([]byte(nil), p...),
+	}
+	if s.w.follow {
+		s.w.pushLocked(r)
+	} else {
+		s.records = append(s.records, r)
+		s.w.held++
+		s.w.releaseLocked(false)
+	}
+	return len(p), nil
+}
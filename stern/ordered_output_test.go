@@ -0,0 +1,248 @@
+package stern
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestOrderedWriterMerge(t *testing.T) {
+	tmpl := template.Must(template.New("").Parse(`{{.PodName}} {{.Message}}` + "\n"))
+	logs := map[string]string{
+		"api": `2023-02-13T21:20:30.000000001Z request received
+2023-02-13T21:20:30.000000005Z request done`,
+		"auth": `2023-02-13T21:20:30.000000002Z token checked
+2023-02-13T21:20:30.000000004Z token refreshed`,
+		"db": `2023-02-13T21:20:30.000000003Z query executed
+2023-02-13T21:20:31Z idle`,
+	}
+
+	out := new(bytes.Buffer)
+	w := NewOrderedWriter(out, io.Discard, 0, false, 1)
+	clientset := fake.NewSimpleClientset()
+	for _, name := range []string{"db", "api", "auth"} {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "my-namespace", Name: name}}
+		tail := NewTail(clientset.CoreV1(), pod, "my-container", tmpl, w.source(), io.Discard, &TailOptions{}, false)
+		if err := tail.ConsumeRequest(context.TODO(), &responseWrapperMock{data: bytes.NewBufferString(logs[name])}); err != nil {
+			t.Fatalf("unexpected err %v", err)
+		}
+	}
+	if out.Len() != 0 {
+		t.Fatalf("expected no output before closed, but got `%s`", out)
+	}
+	w.Close()
+
+	expected := `api request received
+auth token checked
+db query executed
+auth token refreshed
+api request done
+db idle
+`
+	if out.String() != expected {
+		t.Errorf("expected `%s`, but actual `%s`", expected, out)
+	}
+}
+
+func TestOrderedWriterReorderWindow(t *testing.T) {
+	out := new(bytes.Buffer)
+	// the records are released manually without the goroutine
+	w := &OrderedWriter{out: out, window: time.Minute, follow: true, done: make(chan struct{})}
+	a, b := w.source(), w.source()
+
+	write := func(s *orderedSource, ts, msg string) {
+		s.advance(ts)
+		if _, err := s.Write([]byte(msg + "\n")); err != nil {
+			t.Fatalf("unexpected err %v", err)
+		}
+	}
+	write(a, "2023-02-13T21:20:30.000000003Z", "a3")
+	write(a, "2023-02-13T21:20:30.000000005Z", "a5")
+	write(b, "2023-02-13T21:20:30.000000001Z", "b1")
+	write(b, "2023-02-13T21:20:30.000000004Z", "b4")
+	// the timestamp of a source never goes back
+	write(b, "2023-02-13T21:20:30.000000002Z", "b2")
+
+	w.releaseExpiredLocked(time.Now())
+	if out.Len() != 0 {
+		t.Fatalf("expected no output within the window, but got `%s`", out)
+	}
+
+	// a3 expires, which releases b1 older than it
+	w.arrivals[0].deadline = time.Now()
+	w.releaseExpiredLocked(time.Now())
+	if expected := "b1\na3\n"; out.String() != expected {
+		t.Fatalf("expected `%s`, but actual `%s`", expected, out)
+	}
+
+	w.Close()
+	if expected := "b1\na3\nb4\nb2\na5\n"; out.String() != expected {
+		t.Errorf("expected `%s`, but actual `%s`", expected, out)
+	}
+}
+
+func TestOrderedWriterWatermark(t *testing.T) {
+	out := new(bytes.Buffer)
+	w := NewOrderedWriter(out, io.Discard, 0, false, 1)
+	write := func(s *orderedSource, ts, msg string) {
+		s.advance(ts)
+		if _, err := s.Write([]byte(msg + "\n")); err != nil {
+			t.Fatalf("unexpected err %v", err)
+		}
+	}
+
+	a, b, c := w.source(), w.source(), w.source()
+	write(a, "2023-02-13T21:20:30.000000001Z", "a1")
+	write(b, "2023-02-13T21:20:30.000000002Z", "b2")
+	write(a, "2023-02-13T21:20:30.000000004Z", "a4")
+	if out.Len() != 0 {
+		t.Fatalf("expected no output before all the sources are opened, but got `%s`", out)
+	}
+
+	// c has not started yet, so it might still print older lines
+	w.listed()
+	if out.Len() != 0 {
+		t.Fatalf("expected no output before c starts, but got `%s`", out)
+	}
+
+	// a4 is held until b passes it
+	write(c, "2023-02-13T21:20:30.000000003Z", "c3")
+	if expected := "a1\nb2\n"; out.String() != expected {
+		t.Fatalf("expected `%s`, but actual `%s`", expected, out)
+	}
+
+	// b ends, which leaves c as the oldest source
+	b.close()
+	if expected := "a1\nb2\nc3\n"; out.String() != expected {
+		t.Fatalf("expected `%s`, but actual `%s`", expected, out)
+	}
+
+	w.Close()
+	if expected := "a1\nb2\nc3\na4\n"; out.String() != expected {
+		t.Errorf("expected `%s`, but actual `%s`", expected, out)
+	}
+}
+
+func TestOrderedWriterMaxRecords(t *testing.T) {
+	out, errOut := new(bytes.Buffer), new(bytes.Buffer)
+	w := NewOrderedWriter(out, errOut, 0, false, 1)
+	s := w.source()
+	for range maxOrderedRecords + 1 {
+		if _, err := s.Write([]byte("x\n")); err != nil {
+			t.Fatalf("unexpected err %v", err)
+		}
+	}
+	// the oldest record is written to bound the memory
+	if expected := "x\n"; out.String() != expected {
+		t.Errorf("expected `%s`, but actual `%s`", expected, out)
+	}
+	if w.held != maxOrderedRecords {
+		t.Errorf("expected %d records held, but actual %d", maxOrderedRecords, w.held)
+	}
+	if !strings.Contains(errOut.String(), "the order is not guaranteed") {
+		t.Errorf("expected a warning, but actual `%s`", errOut)
+	}
+}
+
+func TestRunOrdered(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines int // the lines of each pod
+		warn  bool
+	}{
+		{"merged", 3, false},
+		{"too many lines", maxOrderedRecords / 2, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset()
+			// the pods are told apart by the names of their containers in
+			// the log requests
+			var containers []string
+			for i := range 4 {
+				name := fmt.Sprintf("pod%d", i)
+				containers = append(containers, fmt.Sprintf("container%d", i))
+				_, err := clientset.CoreV1().Pods("my-namespace").Create(context.TODO(), &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "my-namespace", Name: name, UID: types.UID(name)},
+					Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+						Name:        containers[i],
+						ContainerID: "containerd://" + name,
+						State:       corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+					}}},
+				}, metav1.CreateOptions{})
+				if err != nil {
+					t.Fatalf("unexpected err %v", err)
+				}
+			}
+			// the lines of the pods interleave
+			base := time.Date(2023, 2, 13, 21, 20, 30, 0, time.UTC)
+			clientset.PrependReactor("get", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				if action.GetSubresource() != "log" {
+					return false, nil, nil
+				}
+				options := action.(k8stesting.GenericAction).GetValue().(*corev1.PodLogOptions)
+				i := slices.Index(containers, options.Container)
+				var logs strings.Builder
+				for j := range tt.lines {
+					ts := base.Add(time.Duration(j*len(containers)+i) * time.Millisecond)
+					fmt.Fprintf(&logs, "%s %d\n", ts.Format(time.RFC3339Nano), j*len(containers)+i)
+				}
+				return true, &runtime.Unknown{Raw: []byte(logs.String())}, nil
+			})
+
+			out, errOut := new(bytes.Buffer), new(bytes.Buffer)
+			err := Run(context.TODO(), clientset, &Config{
+				Namespaces:      []string{"my-namespace"},
+				PodQuery:        regexp.MustCompile(""),
+				ContainerQuery:  regexp.MustCompile(""),
+				ContainerStates: []ContainerState{RUNNING},
+				LabelSelector:   labels.Everything(),
+				FieldSelector:   fields.Everything(),
+				Template:        template.Must(template.New("").Parse(`{{.Message}}` + "\n")),
+				MaxLogRequests:  2,
+				Ordered:         true,
+				Out:             out,
+				ErrOut:          errOut,
+			})
+			if err != nil {
+				t.Fatalf("unexpected err %v", err)
+			}
+
+			var numbers []int
+			for line := range strings.Lines(out.String()) {
+				n, err := strconv.Atoi(strings.TrimSpace(line))
+				if err != nil {
+					t.Fatalf("unexpected line %q", line)
+				}
+				numbers = append(numbers, n)
+			}
+			if len(numbers) != tt.lines*len(containers) {
+				t.Errorf("expected %d lines, but actual %d", tt.lines*len(containers), len(numbers))
+			}
+			warned := strings.Contains(errOut.String(), "the order is not guaranteed")
+			if warned != tt.warn {
+				t.Errorf("expected warned %v, but actual %v: %s", tt.warn, warned, errOut)
+			}
+			if !tt.warn && !slices.IsSorted(numbers) {
+				t.Errorf("expected the lines in order, but actual %v", numbers)
+			}
+		})
+	}
+}
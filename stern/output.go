@@ -0,0 +1,140 @@
+package stern
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// OverflowPolicy decides what to do with the records of the tails when the
+// output cannot keep up with them
+type OverflowPolicy string
+
+const (
+	// OverflowBlock blocks the tails until the output catches up
+	OverflowBlock OverflowPolicy = "block"
+	// OverflowDrop drops the records and reports the number of them
+	OverflowDrop OverflowPolicy = "drop"
+)
+
+// NewOverflowPolicy returns the policy of the name
+func NewOverflowPolicy(name string) (OverflowPolicy, error) {
+	switch p := OverflowPolicy(name); p {
+	case OverflowBlock, OverflowDrop:
+		return p, nil
+	}
+	return "", fmt.Errorf("overflow policy should be one of '%s', '%s'", OverflowBlock, OverflowDrop)
+}
+
+// errOutputClosed is returned by the writes after the output writer is closed
+var errOutputClosed = errors.New("output writer is closed")
+
+// OutputWriter serializes the records written by the tails into the output.
+// Each write is a record, which is written to the output at once in a single
+// goroutine, so the records of different containers never interleave. The
+// records are buffered up to the size, and the writes block or the records
+// are dropped depending on the policy when the buffer is full.
+//
+// Run creates one unless Config.Out is an OutputWriter, which is shared by
+// the runs of multiple contexts.
+type OutputWriter struct {
+	out    io.Writer
+	errOut io.Writer
+	policy OverflowPolicy
+
+	// mu is held by the writes while queuing the records, and by Close to
+	// stop the writes
+	mu      sync.RWMutex
+	closed  bool
+	records chan []byte
+	dropped atomic.Int64
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewOutputWriter returns a writer of the records into out, which reports the
+// dropped records to errOut
+func NewOutputWriter(out, errOut io.Writer, size int, policy OverflowPolicy) *OutputWriter {
+	w := &OutputWriter{
+		out:     out,
+		errOut:  errOut,
+		policy:  policy,
+		records: make(chan []byte, max(size, 1)),
+		done:    make(chan struct{}),
+	}
+	w.wg.Add(1)
+	go w.run()
+	return w
+}
+
+func (w *OutputWriter) run() {
+	defer w.wg.Done()
+	for {
+		select {
+		case r := <-w.records:
+			w.write(r)
+		case <-w.done:
+			// drain the records written before closed
+			for {
+				select {
+				case r := <-w.records:
+					w.write(r)
+				default:
+					w.reportDropped()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (w *OutputWriter) write(r []byte) {
+	_, _ = w.out.Write(r)
+	w.reportDropped()
+}
+
+// reportDropped reports the number of the records dropped since the last
+// report if any
+func (w *OutputWriter) reportDropped() {
+	if n := w.dropped.Swap(0); n > 0 {
+		fmt.Fprintf(w.errOut, "dropped %d log lines because the output could not keep up\n", n)
+	}
+}
+
+// Write queues the record. It fails only when the writer is closed.
+func (w *OutputWriter) Write(p []byte) (int, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.closed {
+		return 0, errOutputClosed
+	}
+	r := append([]byte(nil), p...)
+	if w.policy == OverflowDrop {
+		select {
+		case w.records <- r:
+		default:
+			w.dropped.Add(1)
+		}
+		return len(p), nil
+	}
+	w.records <- r
+	return len(p), nil
+}
+
+// Close writes the queued records and stops the writer. The writes after it
+// fail. It can be called more than once.
+func (w *OutputWriter) Close() {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+	w.closed = true
+	close(w.done)
+	w.mu.Unlock()
+
+	w.wg.Wait()
+}
@@ -0,0 +1,143 @@
+package stern
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordWriter remembers each write as a record, and blocks the writes while
+// the gate is closed
+type recordWriter struct {
+	gate    chan struct{}
+	mu      sync.Mutex
+	records []string
+}
+
+func (w *recordWriter) Write(p []byte) (int, error) {
+	if w.gate != nil {
+		<-w.gate
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.records = append(w.records, string(p))
+	return len(p), nil
+}
+
+func TestOutputWriterAtomicRecords(t *testing.T) {
+	out := &recordWriter{}
+	w := NewOutputWriter(out, new(bytes.Buffer), 4, OverflowBlock)
+
+	var wg sync.WaitGroup
+	for pod := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 100 {
+				fmt.Fprintf(w, "{\n  \"pod\": \"pod-%d\",\n  \"line\": %d\n}\n", pod, i)
+			}
+		}()
+	}
+	wg.Wait()
+	w.Close()
+
+	if len(out.records) != 800 {
+		t.Fatalf("expected 800 records, but got %d", len(out.records))
+	}
+	next := map[string]int{}
+	for _, r := range out.records {
+		var pod string
+		var line int
+		if _, err := fmt.Sscanf(r, "{\n  \"pod\": %q,\n  \"line\": %d\n}\n", &pod, &line); err != nil {
+			t.Fatalf("torn record `%s`: %v", r, err)
+		}
+		if line != next[pod] {
+			t.Fatalf("expected line %d of %s, but got %d", next[pod], pod, line)
+		}
+		next[pod]++
+	}
+}
+
+func TestOutputWriterOverflow(t *testing.T) {
+	tests := []struct {
+		policy          OverflowPolicy
+		expectedRecords int
+		expectedErrOut  string
+	}{
+		{
+			policy:          OverflowBlock,
+			expectedRecords: 10,
+		},
+		{
+			// one record is being written and two are buffered
+			policy:          OverflowDrop,
+			expectedRecords: 3,
+			expectedErrOut:  "dropped 7 log lines because the output could not keep up\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			out := &recordWriter{gate: make(chan struct{})}
+			errOut := new(bytes.Buffer)
+			w := NewOutputWriter(out, errOut, 2, tt.policy)
+
+			written := make(chan struct{})
+			go func() {
+				defer close(written)
+				for i := range 10 {
+					fmt.Fprintf(w, "line %d\n", i)
+					if i == 0 {
+						// wait for the first record to be taken
+						for len(w.records) > 0 {
+							time.Sleep(time.Millisecond)
+						}
+					}
+				}
+			}()
+
+			select {
+			case <-written:
+				if tt.policy == OverflowBlock {
+					t.Fatal("expected the writes to block")
+				}
+			case <-time.After(100 * time.Millisecond):
+				if tt.policy == OverflowDrop {
+					t.Fatal("expected the writes not to block")
+				}
+			}
+
+			close(out.gate)
+			<-written
+			w.Close()
+
+			if len(out.records) != tt.expectedRecords {
+				t.Errorf("expected %d records, but got %d: %s", tt.expectedRecords, len(out.records), strings.Join(out.records, ""))
+			}
+			if errOut.String() != tt.expectedErrOut {
+				t.Errorf("expected `%s`, but actual `%s`", tt.expectedErrOut, errOut)
+			}
+		})
+	}
+}
+
+func TestOutputWriterClosed(t *testing.T) {
+	out := &recordWriter{}
+	w := NewOutputWriter(out, new(bytes.Buffer), 4, OverflowBlock)
+	if _, err := fmt.Fprint(w, "line 1\n"); err != nil {
+		t.Fatalf("unexpected err %v", err)
+	}
+	w.Close()
+	w.Close()
+
+	if _, err := fmt.Fprint(w, "line 2\n"); err != errOutputClosed {
+		t.Errorf("expected %v, but actual %v", errOutputClosed, err)
+	}
+	if expected := []string{"line 1\n"}; !reflect.DeepEqual(expected, out.records) {
+		t.Errorf("expected %q, but actual %q", expected, out.records)
+	}
+}
@@ -0,0 +1,126 @@
+package stern
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/lru"
+)
+
+// maxOwnerDepth is the maximum number of ownerReferences to follow from a pod
+// e.g. Pod -> ReplicaSet -> Deployment, Pod -> Job -> CronJob
+const maxOwnerDepth = 4
+
+// maxCachedControllers is the maximum number of the intermediate owners whose
+// controllers are cached. ReplicaSets and Jobs are created by every rollout
+// and every run of a CronJob, so the least recently used ones are evicted.
+const maxCachedControllers = 1024
+
+var (
+	replicaSetKind = appsv1.SchemeGroupVersion.WithKind("ReplicaSet").GroupKind()
+	jobKind        = batchv1.SchemeGroupVersion.WithKind("Job").GroupKind()
+)
+
+// ownerKind returns the group and kind of the resource that can own pods
+func ownerKind(kind string) (schema.GroupKind, bool) {
+	switch {
+	case ReplicationControllerMatcher.Matches(kind):
+		return corev1.SchemeGroupVersion.WithKind("ReplicationController").GroupKind(), true
+	case DaemonSetMatcher.Matches(kind):
+		return appsv1.SchemeGroupVersion.WithKind("DaemonSet").GroupKind(), true
+	case DeploymentMatcher.Matches(kind):
+		return appsv1.SchemeGroupVersion.WithKind("Deployment").GroupKind(), true
+	case ReplicaSetMatcher.Matches(kind):
+		return replicaSetKind, true
+	case StatefulSetMatcher.Matches(kind):
+		return appsv1.SchemeGroupVersion.WithKind("StatefulSet").GroupKind(), true
+	case JobMatcher.Matches(kind):
+		return jobKind, true
+	case CronJobMatcher.Matches(kind):
+		return batchv1.SchemeGroupVersion.WithKind("CronJob").GroupKind(), true
+	}
+	return schema.GroupKind{}, false
+}
+
+// refGroupKind returns the group and kind of the owner reference
+func refGroupKind(ref *metav1.OwnerReference) schema.GroupKind {
+	return schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind).GroupKind()
+}
+
+// ownerMatcher matches pods that are owned by the specified resource
+// by following the controller ownerReferences of the pods.
+type ownerMatcher struct {
+	client kubernetes.Interface
+	kind   schema.GroupKind // the kind of the owner e.g. "CronJob.batch"
+	name   string           // the name of the owner
+
+	// controllers caches the controller of the intermediate owners
+	// keyed by "<namespace>/<kind>/<name>". The cache is safe for
+	// concurrent use.
+	controllers *lru.Cache
+}
+
+func newOwnerMatcher(client kubernetes.Interface, kind schema.GroupKind, name string) *ownerMatcher {
+	return &ownerMatcher{
+		client:      client,
+		kind:        kind,
+		name:        name,
+		controllers: lru.New(maxCachedControllers),
+	}
+}
+
+// Matches returns if the pod is owned by the resource directly or indirectly
+func (m *ownerMatcher) Matches(ctx context.Context, pod *corev1.Pod) bool {
+	ref := metav1.GetControllerOf(pod)
+	for depth := 0; ref != nil && depth < maxOwnerDepth; depth++ {
+		if refGroupKind(ref) == m.kind && ref.Name == m.name {
+			return true
+		}
+		controller, err := m.controllerOf(ctx, pod.Namespace, ref)
+		if err != nil {
+			klog.V(7).InfoS("Failed to retrieve the owner", "namespace", pod.Namespace,
+				"kind", ref.Kind, "name", ref.Name, "pod", pod.Name, "err", err)
+			return false
+		}
+		ref = controller
+	}
+	return false
+}
+
+// controllerOf returns the controller of the owner, which can be nil
+func (m *ownerMatcher) controllerOf(ctx context.Context, namespace string, owner *metav1.OwnerReference) (*metav1.OwnerReference, error) {
+	kind := refGroupKind(owner)
+	key := namespace + "/" + kind.String() + "/" + owner.Name
+	if v, ok := m.controllers.Get(key); ok {
+		return v.(*metav1.OwnerReference), nil
+	}
+
+	var controller *metav1.OwnerReference
+	opt := metav1.GetOptions{}
+	switch kind {
+	case replicaSetKind:
+		o, err := m.client.AppsV1().ReplicaSets(namespace).Get(ctx, owner.Name, opt)
+		if err != nil {
+			return nil, err
+		}
+		controller = metav1.GetControllerOf(o)
+	case jobKind:
+		o, err := m.client.BatchV1().Jobs(namespace).Get(ctx, owner.Name, opt)
+		if err != nil {
+			return nil, err
+		}
+		controller = metav1.GetControllerOf(o)
+	default:
+		// We do not follow the owners that are never owned by the supported resources.
+		controller = nil
+	}
+
+	m.controllers.Add(key, controller)
+	return controller, nil
+}
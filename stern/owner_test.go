@@ -0,0 +1,211 @@
+package stern
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+)
+
+func TestOwnerMatcherCacheEviction(t *testing.T) {
+	var objs []runtime.Object
+	for i := range maxCachedControllers + 10 {
+		objs = append(objs, &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: fmt.Sprintf("rs%d", i)}})
+	}
+	client := fake.NewSimpleClientset(objs...)
+	kind, _ := ownerKind("deployment")
+	m := newOwnerMatcher(client, kind, "deploy1")
+	for i := range maxCachedControllers + 10 {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns1",
+			Name:      fmt.Sprintf("pod%d", i),
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: fmt.Sprintf("rs%d", i), Controller: ptr.To(true)},
+			},
+		}}
+		m.Matches(context.Background(), pod)
+	}
+	if m.controllers.Len() != maxCachedControllers {
+		t.Errorf("expected %d cached controllers, but actual %d", maxCachedControllers, m.controllers.Len())
+	}
+}
+
+func TestOwnerMatcherMatches(t *testing.T) {
+	apiVersions := map[string]string{
+		"Deployment":  "apps/v1",
+		"ReplicaSet":  "apps/v1",
+		"StatefulSet": "apps/v1",
+		"DaemonSet":   "apps/v1",
+		"Job":         "batch/v1",
+		"CronJob":     "batch/v1",
+	}
+	genOwnerRef := func(kind, name string) []metav1.OwnerReference {
+		return []metav1.OwnerReference{
+			{APIVersion: apiVersions[kind], Kind: kind, Name: name, Controller: ptr.To(true)},
+		}
+	}
+	objs := []runtime.Object{
+		&appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "deploy1-abc",
+				Namespace:       "ns1",
+				OwnerReferences: genOwnerRef("Deployment", "deploy1"),
+			},
+		},
+		&appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "canary-abc",
+				Namespace:       "ns1",
+				OwnerReferences: genOwnerRef("Deployment", "canary"),
+			},
+		},
+		&batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "cj1-001",
+				Namespace:       "ns1",
+				OwnerReferences: genOwnerRef("CronJob", "cj1"),
+			},
+		},
+		&batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "cj2-001",
+				Namespace:       "ns1",
+				OwnerReferences: genOwnerRef("CronJob", "cj2"),
+			},
+		},
+		&batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "job1",
+				Namespace: "ns1",
+			},
+		},
+	}
+	client := fake.NewSimpleClientset(objs...)
+	genPod := func(owners []metav1.OwnerReference) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "pod1",
+				Namespace:       "ns1",
+				OwnerReferences: owners,
+			},
+		}
+	}
+
+	tests := []struct {
+		desc     string
+		kind     string
+		name     string
+		pod      *corev1.Pod
+		expected bool
+	}{
+		{
+			desc:     "owned by a replicaset of the deployment",
+			kind:     "Deployment",
+			name:     "deploy1",
+			pod:      genPod(genOwnerRef("ReplicaSet", "deploy1-abc")),
+			expected: true,
+		},
+		{
+			desc:     "owned by a replicaset of another deployment",
+			kind:     "Deployment",
+			name:     "deploy1",
+			pod:      genPod(genOwnerRef("ReplicaSet", "canary-abc")),
+			expected: false,
+		},
+		{
+			desc:     "owned by the replicaset directly",
+			kind:     "ReplicaSet",
+			name:     "deploy1-abc",
+			pod:      genPod(genOwnerRef("ReplicaSet", "deploy1-abc")),
+			expected: true,
+		},
+		{
+			desc:     "owned by the statefulset directly",
+			kind:     "StatefulSet",
+			name:     "sts1",
+			pod:      genPod(genOwnerRef("StatefulSet", "sts1")),
+			expected: true,
+		},
+		{
+			desc:     "owned by another kind with the same name",
+			kind:     "StatefulSet",
+			name:     "sts1",
+			pod:      genPod(genOwnerRef("DaemonSet", "sts1")),
+			expected: false,
+		},
+		{
+			desc: "owned by a custom resource of the same kind in another group",
+			kind: "StatefulSet",
+			name: "sts1",
+			pod: genPod([]metav1.OwnerReference{
+				{APIVersion: "apps.kruise.io/v1beta1", Kind: "StatefulSet", Name: "sts1", Controller: ptr.To(true)},
+			}),
+			expected: false,
+		},
+		{
+			desc:     "owned by a job of the cronjob",
+			kind:     "CronJob",
+			name:     "cj1",
+			pod:      genPod(genOwnerRef("Job", "cj1-001")),
+			expected: true,
+		},
+		{
+			desc:     "owned by a job of another cronjob",
+			kind:     "CronJob",
+			name:     "cj1",
+			pod:      genPod(genOwnerRef("Job", "cj2-001")),
+			expected: false,
+		},
+		{
+			desc:     "owned by a job without owners",
+			kind:     "CronJob",
+			name:     "cj1",
+			pod:      genPod(genOwnerRef("Job", "job1")),
+			expected: false,
+		},
+		{
+			desc:     "owned by a job that does not exist",
+			kind:     "CronJob",
+			name:     "cj1",
+			pod:      genPod(genOwnerRef("Job", "not-found")),
+			expected: false,
+		},
+		{
+			desc: "not owned by a controller",
+			kind: "CronJob",
+			name: "cj1",
+			pod: genPod([]metav1.OwnerReference{
+				{APIVersion: "batch/v1", Kind: "Job", Name: "cj1-001"},
+			}),
+			expected: false,
+		},
+		{
+			desc:     "no owners",
+			kind:     "CronJob",
+			name:     "cj1",
+			pod:      genPod(nil),
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			kind, _ := ownerKind(strings.ToLower(tt.kind))
+			m := newOwnerMatcher(client, kind, tt.name)
+			// check twice to test the cache
+			for i := 0; i < 2; i++ {
+				if actual := m.Matches(context.Background(), tt.pod); tt.expected != actual {
+					t.Errorf("%d: expected %v, but actual %v", i, tt.expected, actual)
+				}
+			}
+		})
+	}
+}
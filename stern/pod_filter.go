@@ -0,0 +1,103 @@
+package stern
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/jsonpath"
+	"k8s.io/klog/v2"
+)
+
+// podFilterFields are the shorthands of the fields for PodFilter
+var podFilterFields = map[string][]string{
+	"phase":          {"{.status.phase}"},
+	"image":          {"{.spec.initContainers[*].image}", "{.spec.containers[*].image}"},
+	"serviceAccount": {"{.spec.serviceAccountName}"},
+	"qosClass":       {"{.status.qosClass}"},
+}
+
+// podFilterOperators are the operators of PodFilter. Longer ones come first
+// because they are matched in order.
+var podFilterOperators = []string{"==", "!=", "=~", "!~", "<=", ">=", "=", "<", ">"}
+
+var podFilterRegexp = regexp.MustCompile(`^\s*(\{[^}]*\}|[A-Za-z]+)\s*(` + strings.Join(podFilterOperators, "|") + `)\s*(.*?)\s*$`)
+
+// PodFilter is a client-side predicate on a field of the pod object
+type PodFilter struct {
+	valuePredicate
+	expr  string
+	paths []*jsonpath.JSONPath
+}
+
+// NewPodFilter returns a PodFilter for the expression in the form of
+// "<field><operator><value>". The field is a JSONPath expression such as
+// "{.spec.priority}" or one of the shorthands: phase, image, serviceAccount
+// and qosClass. The operator is one of =, ==, !=, =~, !~, <, <=, > and >=.
+func NewPodFilter(expr string) (*PodFilter, error) {
+	m := podFilterRegexp.FindStringSubmatch(expr)
+	if m == nil {
+		return nil, fmt.Errorf("pod filter %q should be in the form of '<field><operator><value>' with one of the operators '%s'",
+			expr, strings.Join(podFilterOperators, "', '"))
+	}
+	field, operator, value := m[1], m[2], m[3]
+
+	templates := []string{field}
+	if !strings.HasPrefix(field, "{") {
+		var ok bool
+		templates, ok = podFilterFields[field]
+		if !ok {
+			return nil, fmt.Errorf("pod filter %q has an unknown field %q: it should be a JSONPath expression like '{.status.phase}' or one of 'phase', 'image', 'serviceAccount', 'qosClass'", expr, field)
+		}
+	}
+
+	predicate, err := newValuePredicate(operator, value)
+	if err != nil {
+		return nil, fmt.Errorf("pod filter %q: %w", expr, err)
+	}
+	f := &PodFilter{valuePredicate: predicate, expr: expr}
+	for _, tmpl := range templates {
+		p := jsonpath.New(expr).AllowMissingKeys(true)
+		if err := p.Parse(tmpl); err != nil {
+			return nil, fmt.Errorf("pod filter %q has an invalid JSONPath expression: %w", expr, err)
+		}
+		f.paths = append(f.paths, p)
+	}
+	return f, nil
+}
+
+// String returns the expression of the filter
+func (f *PodFilter) String() string {
+	return f.expr
+}
+
+// Matches returns if the pod matches the filter. The pod must be converted
+// into the unstructured object by toUnstructuredPod.
+//
+// When the field has multiple values such as images of containers, the
+// positive operators match if any of the values matches, and != and !~
+// match if none of the values matches.
+func (f *PodFilter) Matches(pod map[string]any) bool {
+	var values []string
+	for _, p := range f.paths {
+		results, err := p.FindResults(pod)
+		if err != nil {
+			klog.V(7).InfoS("Failed to evaluate the pod filter", "filter", f.expr, "err", err)
+			return false
+		}
+		for _, r := range results {
+			for _, v := range r {
+				values = append(values, fmt.Sprint(v.Interface()))
+			}
+		}
+	}
+	return f.matches(values)
+}
+
+// toUnstructuredPod converts the pod to evaluate JSONPath expressions by
+// the field names in JSON
+func toUnstructuredPod(pod *corev1.Pod) (map[string]any, error) {
+	return runtime.DefaultUnstructuredConverter.ToUnstructured(pod)
+}
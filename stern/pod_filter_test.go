@@ -0,0 +1,107 @@
+package stern
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestNewPodFilter(t *testing.T) {
+	tests := []struct {
+		expr    string
+		isError bool
+	}{
+		{"phase=Running", false},
+		{"phase == Running", false},
+		{"image=~^nginx:", false},
+		{"serviceAccount!=default", false},
+		{"qosClass!~Best.*", false},
+		{"{.spec.priority}>=1000", false},
+		{`{.metadata.annotations.example\.com/team}=payments`, false},
+		{"phase", true},
+		{"unknown=value", true},
+		{"{.spec.priority}>high", true},
+		{"image=~(", true},
+		{"{.spec[}=value", true},
+	}
+
+	for _, tt := range tests {
+		_, err := NewPodFilter(tt.expr)
+		if tt.isError && err == nil {
+			t.Errorf("%s: expected error, but got no error", tt.expr)
+		}
+		if !tt.isError && err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.expr, err)
+		}
+	}
+}
+
+func TestPodFilterMatches(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "pod1",
+			Annotations: map[string]string{"example.com/team": "payments"},
+		},
+		Spec: corev1.PodSpec{
+			ServiceAccountName: "api",
+			Priority:           ptr.To[int32](1000),
+			InitContainers: []corev1.Container{
+				{Name: "init", Image: "busybox:1.36"},
+			},
+			Containers: []corev1.Container{
+				{Name: "app", Image: "example.com/api:v2"},
+				{Name: "proxy", Image: "envoyproxy/envoy:v1.29"},
+			},
+		},
+		Status: corev1.PodStatus{
+			Phase:    corev1.PodRunning,
+			QOSClass: corev1.PodQOSBurstable,
+		},
+	}
+	obj, err := toUnstructuredPod(pod)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		expr     string
+		expected bool
+	}{
+		{"phase=Running", true},
+		{"phase==Pending", false},
+		{"phase!=Pending", true},
+		{"serviceAccount=api", true},
+		{"qosClass=Burstable", true},
+		{"qosClass!~^Best", true},
+		{"image=~^envoyproxy/", true},
+		{"image=~^busybox:", true},
+		{"image=~^nginx:", false},
+		{"image!~^nginx:", true},
+		{"image!~envoy", false},
+		{"image=example.com/api:v2", true},
+		{"image!=example.com/api:v2", false},
+		{"{.spec.priority}>=1000", true},
+		{"{.spec.priority}>1000", false},
+		{"{.spec.priority}<1000.5", true},
+		{"{.spec.containers[*].name}=proxy", true},
+		{`{.metadata.annotations.example\.com/team}=payments`, true},
+		{`{.metadata.annotations.example\.com/team}!=payments`, false},
+		// missing fields
+		{"{.spec.hostname}=host", false},
+		{"{.spec.hostname}!=host", true},
+		{"{.spec.missing.field}>0", false},
+	}
+
+	for _, tt := range tests {
+		f, err := NewPodFilter(tt.expr)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.expr, err)
+			continue
+		}
+		if actual := f.Matches(obj); tt.expected != actual {
+			t.Errorf("%s: expected %v, but actual %v", tt.expr, tt.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package stern
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+)
+
+// valuePredicate compares values of a field with the operator. It is shared
+// by PodFilter and FieldFilter.
+type valuePredicate struct {
+	operator string
+	value    string
+	values   []string       // for in and not in
+	re       *regexp.Regexp // for =~ and !~
+	number   float64        // for <, <=, > and >=
+}
+
+func newValuePredicate(operator string, values ...string) (valuePredicate, error) {
+	p := valuePredicate{operator: operator, values: values}
+	if len(values) > 0 {
+		p.value = values[0]
+	}
+	switch operator {
+	case "=~", "!~":
+		re, err := regexp.Compile(p.value)
+		if err != nil {
+			return p, fmt.Errorf("invalid regular expression: %w", err)
+		}
+		p.re = re
+	case "<", "<=", ">", ">=":
+		n, err := strconv.ParseFloat(p.value, 64)
+		if err != nil {
+			return p, fmt.Errorf("the operator %s requires a number", operator)
+		}
+		p.number = n
+	}
+	return p, nil
+}
+
+// negative returns if the operator is satisfied only when none of the values
+// matches. Negative operators also match missing fields.
+func (p valuePredicate) negative() bool {
+	switch p.operator {
+	case "!=", "!~", "not in":
+		return true
+	}
+	return false
+}
+
+// matches returns if the values of a field match the predicate. The positive
+// operators match if any of the values matches, and the negative ones match
+// if none of the values matches.
+func (p valuePredicate) matches(values []string) bool {
+	if p.negative() {
+		for _, v := range values {
+			if !p.match(v) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, v := range values {
+		if p.match(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// match returns if the value matches. For the negative operators, it returns
+// if the value does not match the positive counterpart.
+func (p valuePredicate) match(v string) bool {
+	switch p.operator {
+	case "=", "==":
+		return v == p.value
+	case "!=":
+		return v != p.value
+	case "=~":
+		return p.re.MatchString(v)
+	case "!~":
+		return !p.re.MatchString(v)
+	case "in":
+		return slices.Contains(p.values, v)
+	case "not in":
+		return !slices.Contains(p.values, v)
+	}
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return false
+	}
+	switch p.operator {
+	case "<":
+		return n < p.number
+	case "<=":
+		return n <= p.number
+	case ">":
+		return n > p.number
+	case ">=":
+		return n >= p.number
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package stern
+
+import (
+	"context"
+	"sync"
+)
+
+// lastTails remembers the last tail of each target, so that the logs of the
+// previous container that have not been printed can be fetched after the
+// container restarts
+type lastTails struct {
+	mu sync.Mutex
+	m  map[string]*lastTail
+}
+
+// lastTail is the tail of a target. cancel stops the tail, done is closed
+// when the tail ends, and resumeRequest is the position of the last printed
+// line after that.
+type lastTail struct {
+	cancel        context.CancelFunc
+	done          chan struct{}
+	resumeRequest *ResumeRequest
+}
+
+func newLastTails() *lastTails {
+	return &lastTails{m: make(map[string]*lastTail)}
+}
+
+// replace registers a new tail of the target stopped by cancel and returns
+// the last one, which is nil if the target has not been tailed
+func (l *lastTails) replace(id string, cancel context.CancelFunc) (current, last *lastTail) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	current = &lastTail{cancel: cancel, done: make(chan struct{})}
+	last = l.m[id]
+	l.m[id] = current
+	return current, last
+}
+
+// forget forgets the last tail of the target that is no longer tailed
+func (l *lastTails) forget(id string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.m, id)
+}
+
+// end forgets the tail of the target that is no longer tailed unless a newer
+// tail has replaced it
+func (l *lastTails) end(id string, t *lastTail) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.m[id] == t {
+		delete(l.m, id)
+	}
+}
+
+// finish records the position of the last printed line and marks the tail as
+// ended
+func (t *lastTail) finish(resumeRequest *ResumeRequest) {
+	t.resumeRequest = resumeRequest
+	close(t.done)
+}
+
+// stop stops the tail and waits for it to end, so that the position of the
+// last printed line is fixed. It returns false if ctx is done before that.
+func (t *lastTail) stop(ctx context.Context) bool {
+	t.cancel()
+	select {
+	case <-t.done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,77 @@
+package stern
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestLastTails(t *testing.T) {
+	tails := newLastTails()
+
+	first, last := tails.replace("ns-pod-c1", func() {})
+	if last != nil {
+		t.Fatalf("expected no last tail, but got %v", last)
+	}
+	resumeRequest := &ResumeRequest{Timestamp: "2023-02-13T21:20:30Z", LinesToSkip: 2}
+	first.finish(resumeRequest)
+
+	second, last := tails.replace("ns-pod-c1", func() {})
+	if last != first {
+		t.Fatalf("expected the first tail, but got %v", last)
+	}
+	select {
+	case <-last.done:
+	default:
+		t.Fatal("expected the first tail to be done")
+	}
+	if !reflect.DeepEqual(resumeRequest, last.resumeRequest) {
+		t.Errorf("expected %v, but actual %v", resumeRequest, last.resumeRequest)
+	}
+	select {
+	case <-second.done:
+		t.Fatal("expected the second tail not to be done")
+	default:
+	}
+
+	tails.forget("ns-pod-c1")
+	third, last := tails.replace("ns-pod-c1", func() {})
+	if last != nil {
+		t.Errorf("expected the forgotten tail not to be returned, but got %v", last)
+	}
+
+	// the ended tail does not forget the newer one
+	tails.end("ns-pod-c1", second)
+	if _, last := tails.replace("ns-pod-c1", func() {}); last != third {
+		t.Errorf("expected the third tail, but got %v", last)
+	}
+}
+
+func TestLastTailStop(t *testing.T) {
+	tails := newLastTails()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	first, _ := tails.replace("ns-pod-c1", cancel)
+	resumeRequest := &ResumeRequest{Timestamp: "2023-02-13T21:20:30Z", LinesToSkip: 1}
+	// the tail ends when it is canceled
+	go func() {
+		<-ctx.Done()
+		first.finish(resumeRequest)
+	}()
+
+	_, last := tails.replace("ns-pod-c1", func() {})
+	if !last.stop(context.Background()) {
+		t.Fatal("expected the last tail to be stopped")
+	}
+	if !reflect.DeepEqual(resumeRequest, last.resumeRequest) {
+		t.Errorf("expected %v, but actual %v", resumeRequest, last.resumeRequest)
+	}
+
+	// stop gives up when the context is done
+	stuck, _ := tails.replace("ns-pod-c2", func() {})
+	done, cancelDone := context.WithCancel(context.Background())
+	cancelDone()
+	if stuck.stop(done) {
+		t.Error("expected stop to give up")
+	}
+}
@@ -0,0 +1,111 @@
+package stern
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// RedactReplacement is the default replacement of redacted strings
+const RedactReplacement = "[REDACTED]"
+
+// RedactRule replaces the strings matching the regular expression in log
+// messages
+type RedactRule struct {
+	re          *regexp.Regexp
+	replacement string
+	// validate decides if the matched string should be replaced when not nil
+	validate func(string) bool
+}
+
+// NewRedactRule returns a rule for the expression in the form of "<regex>" or
+// "<regex>=><replacement>". The replacement may refer to the submatches like
+// "${1}" and defaults to RedactReplacement.
+func NewRedactRule(expr string) (*RedactRule, error) {
+	pattern, replacement := expr, RedactReplacement
+	if i := strings.LastIndex(expr, "=>"); i >= 0 {
+		pattern, replacement = expr[:i], expr[i+2:]
+	}
+	if pattern == "" {
+		return nil, fmt.Errorf("redaction rule %q has an empty regular expression", expr)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("redaction rule %q has an invalid regular expression: %w", expr, err)
+	}
+	return &RedactRule{re: re, replacement: replacement}, nil
+}
+
+// Redact returns the message with the matching strings replaced
+func (r *RedactRule) Redact(msg string) string {
+	if r.validate == nil {
+		return r.re.ReplaceAllString(msg, r.replacement)
+	}
+	return r.re.ReplaceAllStringFunc(msg, func(s string) string {
+		if !r.validate(s) {
+			return s
+		}
+		return r.re.ReplaceAllString(s, r.replacement)
+	})
+}
+
+var redactPresets = map[string][]*RedactRule{
+	"jwt": {
+		{re: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`), replacement: RedactReplacement},
+	},
+	"aws-key": {
+		{re: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`), replacement: RedactReplacement},
+		{re: regexp.MustCompile(`(?i)(aws_?secret_?(?:access_?)?key["']?\s*[:=]\s*["']?)[A-Za-z0-9/+=]{40}`), replacement: "${1}" + RedactReplacement},
+	},
+	"authorization": {
+		{re: regexp.MustCompile(`(?i)(\b(?:proxy-)?authorization["']?\s*[:=]\s*["']?(?:(?:bearer|basic|digest|token)\s+)?)[^\s"',;]+`), replacement: "${1}" + RedactReplacement},
+	},
+	"email": {
+		{re: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`), replacement: RedactReplacement},
+	},
+	"card": {
+		{re: regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`), replacement: RedactReplacement, validate: luhnValid},
+	},
+}
+
+// RedactPresetNames returns the names of the redaction presets
+func RedactPresetNames() []string {
+	names := make([]string, 0, len(redactPresets))
+	for name := range redactPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RedactPreset returns the rules of the preset
+func RedactPreset(name string) ([]*RedactRule, error) {
+	rules, ok := redactPresets[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown redaction preset %q: it should be one of %s", name, strings.Join(RedactPresetNames(), ", "))
+	}
+	return rules, nil
+}
+
+// luhnValid returns if the digits in the string pass the Luhn check, which
+// card numbers satisfy
+func luhnValid(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n > 0 && sum%10 == 0
+}
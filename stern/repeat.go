@@ -0,0 +1,136 @@
+package stern
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// RepeatMode decides which messages are regarded as repeated
+type RepeatMode string
+
+const (
+	// RepeatOff does not collapse repeated messages
+	RepeatOff RepeatMode = ""
+	// RepeatExact collapses identical messages
+	RepeatExact RepeatMode = "exact"
+	// RepeatNormalized collapses messages that are identical after replacing
+	// timestamps, UUIDs and numbers
+	RepeatNormalized RepeatMode = "normalized"
+)
+
+// NewRepeatMode returns the mode of the name
+func NewRepeatMode(name string) (RepeatMode, error) {
+	switch m := RepeatMode(name); m {
+	case RepeatOff, RepeatExact, RepeatNormalized:
+		return m, nil
+	}
+	return RepeatOff, fmt.Errorf("repeat mode should be one of '%s', '%s'", RepeatExact, RepeatNormalized)
+}
+
+// repeatMessage returns the message reporting the suppressed messages
+func repeatMessage(count int) string {
+	if count == 1 {
+		return "last message repeated 1 time"
+	}
+	return fmt.Sprintf("last message repeated %d times", count)
+}
+
+// repeatNormalizers replace the variable parts of messages. Longer patterns
+// come first because the numbers in them would be replaced otherwise.
+var repeatNormalizers = []struct {
+	re          *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:?\d{2})?`), "<timestamp>"},
+	{regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`), "<uuid>"},
+	{regexp.MustCompile(`\d+(\.\d+)?`), "<number>"},
+}
+
+func normalizeRepeat(msg string) string {
+	for _, n := range repeatNormalizers {
+		msg = n.re.ReplaceAllString(msg, n.replacement)
+	}
+	return msg
+}
+
+// repeatCollapser suppresses the consecutive repeated messages of a
+// container. It reports the number of the suppressed messages when a
+// different message comes, when it is flushed, or when the window passes
+// since the first suppressed message.
+type repeatCollapser struct {
+	mode   RepeatMode
+	window time.Duration
+	print  func(msg, timestamp string, level Level)
+	report func(count int, timestamp string)
+
+	mu        sync.Mutex
+	last      string // the key of the last printed message
+	hasLast   bool
+	count     int    // the number of the suppressed messages
+	timestamp string // the timestamp of the last suppressed message
+	timer     *time.Timer
+}
+
+// newRepeatCollapser returns nil if repeated messages are not collapsed
+func newRepeatCollapser(options *TailOptions, print func(msg, timestamp string, level Level), report func(count int, timestamp string)) *repeatCollapser {
+	if options.RepeatMode == RepeatOff {
+		return nil
+	}
+	return &repeatCollapser{
+		mode:   options.RepeatMode,
+		window: options.RepeatWindow,
+		print:  print,
+		report: report,
+	}
+}
+
+// add prints the message unless it repeats the last one
+func (c *repeatCollapser) add(msg, timestamp string, level Level) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := msg
+	if c.mode == RepeatNormalized {
+		key = normalizeRepeat(msg)
+	}
+	if c.hasLast && key == c.last {
+		c.count++
+		c.timestamp = timestamp
+		if c.count == 1 && c.window > 0 {
+			if c.timer == nil {
+				c.timer = time.AfterFunc(c.window, c.flush)
+			} else {
+				c.timer.Reset(c.window)
+			}
+		}
+		return
+	}
+
+	c.flushLocked()
+	c.last, c.hasLast = key, true
+	c.print(msg, timestamp, level)
+}
+
+// flush reports the suppressed messages if any
+func (c *repeatCollapser) flush() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.flushLocked()
+}
+
+func (c *repeatCollapser) flushLocked() {
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	if c.count == 0 {
+		return
+	}
+	c.report(c.count, c.timestamp)
+	c.count = 0
+}
@@ -0,0 +1,164 @@
+package stern
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+	"text/template"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNormalizeRepeat(t *testing.T) {
+	tests := []struct {
+		msg      string
+		expected string
+	}{
+		{"GET /healthz 200 0.12ms", "GET /healthz <number> <number>ms"},
+		{"2024-01-01T12:00:00.123Z retry 3", "<timestamp> retry <number>"},
+		{"request 0f8fad5b-d9cb-469f-a165-70867728950e done", "request <uuid> done"},
+		{"no variables", "no variables"},
+	}
+
+	for _, tt := range tests {
+		if actual := normalizeRepeat(tt.msg); actual != tt.expected {
+			t.Errorf("%s: expected %q, but actual %q", tt.msg, tt.expected, actual)
+		}
+	}
+}
+
+func TestRepeatCollapser(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     RepeatMode
+		msgs     []string
+		expected []string
+	}{
+		{
+			name: "exact",
+			mode: RepeatExact,
+			msgs: []string{"a", "a", "a", "b", "a", "a", "c", "c"},
+			expected: []string{
+				"a", "repeated 2 (ts3)",
+				"b",
+				"a", "repeated 1 (ts6)",
+				"c", "repeated 1 (ts8)",
+			},
+		},
+		{
+			name:     "exact does not collapse different numbers",
+			mode:     RepeatExact,
+			msgs:     []string{"GET /healthz 1ms", "GET /healthz 2ms"},
+			expected: []string{"GET /healthz 1ms", "GET /healthz 2ms"},
+		},
+		{
+			name:     "normalized",
+			mode:     RepeatNormalized,
+			msgs:     []string{"GET /healthz 1ms", "GET /healthz 2ms", "GET /healthz 3ms", "GET /ready 1ms"},
+			expected: []string{"GET /healthz 1ms", "repeated 2 (ts3)", "GET /ready 1ms"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var actual []string
+			c := newRepeatCollapser(&TailOptions{RepeatMode: tt.mode},
+				func(msg, _ string, _ Level) { actual = append(actual, msg) },
+				func(count int, timestamp string) {
+					actual = append(actual, fmt.Sprintf("repeated %d (%s)", count, timestamp))
+				})
+			for i, msg := range tt.msgs {
+				c.add(msg, fmt.Sprintf("ts%d", i+1), LevelUnknown)
+			}
+			c.flush()
+
+			if !reflect.DeepEqual(tt.expected, actual) {
+				t.Errorf("expected %q, but actual %q", tt.expected, actual)
+			}
+		})
+	}
+
+	if c := newRepeatCollapser(&TailOptions{}, nil, nil); c != nil {
+		t.Errorf("expected nil without the repeat mode, but actual %v", c)
+	}
+}
+
+func TestRepeatCollapserWindow(t *testing.T) {
+	var mu sync.Mutex
+	var actual []string
+	reported := make(chan struct{}, 1)
+	c := newRepeatCollapser(&TailOptions{RepeatMode: RepeatExact, RepeatWindow: 10 * time.Millisecond},
+		func(msg, _ string, _ Level) {
+			mu.Lock()
+			defer mu.Unlock()
+			actual = append(actual, msg)
+		},
+		func(count int, _ string) {
+			mu.Lock()
+			defer mu.Unlock()
+			actual = append(actual, fmt.Sprintf("repeated %d", count))
+			reported <- struct{}{}
+		})
+
+	c.add("a", "", LevelUnknown)
+	c.add("a", "", LevelUnknown)
+	c.add("a", "", LevelUnknown)
+	select {
+	case <-reported:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out")
+	}
+
+	// the run continues after the window
+	c.add("a", "", LevelUnknown)
+	c.flush()
+	<-reported
+
+	mu.Lock()
+	defer mu.Unlock()
+	expected := []string{"a", "repeated 2", "repeated 1"}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %q, but actual %q", expected, actual)
+	}
+}
+
+func TestConsumeStreamTailRepeat(t *testing.T) {
+	logLines := `2023-02-13T21:20:30.000000001Z GET /healthz
+2023-02-13T21:20:31.000000001Z GET /healthz
+2023-02-13T21:20:32.000000001Z GET /healthz
+2023-02-13T21:20:33.000000001Z GET /api
+2023-02-13T21:20:34.000000001Z GET /healthz
+2023-02-13T21:20:35.000000001Z GET /healthz`
+	tmpl := template.Must(template.New("").Parse(`{{printf "%s %d %s\n" .Timestamp .RepeatCount .Message}}`))
+
+	options := &TailOptions{
+		Timestamps:      true,
+		TimestampFormat: TimestampFormatShort,
+		Location:        time.UTC,
+		RepeatMode:      RepeatExact,
+	}
+
+	out := new(bytes.Buffer)
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "my-namespace", Name: "my-pod"}}
+	tail := NewTail(fake.NewSimpleClientset().CoreV1(), pod, "my-container", tmpl, out, io.Discard, options, false)
+	if err := tail.ConsumeRequest(context.TODO(), &responseWrapperMock{data: bytes.NewBufferString(logLines)}); err != nil {
+		t.Fatalf("unexpected err %v", err)
+	}
+
+	expected := `02-13 21:20:30 0 GET /healthz
+02-13 21:20:32 2 last message repeated 2 times
+02-13 21:20:33 0 GET /api
+02-13 21:20:34 0 GET /healthz
+02-13 21:20:35 1 last message repeated 1 time
+`
+	if expected != out.String() {
+		t.Errorf("expected `%s`, but actual `%s`", expected, out)
+	}
+}
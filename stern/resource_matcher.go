@@ -49,6 +49,7 @@ var (
 	ReplicaSetMatcher            = ResourceMatcher{name: "replicaset", aliases: []string{"rs", "replicasets"}}
 	StatefulSetMatcher           = ResourceMatcher{name: "statefulset", aliases: []string{"sts", "statefulsets"}}
 	JobMatcher                   = ResourceMatcher{name: "job", aliases: []string{"jobs"}} // job does not have a short name
+	CronJobMatcher               = ResourceMatcher{name: "cronjob", aliases: []string{"cj", "cronjobs"}}
 	ResourceMatchers             = []ResourceMatcher{
 		PodMatcher,
 		ReplicationControllerMatcher,
@@ -58,5 +59,16 @@ var (
 		ReplicaSetMatcher,
 		StatefulSetMatcher,
 		JobMatcher,
+		CronJobMatcher,
 	}
 )
+
+// isBuiltinResource returns if the kind matches one of the ResourceMatchers
+func isBuiltinResource(kind string) bool {
+	for _, m := range ResourceMatchers {
+		if m.Matches(kind) {
+			return true
+		}
+	}
+	return false
+}
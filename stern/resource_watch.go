@@ -0,0 +1,80 @@
+package stern
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+// watchSelector watches the resource and sends the selector to select its pods
+// every time the resource is changed, e.g. when the pod template labels of a
+// Deployment or the selector of a Service are updated. It returns nil if the
+// query does not reference a resource that has a selector. The reconnects of
+// the watch are counted by metrics if not nil.
+func watchSelector(ctx context.Context, client kubernetes.Interface, dynResolver *dynamicResolver, namespace, kind, name string, selector labels.Selector, metrics *Metrics) <-chan labels.Selector {
+	if kind == "" || PodMatcher.Matches(kind) {
+		return nil
+	}
+	selectors := make(chan labels.Selector)
+	_, err := retryWatch(ctx, strings.ToLower(kind), "1", metrics, func(options metav1.ListOptions) (watch.Interface, error) {
+		return watchResource(ctx, client, dynResolver, namespace, kind, name)
+	}, func(e watch.Event) {
+		if e.Type != watch.Added && e.Type != watch.Modified {
+			return
+		}
+		s, err := chooseSelector(ctx, client, dynResolver, namespace, kind, name, selector)
+		if err != nil {
+			klog.V(7).InfoS("Failed to retrieve the selector", "namespace", namespace, "kind", kind, "name", name, "err", err)
+			return
+		}
+		select {
+		case selectors <- s:
+		case <-ctx.Done():
+		}
+	})
+	if err != nil {
+		klog.V(7).InfoS("Failed to watch the resource", "namespace", namespace, "kind", kind, "name", name, "err", err)
+		return nil
+	}
+	return selectors
+}
+
+// watchResource watches the resource of the kind with the name
+func watchResource(ctx context.Context, client kubernetes.Interface, dynResolver *dynamicResolver, namespace, kind, name string) (watch.Interface, error) {
+	opt := metav1.SingleObject(metav1.ObjectMeta{Name: name})
+	switch {
+	// core
+	case ReplicationControllerMatcher.Matches(kind):
+		return client.CoreV1().ReplicationControllers(namespace).Watch(ctx, opt)
+	case ServiceMatcher.Matches(kind):
+		return client.CoreV1().Services(namespace).Watch(ctx, opt)
+	// apps
+	case DaemonSetMatcher.Matches(kind):
+		return client.AppsV1().DaemonSets(namespace).Watch(ctx, opt)
+	case DeploymentMatcher.Matches(kind):
+		return client.AppsV1().Deployments(namespace).Watch(ctx, opt)
+	case ReplicaSetMatcher.Matches(kind):
+		return client.AppsV1().ReplicaSets(namespace).Watch(ctx, opt)
+	case StatefulSetMatcher.Matches(kind):
+		return client.AppsV1().StatefulSets(namespace).Watch(ctx, opt)
+	// batch
+	case JobMatcher.Matches(kind):
+		return client.BatchV1().Jobs(namespace).Watch(ctx, opt)
+	case CronJobMatcher.Matches(kind):
+		return client.BatchV1().CronJobs(namespace).Watch(ctx, opt)
+	}
+	if dynResolver != nil {
+		gvr, err := dynResolver.resourceFor(kind)
+		if err != nil {
+			return nil, err
+		}
+		return dynResolver.client.Resource(gvr).Namespace(namespace).Watch(ctx, opt)
+	}
+	return nil, fmt.Errorf("resource type %s is not supported", kind)
+}
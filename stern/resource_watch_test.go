@@ -0,0 +1,68 @@
+package stern
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestWatchSelector(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	genDeployment := func(version string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:       "ns1",
+				Name:            "deploy1",
+				ResourceVersion: version,
+			},
+			Spec: appsv1.DeploymentSpec{
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{
+						Labels: map[string]string{"app": "deploy1", "version": version},
+					},
+				},
+			},
+		}
+	}
+
+	client := fake.NewSimpleClientset(genDeployment("1"))
+	fw := watch.NewFake()
+	client.PrependWatchReactor("deployments", k8stesting.DefaultWatchReactor(fw, nil))
+
+	if s := watchSelector(ctx, client, nil, "ns1", "", "", labels.Everything(), nil); s != nil {
+		t.Errorf("expected nil for the pod query")
+	}
+	if s := watchSelector(ctx, client, nil, "ns1", "pod", "pod1", labels.Everything(), nil); s != nil {
+		t.Errorf("expected nil for the pod resource")
+	}
+
+	selectors := watchSelector(ctx, client, nil, "ns1", "deploy", "deploy1", labels.Everything(), nil)
+	if selectors == nil {
+		t.Fatal("expected a channel, but actual nil")
+	}
+
+	d := genDeployment("2")
+	if _, err := client.AppsV1().Deployments("ns1").Update(ctx, d, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	go fw.Modify(d)
+
+	select {
+	case s := <-selectors:
+		if expected := "app=deploy1,version=2"; s.String() != expected {
+			t.Errorf("expected %s, but actual %s", expected, s)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out")
+	}
+}
@@ -0,0 +1,50 @@
+package stern
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	watchtools "k8s.io/client-go/tools/watch"
+	"k8s.io/klog/v2"
+)
+
+// retryWatch watches the resource from the resource version and calls handle
+// with each event in a goroutine. The watch is made by a RetryWatcher, which
+// restarts the underlying watch from the last point when it is closed (e.g.
+// due to API timeout or etcd timeout) without the consumer even knowing about
+// it, and the restarts are counted as the reconnects of the resource by
+// metrics if not nil.
+//
+// The returned channel is closed when ctx is done, or when the RetryWatcher
+// gives up because of an error such as the expired resource version. The
+// callers keep the last known state in the latter case.
+func retryWatch(ctx context.Context, resource, resourceVersion string, metrics *Metrics, watchFunc func(options metav1.ListOptions) (watch.Interface, error), handle func(e watch.Event)) (<-chan struct{}, error) {
+	watcher, err := watchtools.NewRetryWatcherWithContext(ctx, resourceVersion, &cache.ListWatch{
+		WatchFunc: metrics.countReconnects(resource, watchFunc),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create a watcher for %s", resource)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer watcher.Stop()
+		for {
+			select {
+			case e := <-watcher.ResultChan():
+				if e.Object == nil {
+					klog.V(7).InfoS("Stopped watching", "resource", resource)
+					return
+				}
+				handle(e)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return done, nil
+}
@@ -0,0 +1,54 @@
+package stern
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func TestRetryWatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	metrics := NewMetrics()
+	watchers := make(chan *watch.FakeWatcher, 2)
+	events := make(chan watch.Event)
+	done, err := retryWatch(ctx, "pods", "1", metrics, func(options metav1.ListOptions) (watch.Interface, error) {
+		w := watch.NewFake()
+		watchers <- w
+		return w, nil
+	}, func(e watch.Event) {
+		events <- e
+	})
+	if err != nil {
+		t.Fatalf("unexpected err %v", err)
+	}
+
+	first := <-watchers
+	first.Add(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1", ResourceVersion: "2"}})
+	if e := <-events; e.Type != watch.Added || e.Object.(*corev1.Pod).Name != "pod1" {
+		t.Errorf("unexpected event %v", e)
+	}
+
+	// the closed watch is restarted and counted as a reconnect
+	first.Stop()
+	select {
+	case <-watchers:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the watch to be restarted")
+	}
+	if n := testutil.ToFloat64(metrics.watchReconnects.WithLabelValues("pods")); n != 1 {
+		t.Errorf("expected 1 reconnect, but actual %v", n)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the watch to end")
+	}
+}
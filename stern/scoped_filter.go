@@ -0,0 +1,121 @@
+package stern
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// FilterType is the type of the log line filter
+type FilterType string
+
+// The types of the filters corresponding to --exclude, --include and --highlight
+const (
+	FilterExclude   FilterType = "exclude"
+	FilterInclude   FilterType = "include"
+	FilterHighlight FilterType = "highlight"
+)
+
+// filterScopeKeys are the keys of the scope of the filters
+var filterScopeKeys = []string{"namespace", "pod", "container"}
+
+// filterScopeEntry is an entry of the scope such as "pod=api-.*". The value
+// is a regular expression where "," and ":" are escaped by a backslash,
+// which is still a valid regular expression.
+var filterScopeEntry = `(` + strings.Join(filterScopeKeys, "|") + `)=((?:[^\\:,]|\\.)+)`
+
+var (
+	// filterScopeRegexp matches the scope prefix such as
+	// "container=istio-proxy:" or "namespace=prod,pod=api-.*:"
+	filterScopeRegexp = regexp.MustCompile(`^(` + filterScopeEntry + `(?:,` + filterScopeEntry + `)*):`)
+	// filterScopeEntryRegexp matches each entry of the scope prefix
+	filterScopeEntryRegexp = regexp.MustCompile(filterScopeEntry)
+)
+
+// FilterScope restricts a filter to the matching containers. Each of the
+// regular expressions matches the whole name, and nil matches any name.
+type FilterScope struct {
+	Namespace *regexp.Regexp
+	Pod       *regexp.Regexp
+	Container *regexp.Regexp
+}
+
+// Matches returns if the container is in the scope
+func (s FilterScope) Matches(namespace, pod, container string) bool {
+	return matchScope(s.Namespace, namespace) && matchScope(s.Pod, pod) && matchScope(s.Container, container)
+}
+
+func matchScope(re *regexp.Regexp, name string) bool {
+	return re == nil || re.MatchString(name)
+}
+
+// ScopedFilter is a filter of log lines applied only to the containers in
+// the scope
+type ScopedFilter struct {
+	Type   FilterType
+	Scope  FilterScope
+	Regexp *regexp.Regexp
+	expr   string
+}
+
+// ParseScopedFilter parses the filter in the form of
+// "<key>=<value>[,<key>=<value>...]:<regex>" such as
+// "container=istio-proxy:GET /healthz". The key is one of namespace, pod and
+// container, and the value is a regular expression matching the whole name,
+// where "," and ":" are escaped by a backslash like "pod=a\,b".
+func ParseScopedFilter(filterType FilterType, expr string) (*ScopedFilter, error) {
+	m := filterScopeRegexp.FindStringSubmatch(expr)
+	if m == nil {
+		return nil, fmt.Errorf("%s filter %q should start with a scope like 'container=istio-proxy:'", filterType, expr)
+	}
+
+	f := &ScopedFilter{Type: filterType, expr: expr}
+	for _, kv := range filterScopeEntryRegexp.FindAllStringSubmatch(m[1], -1) {
+		key, value := kv[1], kv[2]
+		re, err := regexp.Compile("^(?:" + value + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("%s filter %q has an invalid regular expression for %s: %w", filterType, expr, key, err)
+		}
+		switch key {
+		case "namespace":
+			f.Scope.Namespace = re
+		case "pod":
+			f.Scope.Pod = re
+		case "container":
+			f.Scope.Container = re
+		}
+	}
+
+	re, err := regexp.Compile(expr[len(m[0]):])
+	if err != nil {
+		return nil, fmt.Errorf("%s filter %q has an invalid regular expression: %w", filterType, expr, err)
+	}
+	f.Regexp = re
+	return f, nil
+}
+
+// String returns the expression of the filter
+func (f *ScopedFilter) String() string {
+	return f.expr
+}
+
+// withScopedFilters returns the options with the filters in the scope of the
+// container added. The slices are clipped not to modify the ones shared by
+// the options of other containers.
+func (o *TailOptions) withScopedFilters(filters []*ScopedFilter, namespace, pod, container string) *TailOptions {
+	for _, f := range filters {
+		if !f.Scope.Matches(namespace, pod, container) {
+			continue
+		}
+		switch f.Type {
+		case FilterExclude:
+			o.Exclude = append(slices.Clip(o.Exclude), f.Regexp)
+		case FilterInclude:
+			o.Include = append(slices.Clip(o.Include), f.Regexp)
+		case FilterHighlight:
+			o.Highlight = append(slices.Clip(o.Highlight), f.Regexp)
+		}
+	}
+	return o
+}
@@ -0,0 +1,128 @@
+package stern
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestParseScopedFilter(t *testing.T) {
+	type target struct {
+		namespace, pod, container string
+	}
+
+	tests := []struct {
+		expr      string
+		isError   bool
+		regexp    string
+		matches   []target
+		unmatches []target
+	}{
+		{
+			expr:      "container=istio-proxy:GET /healthz",
+			regexp:    "GET /healthz",
+			matches:   []target{{"ns1", "pod1", "istio-proxy"}},
+			unmatches: []target{{"ns1", "pod1", "app"}, {"ns1", "pod1", "istio-proxy-init"}},
+		},
+		{
+			expr:      "namespace=prod,pod=api-.*:level=debug",
+			regexp:    "level=debug",
+			matches:   []target{{"prod", "api-1", "app"}, {"prod", "api-2", "sidecar"}},
+			unmatches: []target{{"dev", "api-1", "app"}, {"prod", "web-1", "app"}},
+		},
+		{
+			expr:    "pod=web|api:",
+			regexp:  "",
+			matches: []target{{"ns1", "web", "app"}, {"ns1", "api", "app"}},
+		},
+		{
+			expr:      `pod=a\,b|c\:d:error: refused`,
+			regexp:    "error: refused",
+			matches:   []target{{"ns1", "a,b", "app"}, {"ns1", "c:d", "app"}},
+			unmatches: []target{{"ns1", "a", "app"}},
+		},
+		{expr: "GET /healthz", isError: true},
+		{expr: "error: connection refused", isError: true},
+		{expr: "node=node1:GET", isError: true},
+		{expr: "container=(:GET", isError: true},
+		{expr: "container=app:(", isError: true},
+	}
+
+	for _, tt := range tests {
+		f, err := ParseScopedFilter(FilterExclude, tt.expr)
+		if tt.isError {
+			if err == nil {
+				t.Errorf("%s: expected error, but got no error", tt.expr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.expr, err)
+			continue
+		}
+		if f.Type != FilterExclude || f.Regexp.String() != tt.regexp {
+			t.Errorf("%s: expected %s %q, but actual %s %q", tt.expr, FilterExclude, tt.regexp, f.Type, f.Regexp)
+		}
+		for _, m := range tt.matches {
+			if !f.Scope.Matches(m.namespace, m.pod, m.container) {
+				t.Errorf("%s: expected to match %v", tt.expr, m)
+			}
+		}
+		for _, m := range tt.unmatches {
+			if f.Scope.Matches(m.namespace, m.pod, m.container) {
+				t.Errorf("%s: expected not to match %v", tt.expr, m)
+			}
+		}
+	}
+}
+
+func TestWithScopedFilters(t *testing.T) {
+	mustParse := func(filterType FilterType, expr string) *ScopedFilter {
+		f, err := ParseScopedFilter(filterType, expr)
+		if err != nil || f == nil {
+			t.Fatalf("%s: failed to parse: %v", expr, err)
+		}
+		return f
+	}
+	filters := []*ScopedFilter{
+		mustParse(FilterExclude, "container=istio-proxy:GET /healthz"),
+		mustParse(FilterInclude, "container=app:error"),
+		mustParse(FilterHighlight, "pod=api-.*:timeout"),
+	}
+
+	// the shared slice has spare capacity to detect writes to it
+	shared := make([]*regexp.Regexp, 1, 10)
+	shared[0] = regexp.MustCompile("common")
+	newOptions := func() *TailOptions {
+		return &TailOptions{Exclude: shared}
+	}
+
+	proxy := newOptions().withScopedFilters(filters, "ns1", "api-1", "istio-proxy")
+	app := newOptions().withScopedFilters(filters, "ns1", "web-1", "app")
+
+	toStrings := func(res []*regexp.Regexp) []string {
+		var ss []string
+		for _, re := range res {
+			ss = append(ss, re.String())
+		}
+		return ss
+	}
+	if actual := toStrings(proxy.Exclude); !reflect.DeepEqual([]string{"common", "GET /healthz"}, actual) {
+		t.Errorf("proxy exclude: actual %v", actual)
+	}
+	if actual := toStrings(proxy.Highlight); !reflect.DeepEqual([]string{"timeout"}, actual) {
+		t.Errorf("proxy highlight: actual %v", actual)
+	}
+	if len(proxy.Include) != 0 {
+		t.Errorf("proxy include: actual %v", proxy.Include)
+	}
+	if actual := toStrings(app.Exclude); !reflect.DeepEqual([]string{"common"}, actual) {
+		t.Errorf("app exclude: actual %v", actual)
+	}
+	if actual := toStrings(app.Include); !reflect.DeepEqual([]string{"error"}, actual) {
+		t.Errorf("app include: actual %v", actual)
+	}
+	if shared[:2][1] != nil {
+		t.Errorf("the shared slice was modified: %v", shared[:2])
+	}
+}
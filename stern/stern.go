@@ -17,6 +17,7 @@ package stern
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"regexp"
 	"strings"
@@ -31,6 +32,7 @@ import (
 	"golang.org/x/time/rate"
 
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
 	"k8s.io/utils/ptr"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -40,61 +42,129 @@ import (
 // Run starts the main run loop
 func Run(ctx context.Context, client kubernetes.Interface, config *Config) error {
 	var namespaces []string
+	hasNamespaceSelector := config.NamespaceSelector != nil && !config.NamespaceSelector.Empty()
+	// Namespaces matching the namespace query or selector are discovered
+	// dynamically, so we watch pods in all namespaces and filter them
+	dynamicNamespaces := len(config.NamespaceQuery) > 0 || hasNamespaceSelector
 	// A specific namespace is ignored if all-namespaces is provided
-	if config.AllNamespaces {
+	if config.AllNamespaces || dynamicNamespaces {
 		namespaces = []string{""}
 	} else {
-		namespaces = config.Namespaces
+		for _, n := range config.Namespaces {
+			if !matchAny(config.ExcludeNamespaceQuery, n) {
+				namespaces = append(namespaces, n)
+			}
+		}
 		if len(namespaces) == 0 {
 			return errors.New("no namespace specified")
 		}
 	}
 
+	// All the records are written to the output in a single goroutine, after
+	// they are merged by the ordered writer if any
+	out := config.Out
+	ordered, _ := out.(*OrderedWriter)
+	if ordered != nil {
+		out = ordered.out
+	}
+	output, ok := out.(*OutputWriter)
+	if !ok {
+		output = NewOutputWriter(out, config.ErrOut, config.OutputBufferSize, config.OutputOverflow)
+		defer output.Close()
+	}
+
 	newTailOptions := func() *TailOptions {
+		sinceSeconds := ptr.To[int64](int64(config.Since.Seconds()))
+		var sinceTime *metav1.Time
+		if config.SinceTime != nil {
+			// PodLogOptions accepts only one of SinceSeconds and SinceTime
+			sinceSeconds = nil
+			sinceTime = &metav1.Time{Time: *config.SinceTime}
+		}
 		return &TailOptions{
-			Timestamps:      config.Timestamps,
-			TimestampFormat: config.TimestampFormat,
-			Location:        config.Location,
-			SinceSeconds:    ptr.To[int64](int64(config.Since.Seconds())),
-			Exclude:         config.Exclude,
-			Include:         config.Include,
-			Highlight:       config.Highlight,
-			Namespace:       config.AllNamespaces || len(namespaces) > 1,
-			TailLines:       config.TailLines,
-			Follow:          config.Follow,
-			OnlyLogLines:    config.OnlyLogLines,
+			Timestamps:           config.Timestamps,
+			TimestampFormat:      config.TimestampFormat,
+			Location:             config.Location,
+			SinceSeconds:         sinceSeconds,
+			SinceTime:            sinceTime,
+			Until:                config.Until,
+			Exclude:              config.Exclude,
+			Include:              config.Include,
+			Highlight:            config.Highlight,
+			FieldFilters:         config.FieldFilters,
+			UnstructuredPolicy:   config.UnstructuredPolicy,
+			MinLevel:             config.MinLevel,
+			TemplateLevel:        templateRefersTo(config.Template, "Level"),
+			Multiline:            config.Multiline,
+			MultilineTimeout:     config.MultilineTimeout,
+			Redact:               config.Redact,
+			RepeatMode:           config.RepeatMode,
+			RepeatWindow:         config.RepeatWindow,
+			BeforeContext:        config.BeforeContext,
+			AfterContext:         config.AfterContext,
+			OmitContextSeparator: config.OmitContextSeparator,
+			Namespace:            config.AllNamespaces || dynamicNamespaces || len(namespaces) > 1,
+			Context:              config.Context,
+			TailLines:            config.TailLines,
+			Follow:               config.Follow,
+			OnlyLogLines:         config.OnlyLogLines,
+		}
+	}
+	var nodes *objectWatcher
+	// tailOutput returns the writer of a tail, which is a source of the
+	// ordered writer if any
+	tailOutput := func() io.Writer {
+		if ordered != nil {
+			return ordered.source()
 		}
+		return output
+	}
+	newTailTo := func(t *Target, out io.Writer) *Tail {
+		options := newTailOptions().withScopedFilters(config.ScopedFilters, t.Pod.Namespace, t.Pod.Name, t.Container)
+		if nodes != nil {
+			options.NodeLabels = nodes.Labels(t.Pod.Spec.NodeName, config.NodeLabels)
+		}
+		tail := NewTail(client.CoreV1(), t.Pod, t.Container, config.Template, out, config.ErrOut, options, config.DiffContainer)
+		tail.metrics = config.Metrics
+		return tail
 	}
 	newTail := func(t *Target) *Tail {
-		return NewTail(client.CoreV1(), t.Pod, t.Container, config.Template, config.Out, config.ErrOut, newTailOptions(), config.DiffContainer)
+		return newTailTo(t, tailOutput())
 	}
 
 	if config.Stdin {
-		tail := NewFileTail(config.Template, os.Stdin, config.Out, config.ErrOut, newTailOptions())
+		tail := NewFileTail(config.Template, os.Stdin, output, config.ErrOut, newTailOptions())
 		return tail.Start()
 	}
 
-	var resource struct {
-		kind string
-		name string
+	config.Metrics.setMaxLogRequests(config.MaxLogRequests)
+
+	if config.Ordered && ordered == nil {
+		// The lines are merged by their timestamps within the reorder window,
+		// or as all the tails pass them unless following
+		ordered = NewOrderedWriter(output, config.ErrOut, config.ReorderWindow, config.Follow, 1)
+		defer ordered.Close()
 	}
-	if config.Resource != "" {
-		parts := strings.Split(config.Resource, "/")
-		if len(parts) != 2 {
-			return errors.New("resource must be specified in the form \"<resource>/<name>\"")
+	// The other runs sharing the ordered writer are not held even if this
+	// run fails before listing its targets
+	listed := sync.OnceFunc(ordered.listed)
+	defer listed()
+
+	hasNodeSelector := config.NodeSelector != nil && !config.NodeSelector.Empty()
+	if hasNodeSelector || len(config.NodeLabels) > 0 {
+		nodeSelector := config.NodeSelector
+		if !hasNodeSelector {
+			nodeSelector = labels.Everything()
 		}
-		resource.kind, resource.name = parts[0], parts[1]
-		if PodMatcher.Matches(resource.kind) {
-			// Pods might have no labels or share the same labels,
-			// so we use an exact match instead.
-			podName, err := regexp.Compile("^" + resource.name + "$")
-			if err != nil {
-				return errors.Wrap(err, "failed to compile regular expression for pod")
-			}
-			config.PodQuery = podName
+		var err error
+		nodes, err = newNodeWatcher(ctx, client, nodeSelector, config.Metrics)
+		if err != nil {
+			return err
 		}
 	}
 
+	dynResolver := newDynamicResolver(config.DynamicClient, config.RESTMapper)
+
 	filter := newTargetFilter(targetFilterConfig{
 		podFilter:              config.PodQuery,
 		excludePodFilter:       config.ExcludePodQuery,
@@ -104,124 +174,361 @@ func Run(ctx context.Context, client kubernetes.Interface, config *Config) error
 		initContainers:         config.InitContainers,
 		ephemeralContainers:    config.EphemeralContainers,
 		containerStates:        config.ContainerStates,
+		annotationSelector:     config.AnnotationSelector,
+		podFilters:             config.PodFilters,
+		metrics:                config.Metrics,
 	})
+	if hasNodeSelector {
+		filter.c.nodes = nodes
+	}
+	if config.LifecycleEvents {
+		filter.c.lifecycle = newLifecyclePrinter(newTail)
+	}
+	var events *eventWatcher
+	if config.Events != EventsOff {
+		since := time.Now().Add(-config.Since)
+		if config.SinceTime != nil {
+			since = *config.SinceTime
+		}
+		events = newEventWatcher(config.Events, since, config.Until, newTail)
+		events.metrics = config.Metrics
+		filter.c.events = events
+	}
+
+	var nsFilter *namespaceFilter
+	if namespaces[0] == "" && (dynamicNamespaces || len(config.ExcludeNamespaceQuery) > 0) {
+		nsFilter = &namespaceFilter{
+			query:   config.NamespaceQuery,
+			exclude: config.ExcludeNamespaceQuery,
+		}
+		if hasNamespaceSelector {
+			var err error
+			nsFilter.labeled, err = newNamespaceWatcher(ctx, client, config.NamespaceSelector, config.Metrics)
+			if err != nil {
+				return err
+			}
+		}
+		filter.c.namespaces = nsFilter
+	}
+
+	var queries []*query
+	// The pod query is used unless only resources are specified
+	if len(config.Resources) == 0 || config.PodQuery.String() != "" {
+		queries = append(queries, &query{filter: filter})
+	}
+	for _, resource := range config.Resources {
+		q, err := newResourceQuery(client, dynResolver, filter, resource, config.OnlyOwned, config.ReadyEndpoints)
+		if err != nil {
+			return err
+		}
+		if q.endpoints != nil {
+			q.endpoints.metrics = config.Metrics
+		}
+		queries = append(queries, q)
+	}
 
 	if !config.Follow {
 		var eg errgroup.Group
 		eg.SetLimit(config.MaxLogRequests)
+		// All the targets are listed before tailing them, so that the ordered
+		// output holds the lines for the tails waiting for their turn
+		var allTargets []*Target
 		for _, n := range namespaces {
-			selector, err := chooseSelector(ctx, client, n, resource.kind, resource.name, config.LabelSelector)
-			if err != nil {
-				return err
-			}
-			targets, err := ListTargets(ctx,
-				client.CoreV1().Pods(n),
-				selector,
-				config.FieldSelector,
-				filter,
-			)
-			if err != nil {
-				return err
+			for _, q := range queries {
+				selector, err := chooseSelector(ctx, client, dynResolver, n, q.kind, q.name, config.LabelSelector)
+				if err != nil {
+					return err
+				}
+				if q.endpoints != nil {
+					// The endpoints are listed once as well as the pods
+					if _, err := q.endpoints.list(ctx, client, n); err != nil {
+						return err
+					}
+				}
+				targets, err := ListTargets(ctx,
+					client.CoreV1().Pods(n),
+					selector,
+					config.FieldSelector,
+					q.filter,
+				)
+				if err != nil {
+					return err
+				}
+				allTargets = append(allTargets, targets...)
 			}
-			for _, t := range targets {
-				t := t
-				eg.Go(func() error {
-					tail := newTail(t)
-					defer tail.Close()
-					return tail.Start(ctx)
-				})
+		}
+		if events != nil {
+			// The events of the listed pods are merged with their logs by the
+			// ordered output if any
+			for _, n := range namespaces {
+				if err := events.list(ctx, client, n); err != nil {
+					return err
+				}
 			}
+			events.end()
+		}
+		// The sources of the ordered output are opened before the tails
+		// start, as the tails beyond MaxLogRequests start later
+		outs := make([]io.Writer, len(allTargets))
+		for i := range allTargets {
+			outs[i] = tailOutput()
+		}
+		listed()
+		for i, t := range allTargets {
+			eg.Go(func() error {
+				tail := newTailTo(t, outs[i])
+				tail.stats = config.Summary.targetStats(config.Context, t)
+				defer tail.Close()
+				err := tail.Start(ctx)
+				tail.stats.fail(err)
+				return err
+			})
 		}
 		return eg.Wait()
 	}
 
+	var tails *lastTails
+	if config.PreviousOnRestart {
+		tails = newLastTails()
+	}
+	// tailPrevious prints the logs of the previous container that the last
+	// tail has not printed, such as the last lines before a crash. The last
+	// tail is stopped rather than waited for, since the previous logs include
+	// the rest of its stream.
+	tailPrevious := func(ctx context.Context, target *Target, last *lastTail) {
+		if !last.stop(ctx) {
+			return
+		}
+		tail := newTail(target)
+		tail.stats = config.Summary.targetStats(config.Context, target)
+		defer tail.Close()
+		if err := tail.ResumePrevious(ctx, last.resumeRequest); err != nil {
+			tail.stats.fail(err)
+			fmt.Fprintf(config.ErrOut, "failed to tail the previous container: %v\n", err)
+		}
+	}
+
 	tailTarget := func(ctx context.Context, target *Target) {
+		stats := config.Summary.targetStats(config.Context, target)
+		// The tail ends for good when the target is removed or it fails
+		// without retries
+		endsForGood := func() bool {
+			return ctx.Err() != nil || !filter.isActive(target)
+		}
+		defer func() {
+			if endsForGood() {
+				config.Metrics.forget(config.Context, target)
+			}
+		}()
+		// tailCtx is also canceled when the next tail of the target stops
+		// this one
+		tailCtx := ctx
+		var resumeRequest *ResumeRequest
+		if tails != nil {
+			var stop context.CancelFunc
+			tailCtx, stop = context.WithCancel(ctx)
+			defer stop()
+			current, last := tails.replace(target.GetID(), stop)
+			defer func() {
+				current.finish(resumeRequest)
+				if endsForGood() {
+					tails.end(target.GetID(), current)
+				}
+			}()
+			if target.restarted && last != nil {
+				tailPrevious(ctx, target, last)
+			}
+		}
+
 		// We use a rate limiter to prevent a burst of retries.
 		// It also enables us to retry immediately, in most cases,
 		// when it is disconnected on the way.
 		limiter := rate.NewLimiter(rate.Every(time.Second*20), 2)
-		var resumeRequest *ResumeRequest
 		for {
-			if err := limiter.Wait(ctx); err != nil {
-				fmt.Fprintf(config.ErrOut, "failed to retry: %v\n", err)
+			if err := limiter.Wait(tailCtx); err != nil {
+				// the tail stopped by the next one is not an error
+				if tailCtx.Err() == nil || ctx.Err() != nil {
+					fmt.Fprintf(config.ErrOut, "failed to retry: %v\n", err)
+				}
 				return
 			}
 			tail := newTail(target)
+			tail.stats = stats
 			var err error
 			if resumeRequest == nil {
-				err = tail.Start(ctx)
+				err = tail.Start(tailCtx)
 			} else {
-				err = tail.Resume(ctx, resumeRequest)
+				err = tail.Resume(tailCtx, resumeRequest)
 			}
 			tail.Close()
+			if resumeReq := tail.GetResumeRequest(); resumeReq != nil {
+				resumeRequest = resumeReq
+			}
 			if err == nil {
 				return
 			}
+			stats.fail(err)
 			if !filter.isActive(target) {
 				fmt.Fprintf(config.ErrOut, "failed to tail: %v\n", err)
 				return
 			}
 			fmt.Fprintf(config.ErrOut, "failed to tail: %v, will retry\n", err)
-			if resumeReq := tail.GetResumeRequest(); resumeReq != nil {
-				resumeRequest = resumeReq
-			}
+			stats.retry()
+			config.Metrics.retry(config.Context, target)
 		}
 	}
 
+	// The tails are waited for before the output is closed, so that their
+	// last records such as the flushed context lines are written. They are
+	// canceled first even if the setup fails on the way.
+	var tailing sync.WaitGroup
+	defer tailing.Wait()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	cancelMap := sync.Map{}
 	eg, nctx := errgroup.WithContext(ctx)
 	var numRequests atomic.Int64
 	for _, n := range namespaces {
-		selector, err := chooseSelector(nctx, client, n, resource.kind, resource.name, config.LabelSelector)
-		if err != nil {
-			return err
-		}
-		a, d, err := WatchTargets(nctx,
-			client.CoreV1().Pods(n),
-			selector,
-			config.FieldSelector,
-			filter,
-		)
-		if err != nil {
-			return errors.Wrap(err, "failed to set up watch")
+		if events != nil {
+			if err := events.watch(nctx, client, n); err != nil {
+				return err
+			}
 		}
+		for _, q := range queries {
+			selector, err := chooseSelector(nctx, client, dynResolver, n, q.kind, q.name, config.LabelSelector)
+			if err != nil {
+				return err
+			}
+			// The pod watch is restarted when the selector of the resource is changed
+			selectors := watchSelector(nctx, client, dynResolver, n, q.kind, q.name, config.LabelSelector, config.Metrics)
+			// Targets are re-evaluated when a node or namespace matching the selector joins
+			// or leaves, or when the ready endpoints of the service change
+			var nodesChanged, namespacesChanged, endpointsChanged <-chan struct{}
+			if hasNodeSelector {
+				nodesChanged = nodes.subscribe()
+			}
+			if nsFilter != nil && nsFilter.labeled != nil {
+				namespacesChanged = nsFilter.labeled.subscribe()
+			}
+			if q.endpoints != nil {
+				if err := q.endpoints.start(nctx, client, n); err != nil {
+					return err
+				}
+				endpointsChanged = q.endpoints.subscribe()
+			}
+			refresh := mergeNotifications(nctx, nodesChanged, namespacesChanged, endpointsChanged)
+			a, d, err := watchTargetsWithSelector(nctx,
+				client.CoreV1().Pods(n),
+				n,
+				selector,
+				selectors,
+				refresh,
+				config.FieldSelector,
+				q.filter,
+			)
+			if err != nil {
+				return errors.Wrap(err, "failed to set up watch")
+			}
 
-		eg.Go(func() error {
-			for {
-				select {
-				case target, ok := <-a:
-					if !ok {
-						return fmt.Errorf("lost watch connection")
-					}
-					numRequests.Add(1)
-					if numRequests.Load() > int64(config.MaxLogRequests) {
-						return fmt.Errorf(
-							"stern reached the maximum number of log requests (%d),"+
-								" use --max-log-requests to increase the limit",
-							config.MaxLogRequests)
+			eg.Go(func() error {
+				for {
+					select {
+					case target, ok := <-a:
+						if !ok {
+							return fmt.Errorf("lost watch connection")
+						}
+						numRequests.Add(1)
+						if numRequests.Load() > int64(config.MaxLogRequests) {
+							return fmt.Errorf(
+								"stern reached the maximum number of log requests (%d),"+
+									" use --max-log-requests to increase the limit",
+								config.MaxLogRequests)
+						}
+						ctx, cancel := context.WithCancel(nctx)
+						cancelMap.Store(target.GetID(), cancel)
+						tailing.Go(func() {
+							tailTarget(ctx, target)
+							numRequests.Add(-1)
+							cancel()
+							cancelMap.Delete(target.GetID())
+						})
+					case target := <-d:
+						if cancel, ok := cancelMap.LoadAndDelete(target.GetID()); ok {
+							cancel.(context.CancelFunc)()
+						}
+						if tails != nil {
+							tails.forget(target.GetID())
+						}
+						config.Metrics.forget(config.Context, target)
+					case <-nctx.Done():
+						return nil
 					}
-					ctx, cancel := context.WithCancel(nctx)
-					cancelMap.Store(target.GetID(), cancel)
-					go func() {
-						tailTarget(ctx, target)
-						numRequests.Add(-1)
-						cancel()
-						cancelMap.Delete(target.GetID())
-					}()
-				case target := <-d:
-					if cancel, ok := cancelMap.LoadAndDelete(target.GetID()); ok {
-						cancel.(context.CancelFunc)()
-					}
-				case <-nctx.Done():
-					return nil
 				}
-			}
-		})
+			})
+		}
 	}
 	return eg.Wait()
 }
 
-func chooseSelector(ctx context.Context, client kubernetes.Interface, namespace, kind, name string, selector labels.Selector) (labels.Selector, error) {
+// query is a query to select pods, which is the pod query or a resource query
+type query struct {
+	kind      string // the kind of the resource, which is empty for the pod query
+	name      string // the name of the resource
+	filter    *targetFilter
+	endpoints *endpointsWatcher // ready endpoints of the service, nil unless ReadyEndpoints
+}
+
+func newResourceQuery(client kubernetes.Interface, dynResolver *dynamicResolver, filter *targetFilter, resource string, onlyOwned, readyEndpoints bool) (*query, error) {
+	parts := strings.Split(resource, "/")
+	if len(parts) != 2 {
+		return nil, errors.New("resource must be specified in the form \"<resource>/<name>\"")
+	}
+	q := &query{kind: parts[0], name: parts[1]}
+
+	if PodMatcher.Matches(q.kind) {
+		// Pods might have no labels or share the same labels,
+		// so we use an exact match instead.
+		podName, err := regexp.Compile("^" + q.name + "$")
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to compile regular expression for pod")
+		}
+		q.filter = filter.withPodFilter(podName, nil)
+		return q, nil
+	}
+
+	var owner *ownerMatcher
+	// Pods of a CronJob are created by the Jobs it owns, and they might not
+	// have labels to select, so we always follow ownerReferences for CronJobs.
+	if onlyOwned || CronJobMatcher.Matches(q.kind) {
+		kind, ok := ownerKind(q.kind)
+		switch {
+		case ok:
+			owner = newOwnerMatcher(client, kind, q.name)
+		case isBuiltinResource(q.kind):
+			// --only-owned applies only to the resources that can own pods, so
+			// the pods of the others such as Services are selected by labels
+		case dynResolver == nil:
+			return nil, fmt.Errorf("resource type %s does not own pods", q.kind)
+		default:
+			kind, err := dynResolver.kindFor(q.kind)
+			if err != nil {
+				return nil, err
+			}
+			owner = newOwnerMatcher(client, kind, q.name)
+		}
+	}
+	// The pods are selected by the labels of the resource
+	q.filter = filter.withPodFilter(regexp.MustCompile(""), owner)
+	if readyEndpoints && ServiceMatcher.Matches(q.kind) {
+		// The pods are further narrowed down to the ready endpoints
+		q.endpoints = newEndpointsWatcher(q.name)
+		q.filter.c.endpoints = q.endpoints
+	}
+	return q, nil
+}
+
+func chooseSelector(ctx context.Context, client kubernetes.Interface, dynResolver *dynamicResolver, namespace, kind, name string, selector labels.Selector) (labels.Selector, error) {
 	if kind == "" {
 		return selector, nil
 	}
@@ -229,16 +536,38 @@ func chooseSelector(ctx context.Context, client kubernetes.Interface, namespace,
 		// We use an exact match for pods instead of a label to select pods without labels.
 		return labels.Everything(), nil
 	}
+	if !isBuiltinResource(kind) && dynResolver != nil {
+		// The built-in resources are resolved by the typed client as a fast path,
+		// and the other resources such as custom resources are resolved dynamically.
+		return dynResolver.retrieveSelector(ctx, namespace, kind, name)
+	}
 	labelMap, err := retrieveLabelsFromResource(ctx, client, namespace, kind, name)
 	if err != nil {
 		return nil, err
 	}
 	if len(labelMap) == 0 {
+		if CronJobMatcher.Matches(kind) {
+			// The job template of a CronJob might not have labels, but the pods
+			// created by Jobs always have the job-name label. We use it to
+			// narrow down the pods, which are filtered by ownerReferences later.
+			return jobPodSelector()
+		}
 		return nil, fmt.Errorf("resource %s/%s has no labels to select", kind, name)
 	}
 	return labels.SelectorFromSet(labelMap), nil
 }
 
+// jobPodSelector returns a selector that matches pods created by Jobs
+func jobPodSelector() (labels.Selector, error) {
+	// We use the legacy label because batch.kubernetes.io/job-name is
+	// not available in Kubernetes versions prior to 1.27.
+	req, err := labels.NewRequirement("job-name", selection.Exists, nil)
+	if err != nil {
+		return nil, err
+	}
+	return labels.NewSelector().Add(*req), nil
+}
+
 func retrieveLabelsFromResource(ctx context.Context, client kubernetes.Interface, namespace, kind, name string) (map[string]string, error) {
 	opt := metav1.GetOptions{}
 	switch {
@@ -284,13 +613,19 @@ func retrieveLabelsFromResource(ctx context.Context, client kubernetes.Interface
 		}
 		return o.Spec.Template.Labels, nil
 	// batch
-	// We do not support cronjobs because they might not have labels to select.
 	case JobMatcher.Matches(kind):
 		o, err := client.BatchV1().Jobs(namespace).Get(ctx, name, opt)
 		if err != nil {
 			return nil, err
 		}
 		return o.Spec.Template.Labels, nil
+	case CronJobMatcher.Matches(kind):
+		// The labels can be empty because the job template might not have labels.
+		o, err := client.BatchV1().CronJobs(namespace).Get(ctx, name, opt)
+		if err != nil {
+			return nil, err
+		}
+		return o.Spec.JobTemplate.Spec.Template.Labels, nil
 	}
 	return nil, fmt.Errorf("resource type %s is not supported", kind)
 }
@@ -149,6 +149,12 @@ func TestRetrieveLabelsFromResource(t *testing.T) {
 			name:  "job1",
 			label: "job-label",
 		},
+		{
+			desc:  "cronjobs",
+			kinds: []string{"cj", "cronjobs", "cronjob"},
+			name:  "cj1",
+			label: "cj-label",
+		},
 		// invalid
 		{
 			desc:      "invalid",
@@ -185,3 +191,39 @@ func TestRetrieveLabelsFromResource(t *testing.T) {
 		})
 	}
 }
+
+func TestNewResourceQueryOnlyOwned(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	filter := newTargetFilter(targetFilterConfig{})
+
+	tests := []struct {
+		resource      string
+		expectedOwner bool
+		wantError     bool
+	}{
+		{resource: "deploy/api", expectedOwner: true},
+		{resource: "cronjob/cj1", expectedOwner: true},
+		// the pods of a service are selected by labels
+		{resource: "svc/api", expectedOwner: false},
+		// custom resources cannot be resolved without the dynamic client
+		{resource: "rollout/api", wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.resource, func(t *testing.T) {
+			q, err := newResourceQuery(client, nil, filter, tt.resource, true, false)
+			if tt.wantError {
+				if err == nil {
+					t.Error("expected error, but got no error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if actual := q.filter.c.owner != nil; actual != tt.expectedOwner {
+				t.Errorf("expected the owner filter %v, but actual %v", tt.expectedOwner, actual)
+			}
+		})
+	}
+}
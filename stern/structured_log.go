@@ -0,0 +1,131 @@
+package stern
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseStructuredLog parses the message as a JSON object or a logfmt line.
+// It returns false if the message is neither of them.
+func parseStructuredLog(msg string) (map[string]any, bool) {
+	msg = strings.TrimSpace(msg)
+	if strings.HasPrefix(msg, "{") {
+		dec := json.NewDecoder(strings.NewReader(msg))
+		dec.UseNumber()
+		var fields map[string]any
+		if err := dec.Decode(&fields); err != nil {
+			return nil, false
+		}
+		return fields, true
+	}
+	return parseLogfmt(msg)
+}
+
+// parseLogfmt parses the line in the logfmt format such as
+// `level=info msg="hello world" status=200`. Every token must be a key=value
+// pair so that plain text is not mistaken for logfmt.
+func parseLogfmt(line string) (map[string]any, bool) {
+	fields := map[string]any{}
+	for i := 0; i < len(line); {
+		if line[i] == ' ' || line[i] == '\t' {
+			i++
+			continue
+		}
+
+		eq := strings.IndexAny(line[i:], "= \t\"")
+		if eq <= 0 || line[i+eq] != '=' {
+			return nil, false
+		}
+		key := line[i : i+eq]
+		i += eq + 1
+
+		var value string
+		if i < len(line) && line[i] == '"' {
+			end := quotedStringEnd(line[i:])
+			if end < 0 {
+				return nil, false
+			}
+			unquoted, err := strconv.Unquote(line[i : i+end])
+			if err != nil {
+				return nil, false
+			}
+			value = unquoted
+			i += end
+		} else {
+			end := strings.IndexAny(line[i:], " \t")
+			if end < 0 {
+				end = len(line) - i
+			}
+			value = line[i : i+end]
+			if strings.Contains(value, `"`) {
+				return nil, false
+			}
+			i += end
+		}
+		fields[key] = value
+	}
+	return fields, len(fields) > 0
+}
+
+// quotedStringEnd returns the index next to the closing quote of the string
+// starting with a double quote, or -1 if it is not closed
+func quotedStringEnd(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// lookupField returns the values of the field in the structured log. The
+// name is looked up as is first, and then as a dot-separated path of nested
+// objects. Arrays yield all of their elements. It returns false if the
+// field is missing or null.
+func lookupField(fields map[string]any, name string) ([]string, bool) {
+	if v, ok := fields[name]; ok && v != nil {
+		return fieldValues(v), true
+	}
+	var current any = fields
+	for key := range strings.SplitSeq(name, ".") {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		current, ok = m[key]
+		if !ok || current == nil {
+			return nil, false
+		}
+	}
+	return fieldValues(current), true
+}
+
+func fieldValues(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case json.Number:
+		return []string{v.String()}
+	case []any:
+		var values []string
+		for _, e := range v {
+			if e != nil {
+				values = append(values, fieldValues(e)...)
+			}
+		}
+		return values
+	case map[string]any:
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(v); err != nil {
+			return nil
+		}
+		return []string{strings.TrimSuffix(buf.String(), "\n")}
+	}
+	return []string{fmt.Sprint(v)}
+}
@@ -0,0 +1,84 @@
+package stern
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseStructuredLog(t *testing.T) {
+	tests := []struct {
+		msg      string
+		expected map[string]string // field name to the first value
+		ok       bool
+	}{
+		{
+			msg:      `{"level":"info","status":200,"http":{"path":"/"}}`,
+			expected: map[string]string{"level": "info", "status": "200", "http.path": "/"},
+			ok:       true,
+		},
+		{
+			msg:      `  {"id":12345678901234567890}  `,
+			expected: map[string]string{"id": "12345678901234567890"},
+			ok:       true,
+		},
+		{
+			msg:      `time="2024-01-01T00:00:00Z" level=info msg="hello \"world\"" status=200 url=/a?b=c`,
+			expected: map[string]string{"level": "info", "msg": `hello "world"`, "status": "200", "url": "/a?b=c"},
+			ok:       true,
+		},
+		{
+			msg:      `empty= level=warn`,
+			expected: map[string]string{"empty": "", "level": "warn"},
+			ok:       true,
+		},
+		{msg: `{"level":"info"`, ok: false},
+		{msg: `[1,2,3]`, ok: false},
+		{msg: `plain text`, ok: false},
+		{msg: `user logged in id=3`, ok: false},
+		{msg: `msg="unterminated`, ok: false},
+		{msg: `=value`, ok: false},
+		{msg: ``, ok: false},
+	}
+
+	for _, tt := range tests {
+		fields, ok := parseStructuredLog(tt.msg)
+		if ok != tt.ok {
+			t.Errorf("%s: expected %v, but actual %v", tt.msg, tt.ok, ok)
+			continue
+		}
+		for name, want := range tt.expected {
+			values, found := lookupField(fields, name)
+			if !found || len(values) == 0 || values[0] != want {
+				t.Errorf("%s: expected %q for %s, but actual %q", tt.msg, want, name, values)
+			}
+		}
+	}
+}
+
+func TestLookupField(t *testing.T) {
+	fields, ok := parseStructuredLog(`{"a.b":"literal","a":{"b":"nested","c":null},"list":[1,"x",null,{"k":"v"}],"obj":{"k":true}}`)
+	if !ok {
+		t.Fatal("failed to parse the log")
+	}
+
+	tests := []struct {
+		name     string
+		expected []string
+		ok       bool
+	}{
+		{"a.b", []string{"literal"}, true},
+		{"a.c", nil, false},
+		{"a.d", nil, false},
+		{"list", []string{"1", "x", `{"k":"v"}`}, true},
+		{"obj", []string{`{"k":true}`}, true},
+		{"obj.k", []string{"true"}, true},
+		{"obj.k.x", nil, false},
+	}
+
+	for _, tt := range tests {
+		values, ok := lookupField(fields, tt.name)
+		if ok != tt.ok || !reflect.DeepEqual(values, tt.expected) {
+			t.Errorf("%s: expected %q (%v), but actual %q (%v)", tt.name, tt.expected, tt.ok, values, ok)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package stern
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// SummaryFormat is the format of the summary printed on exit
+type SummaryFormat string
+
+const (
+	// SummaryOff prints no summary
+	SummaryOff SummaryFormat = ""
+	// SummaryTable prints the summary as a table
+	SummaryTable SummaryFormat = "table"
+	// SummaryJSON prints the summary as a JSON array
+	SummaryJSON SummaryFormat = "json"
+)
+
+// NewSummaryFormat returns the format of the name
+func NewSummaryFormat(name string) (SummaryFormat, error) {
+	switch f := SummaryFormat(name); f {
+	case SummaryOff, SummaryTable, SummaryJSON:
+		return f, nil
+	}
+	return SummaryOff, fmt.Errorf("summary should be one of '%s', '%s'", SummaryTable, SummaryJSON)
+}
+
+// TailStats is the statistics of the tails of a container
+type TailStats struct {
+	Context       string `json:"context,omitempty"`
+	Namespace     string `json:"namespace"`
+	PodName       string `json:"podName"`
+	ContainerName string `json:"containerName"`
+
+	// LinesRead is the number of the lines read from the log streams
+	LinesRead int64 `json:"linesRead"`
+	// LinesMatched is the number of the entries that pass the filters such
+	// as --exclude, --include and --min-level. Multi-line entries are counted
+	// once.
+	LinesMatched int64 `json:"linesMatched"`
+	// LinesEmitted is the number of the entries printed including context
+	// lines
+	LinesEmitted int64 `json:"linesEmitted"`
+	// LinesExcluded is the number of the entries that do not pass the filters
+	// and are not printed as context lines. Each entry is counted in either
+	// LinesEmitted or LinesExcluded.
+	LinesExcluded int64 `json:"linesExcluded"`
+	// Bytes is the size of the lines read including timestamps
+	Bytes int64 `json:"bytes"`
+	// Retries is the number of the retries after the log stream fails
+	Retries int64 `json:"retries"`
+	// Errors is the number of the failures of the log stream, and LastError
+	// is the last one
+	Errors    int64  `json:"errors"`
+	LastError string `json:"lastError,omitempty"`
+
+	// FirstTimestamp and LastTimestamp are the RFC3339Nano timestamps of the
+	// first and the last lines read
+	FirstTimestamp string `json:"firstTimestamp,omitempty"`
+	LastTimestamp  string `json:"lastTimestamp,omitempty"`
+}
+
+// tailStats is the statistics updated by the tails of a container. The
+// methods do nothing if it is nil.
+type tailStats struct {
+	mu sync.Mutex
+	s  TailStats
+}
+
+func (s *tailStats) read(rfc3339Nano string, bytes int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.s.LinesRead++
+	s.s.Bytes += int64(bytes)
+	if rfc3339Nano == "" {
+		return
+	}
+	if s.s.FirstTimestamp == "" {
+		s.s.FirstTimestamp = rfc3339Nano
+	}
+	s.s.LastTimestamp = rfc3339Nano
+}
+
+func (s *tailStats) match() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.s.LinesMatched++
+}
+
+func (s *tailStats) emit() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.s.LinesEmitted++
+}
+
+func (s *tailStats) exclude() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.s.LinesExcluded++
+}
+
+func (s *tailStats) retry() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.s.Retries++
+}
+
+// fail records the error unless it is nil
+func (s *tailStats) fail(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.s.Errors++
+	s.s.LastError = err.Error()
+}
+
+func (s *tailStats) snapshot() TailStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.s
+}
+
+// Summary collects the statistics of the tails to print them on exit. It is
+// shared by the runs of multiple contexts.
+type Summary struct {
+	format   SummaryFormat
+	location *time.Location
+
+	mu    sync.Mutex
+	stats map[string]*tailStats
+}
+
+// NewSummary returns a summary printed in the format. The timestamps of the
+// table are shown in the location.
+func NewSummary(format SummaryFormat, location *time.Location) *Summary {
+	return &Summary{
+		format:   format,
+		location: location,
+		stats:    make(map[string]*tailStats),
+	}
+}
+
+// targetStats returns the statistics of the target, or nil if the summary is
+// nil
+func (s *Summary) targetStats(context string, t *Target) *tailStats {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := context + "/" + t.GetID()
+	stats, ok := s.stats[id]
+	if !ok {
+		stats = &tailStats{s: TailStats{
+			Context:       context,
+			Namespace:     t.Pod.Namespace,
+			PodName:       t.Pod.Name,
+			ContainerName: t.Container,
+		}}
+		s.stats[id] = stats
+	}
+	return stats
+}
+
+// Stats returns the statistics of all the containers sorted by the context,
+// namespace, pod name and container name
+func (s *Summary) Stats() []TailStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := make([]TailStats, 0, len(s.stats))
+	for _, ts := range s.stats {
+		stats = append(stats, ts.snapshot())
+	}
+	slices.SortFunc(stats, func(a, b TailStats) int {
+		return cmp.Or(
+			cmp.Compare(a.Context, b.Context),
+			cmp.Compare(a.Namespace, b.Namespace),
+			cmp.Compare(a.PodName, b.PodName),
+			cmp.Compare(a.ContainerName, b.ContainerName),
+		)
+	})
+	return stats
+}
+
+// Print prints the summary in the format
+func (s *Summary) Print(w io.Writer) error {
+	stats := s.Stats()
+	if s.format == SummaryJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(stats)
+	}
+
+	withContext := slices.ContainsFunc(stats, func(ts TailStats) bool { return ts.Context != "" })
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	if withContext {
+		fmt.Fprint(tw, "CONTEXT\t")
+	}
+	fmt.Fprintln(tw, "NAMESPACE\tPOD\tCONTAINER\tREAD\tMATCHED\tEMITTED\tEXCLUDED\tBYTES\tRETRIES\tERRORS\tFIRST\tLAST\tLAST ERROR")
+	for _, ts := range stats {
+		if withContext {
+			fmt.Fprintf(tw, "%s\t", ts.Context)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%s\t%s\t%s\n",
+			ts.Namespace, ts.PodName, ts.ContainerName,
+			ts.LinesRead, ts.LinesMatched, ts.LinesEmitted, ts.LinesExcluded, ts.Bytes, ts.Retries, ts.Errors,
+			s.formatTimestamp(ts.FirstTimestamp), s.formatTimestamp(ts.LastTimestamp), orDash(ts.LastError))
+	}
+	return tw.Flush()
+}
+
+// formatTimestamp returns the timestamp in RFC3339 in the location
+func (s *Summary) formatTimestamp(rfc3339Nano string) string {
+	ts, err := time.Parse(time.RFC3339Nano, rfc3339Nano)
+	if err != nil {
+		return orDash(rfc3339Nano)
+	}
+	if s.location != nil {
+		ts = ts.In(s.location)
+	}
+	return ts.Format(time.RFC3339)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
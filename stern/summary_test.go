@@ -0,0 +1,178 @@
+package stern
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"regexp"
+	"testing"
+	"text/template"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestTailStats(t *testing.T) {
+	tmpl := template.Must(template.New("").Parse(`{{.Message}}` + "\n"))
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "pod1"}}
+	target := &Target{Pod: pod, Container: "c1"}
+	summary := NewSummary(SummaryJSON, time.UTC)
+
+	tail := NewTail(fake.NewSimpleClientset().CoreV1(), pod, "c1", tmpl, io.Discard, io.Discard, &TailOptions{
+		Include: []*regexp.Regexp{regexp.MustCompile("line")},
+		Exclude: []*regexp.Regexp{regexp.MustCompile("debug")},
+	}, false)
+	tail.stats = summary.targetStats("", target)
+	logLines := `2023-02-13T21:20:30.000000001Z line 1
+2023-02-13T21:20:30.000000002Z log 2
+2023-02-13T21:20:31.000000001Z debug line 3
+2023-02-13T21:20:31.000000002Z line 4
+`
+	if err := tail.ConsumeRequest(context.TODO(), &responseWrapperMock{data: bytes.NewBufferString(logLines)}); err != nil {
+		t.Fatalf("unexpected err %v", err)
+	}
+	// the stats are shared by the tails of the same target
+	stats := summary.targetStats("", target)
+	stats.fail(errors.New("unexpected EOF"))
+	stats.retry()
+	stats.fail(nil)
+
+	expected := []TailStats{{
+		Namespace:      "ns1",
+		PodName:        "pod1",
+		ContainerName:  "c1",
+		LinesRead:      4,
+		LinesMatched:   2,
+		LinesEmitted:   2,
+		LinesExcluded:  2,
+		Bytes:          int64(len(logLines)),
+		Retries:        1,
+		Errors:         1,
+		LastError:      "unexpected EOF",
+		FirstTimestamp: "2023-02-13T21:20:30.000000001Z",
+		LastTimestamp:  "2023-02-13T21:20:31.000000002Z",
+	}}
+	if actual := summary.Stats(); len(actual) != 1 || actual[0] != expected[0] {
+		t.Errorf("expected %+v, but actual %+v", expected, actual)
+	}
+}
+
+func TestTailStatsContextLines(t *testing.T) {
+	tmpl := template.Must(template.New("").Parse(`{{.Message}}` + "\n"))
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "pod1"}}
+	summary := NewSummary(SummaryJSON, time.UTC)
+
+	tail := NewTail(fake.NewSimpleClientset().CoreV1(), pod, "c1", tmpl, io.Discard, io.Discard, &TailOptions{
+		Include:       []*regexp.Regexp{regexp.MustCompile("error")},
+		BeforeContext: 1,
+		AfterContext:  1,
+	}, false)
+	tail.stats = summary.targetStats("", &Target{Pod: pod, Container: "c1"})
+	// the context lines are 2 and 4, and 1, 5 and 6 are dropped
+	logLines := `2023-02-13T21:20:30.000000001Z log 1
+2023-02-13T21:20:30.000000002Z log 2
+2023-02-13T21:20:30.000000003Z error 3
+2023-02-13T21:20:30.000000004Z log 4
+2023-02-13T21:20:30.000000005Z log 5
+2023-02-13T21:20:30.000000006Z log 6
+`
+	if err := tail.ConsumeRequest(context.TODO(), &responseWrapperMock{data: bytes.NewBufferString(logLines)}); err != nil {
+		t.Fatalf("unexpected err %v", err)
+	}
+
+	actual := summary.Stats()[0]
+	if actual.LinesMatched != 1 || actual.LinesEmitted != 3 || actual.LinesExcluded != 3 {
+		t.Errorf("expected 1 matched, 3 emitted and 3 excluded, but actual %+v", actual)
+	}
+}
+
+func TestSummaryPrint(t *testing.T) {
+	newSummary := func(format SummaryFormat, contexts ...string) *Summary {
+		summary := NewSummary(format, time.UTC)
+		for _, ctx := range contexts {
+			for _, name := range []string{"pod2", "pod1"} {
+				pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: name}}
+				stats := summary.targetStats(ctx, &Target{Pod: pod, Container: "c1"})
+				stats.read("2023-02-13T21:20:30.000000001Z", 100)
+				stats.match()
+				stats.emit()
+			}
+		}
+		return summary
+	}
+
+	tests := []struct {
+		name     string
+		summary  *Summary
+		expected string
+	}{
+		{
+			name:    "table",
+			summary: newSummary(SummaryTable, ""),
+			expected: `NAMESPACE  POD   CONTAINER  READ  MATCHED  EMITTED  EXCLUDED  BYTES  RETRIES  ERRORS  FIRST                 LAST                  LAST ERROR
+ns1        pod1  c1         1     1        1        0         100    0        0       2023-02-13T21:20:30Z  2023-02-13T21:20:30Z  -
+ns1        pod2  c1         1     1        1        0         100    0        0       2023-02-13T21:20:30Z  2023-02-13T21:20:30Z  -
+`,
+		},
+		{
+			name:    "table with contexts",
+			summary: newSummary(SummaryTable, "ctx2", "ctx1"),
+			expected: `CONTEXT  NAMESPACE  POD   CONTAINER  READ  MATCHED  EMITTED  EXCLUDED  BYTES  RETRIES  ERRORS  FIRST                 LAST                  LAST ERROR
+ctx1     ns1        pod1  c1         1     1        1        0         100    0        0       2023-02-13T21:20:30Z  2023-02-13T21:20:30Z  -
+ctx1     ns1        pod2  c1         1     1        1        0         100    0        0       2023-02-13T21:20:30Z  2023-02-13T21:20:30Z  -
+ctx2     ns1        pod1  c1         1     1        1        0         100    0        0       2023-02-13T21:20:30Z  2023-02-13T21:20:30Z  -
+ctx2     ns1        pod2  c1         1     1        1        0         100    0        0       2023-02-13T21:20:30Z  2023-02-13T21:20:30Z  -
+`,
+		},
+		{
+			name:    "json",
+			summary: newSummary(SummaryJSON, ""),
+			expected: `[
+  {
+    "namespace": "ns1",
+    "podName": "pod1",
+    "containerName": "c1",
+    "linesRead": 1,
+    "linesMatched": 1,
+    "linesEmitted": 1,
+    "linesExcluded": 0,
+    "bytes": 100,
+    "retries": 0,
+    "errors": 0,
+    "firstTimestamp": "2023-02-13T21:20:30.000000001Z",
+    "lastTimestamp": "2023-02-13T21:20:30.000000001Z"
+  },
+  {
+    "namespace": "ns1",
+    "podName": "pod2",
+    "containerName": "c1",
+    "linesRead": 1,
+    "linesMatched": 1,
+    "linesEmitted": 1,
+    "linesExcluded": 0,
+    "bytes": 100,
+    "retries": 0,
+    "errors": 0,
+    "firstTimestamp": "2023-02-13T21:20:30.000000001Z",
+    "lastTimestamp": "2023-02-13T21:20:30.000000001Z"
+  }
+]
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := new(bytes.Buffer)
+			if err := tt.summary.Print(out); err != nil {
+				t.Fatalf("unexpected err %v", err)
+			}
+			if out.String() != tt.expected {
+				t.Errorf("expected\n%s\nbut actual\n%s", tt.expected, out)
+			}
+		})
+	}
+}
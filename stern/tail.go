@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"hash/fnv"
 	"io"
+	"regexp"
 	"strings"
 	"text/template"
 	"time"
@@ -56,6 +57,14 @@ type Tail struct {
 		lines     int    // the number of lines seen during this timestamp
 	}
 	resumeRequest *ResumeRequest
+	previous      bool   // tails the previous instance of the container
+	kind          string // the kind of the records such as EventKind, empty for log lines
+	contextLines  *contextLines
+	multiline     *multilineBuffer
+	repeats       *repeatCollapser
+	ordered       *orderedSource // nil unless the output is ordered by timestamps
+	stats         *tailStats     // nil unless the summary is printed
+	metrics       *Metrics       // nil unless the metrics are served
 	out           io.Writer
 	errOut        io.Writer
 }
@@ -69,7 +78,7 @@ type ResumeRequest struct {
 func NewTail(clientset corev1client.CoreV1Interface, pod *corev1.Pod, containerName string, tmpl *template.Template, out, errOut io.Writer, options *TailOptions, diffContainer bool) *Tail {
 	podColor, containerColor := determineColor(pod.Name, containerName, diffContainer)
 
-	return &Tail{
+	t := &Tail{
 		clientset:      clientset,
 		Pod:            pod,
 		ContainerName:  containerName,
@@ -78,10 +87,15 @@ func NewTail(clientset corev1client.CoreV1Interface, pod *corev1.Pod, containerN
 		tmpl:           tmpl,
 		podColor:       podColor,
 		containerColor: containerColor,
+		contextLines:   newContextLines(options),
 
 		out:    out,
 		errOut: errOut,
 	}
+	t.multiline = newMultilineBuffer(options, t.consumeEntry)
+	t.repeats = newRepeatCollapser(options, t.printLevel, t.printRepeated)
+	t.ordered, _ = out.(*orderedSource)
+	return t
 }
 
 func determineColor(podName, containerName string, diffContainer bool) (podColor, containerColor *color.Color) {
@@ -107,9 +121,12 @@ func (t *Tail) Start(ctx context.Context) error {
 	}()
 
 	t.printStarting()
+	t.metrics.tailStarted()
+	defer t.metrics.tailStopped()
 
 	req := t.clientset.Pods(t.Pod.Namespace).GetLogs(t.Pod.Name, &corev1.PodLogOptions{
-		Follow:       t.Options.Follow,
+		Follow:       t.Options.Follow && !t.previous,
+		Previous:     t.previous,
 		Timestamps:   true,
 		Container:    t.ContainerName,
 		SinceSeconds: t.Options.SinceSeconds,
@@ -139,9 +156,21 @@ func (t *Tail) Resume(ctx context.Context, resumeRequest *ResumeRequest) error {
 	return t.Start(ctx)
 }
 
+// ResumePrevious prints the logs of the previous instance of the container
+// that have not been printed by the tail of the resume request. It prints all
+// the logs if the resume request is nil.
+func (t *Tail) ResumePrevious(ctx context.Context, resumeRequest *ResumeRequest) error {
+	t.previous = true
+	if resumeRequest == nil {
+		return t.Start(ctx)
+	}
+	return t.Resume(ctx, resumeRequest)
+}
+
 // Close stops tailing
 func (t *Tail) Close() {
 	t.printStopping()
+	t.ordered.close()
 
 	close(t.closed)
 }
@@ -149,27 +178,38 @@ func (t *Tail) Close() {
 func (t *Tail) printStarting() {
 	if !t.Options.OnlyLogLines {
 		g := color.New(color.FgHiGreen, color.Bold).SprintFunc()
-		p := t.podColor.SprintFunc()
 		c := t.containerColor.SprintFunc()
-		if t.Options.Namespace {
-			fmt.Fprintf(t.errOut, "%s %s %s › %s\n", g("+"), p(t.Pod.Namespace), p(t.Pod.Name), c(t.ContainerName))
-		} else {
-			fmt.Fprintf(t.errOut, "%s %s › %s\n", g("+"), p(t.Pod.Name), c(t.ContainerName))
-		}
+		fmt.Fprintf(t.errOut, "%s %s › %s%s\n", g("+"), t.coloredPodName(), c(t.ContainerName), t.previousSuffix())
 	}
 }
 
 func (t *Tail) printStopping() {
 	if !t.Options.OnlyLogLines {
 		r := color.New(color.FgHiRed, color.Bold).SprintFunc()
-		p := t.podColor.SprintFunc()
 		c := t.containerColor.SprintFunc()
-		if t.Options.Namespace {
-			fmt.Fprintf(t.errOut, "%s %s %s › %s\n", r("-"), p(t.Pod.Namespace), p(t.Pod.Name), c(t.ContainerName))
-		} else {
-			fmt.Fprintf(t.errOut, "%s %s › %s\n", r("-"), p(t.Pod.Name), c(t.ContainerName))
-		}
+		fmt.Fprintf(t.errOut, "%s %s › %s%s\n", r("-"), t.coloredPodName(), c(t.ContainerName), t.previousSuffix())
+	}
+}
+
+func (t *Tail) previousSuffix() string {
+	if t.previous {
+		return " (previous)"
+	}
+	return ""
+}
+
+// coloredPodName returns the pod name prefixed with the context and the
+// namespace when they should be shown
+func (t *Tail) coloredPodName() string {
+	p := t.podColor.SprintFunc()
+	name := p(t.Pod.Name)
+	if t.Options.Namespace {
+		name = p(t.Pod.Namespace) + " " + name
 	}
+	if t.Options.Context != "" {
+		name = p(t.Options.Context) + " " + name
+	}
+	return name
 }
 
 // ConsumeRequest reads the data from request and writes into the out
@@ -180,12 +220,17 @@ func (t *Tail) ConsumeRequest(ctx context.Context, request rest.ResponseWrapper)
 		return err
 	}
 	defer stream.Close()
+	defer t.repeats.flush()
+	defer t.contextLines.discard(t.stats.exclude)
+	defer t.multiline.flush()
 
 	r := bufio.NewReader(stream)
 	for {
 		line, err := r.ReadBytes('\n')
 		if len(line) != 0 {
-			t.consumeLine(strings.TrimSuffix(string(line), "\n"))
+			if done := t.consumeLine(strings.TrimSuffix(string(line), "\n")); done {
+				return nil
+			}
 		}
 
 		if err != nil {
@@ -197,11 +242,22 @@ func (t *Tail) ConsumeRequest(ctx context.Context, request rest.ResponseWrapper)
 	}
 }
 
-func (t *Tail) sprint(msg string, timestamp string) (string, error) {
-	vm := Log{
-		Message:        msg,
+func (t *Tail) sprint(msg string, timestamp string, level Level, repeatCount int) (string, error) {
+	vm := t.newLog(msg, timestamp)
+	vm.Level = level.String()
+	vm.RepeatCount = repeatCount
+	return t.render(vm)
+}
+
+// newLog returns the object passed to the template for the message
+func (t *Tail) newLog(msg string, timestamp string) Log {
+	return Log{
+		Message:        t.Options.RedactMessage(msg),
 		Timestamp:      timestamp,
+		Kind:           t.kind,
+		Context:        t.Options.Context,
 		NodeName:       t.Pod.Spec.NodeName,
+		NodeLabels:     t.Options.NodeLabels,
 		Namespace:      t.Pod.Namespace,
 		PodName:        t.Pod.Name,
 		ContainerName:  t.ContainerName,
@@ -210,9 +266,12 @@ func (t *Tail) sprint(msg string, timestamp string) (string, error) {
 		PodColor:       t.podColor,
 		ContainerColor: t.containerColor,
 	}
+}
 
+func (t *Tail) render(vm Log) (string, error) {
 	var buf bytes.Buffer
 	if err := t.tmpl.Execute(&buf, vm); err != nil {
+		t.metrics.templateError()
 		return "", fmt.Errorf("expanding template failed: %s", err)
 	}
 
@@ -221,18 +280,69 @@ func (t *Tail) sprint(msg string, timestamp string) (string, error) {
 
 // Print prints a color coded log message with the pod and container names
 func (t *Tail) Print(msg string, timestamp string) {
-	buf, err := t.sprint(msg, timestamp)
+	t.printLevel(msg, timestamp, t.Options.detectLevel(msg))
+}
+
+// printLevel is like Print, but with the level detected beforehand
+func (t *Tail) printLevel(msg string, timestamp string, level Level) {
+	buf, err := t.sprint(msg, timestamp, level, 0)
 	if err != nil {
 		fmt.Fprintf(t.errOut, "%s\n", err)
 		return
 	}
 
-	fmt.Fprint(t.out, t.Options.HighlightMatchedString(buf))
+	fmt.Fprint(t.out, t.Options.highlightMatchedString(buf, t.patternCounter("highlight")))
+}
+
+// patternCounter returns the function counting the entries matching the
+// patterns of the flag, or nil unless the matches of the log lines are
+// counted
+func (t *Tail) patternCounter(flag string) func(re *regexp.Regexp) {
+	if t.kind == EventKind {
+		return nil
+	}
+	return t.metrics.patternCounter(flag)
 }
 
 // PrintWithoutHighlight prints a log message without applying any highlight.
 func (t *Tail) PrintWithoutHighlight(msg string) {
-	buf, err := t.sprint(msg, "")
+	buf, err := t.sprint(msg, "", LevelUnknown, 0)
+	if err != nil {
+		fmt.Fprintf(t.errOut, "%s\n", err)
+		return
+	}
+
+	fmt.Fprint(t.out, buf)
+}
+
+// printRepeated prints the number of the suppressed repeated messages
+func (t *Tail) printRepeated(count int, timestamp string) {
+	buf, err := t.sprint(repeatMessage(count), timestamp, LevelUnknown, count)
+	if err != nil {
+		fmt.Fprintf(t.errOut, "%s\n", err)
+		return
+	}
+
+	fmt.Fprint(t.out, buf)
+}
+
+// PrintEvent prints the lifecycle transition of the container. It is printed
+// regardless of the filters of the log lines such as --include, --exclude and
+// --min-level.
+func (t *Tail) PrintEvent(event ContainerEvent) {
+	// the termination message might contain secrets as well as log lines
+	event.Message = t.Options.RedactMessage(event.Message)
+	rfc3339Nano := event.time.UTC().Format(time.RFC3339Nano)
+	t.ordered.advance(rfc3339Nano)
+
+	var timestamp string
+	if t.Options.Timestamps {
+		timestamp, _ = t.Options.UpdateTimezoneAndFormat(rfc3339Nano)
+	}
+	vm := t.newLog(event.String(), timestamp)
+	vm.Level = event.level().String()
+	vm.Event = &event
+	buf, err := t.render(vm)
 	if err != nil {
 		fmt.Fprintf(t.errOut, "%s\n", err)
 		return
@@ -248,11 +358,18 @@ func (t *Tail) GetResumeRequest() *ResumeRequest {
 	return &ResumeRequest{Timestamp: t.last.timestamp, LinesToSkip: t.last.lines}
 }
 
-func (t *Tail) consumeLine(line string) {
+// consumeLine consumes a line of the log. It returns true when the tail
+// should stop because the line is after --until.
+func (t *Tail) consumeLine(line string) bool {
 	rfc3339Nano, content, err := splitLogLine(line)
+	t.stats.read(rfc3339Nano, len(line)+1)
+	t.metrics.readLine(t, len(line)+1)
 	if err != nil {
 		t.PrintWithoutHighlight(fmt.Sprintf("[%v] %s", err, line))
-		return
+		return false
+	}
+	if t.Options.IsAfterUntil(rfc3339Nano) {
+		return true
 	}
 
 	// PodLogOptions.SinceTime is RFC3339, not RFC3339Nano.
@@ -260,10 +377,31 @@ func (t *Tail) consumeLine(line string) {
 	rfc3339 := removeSubsecond(rfc3339Nano)
 	t.rememberLastTimestamp(rfc3339)
 	if t.resumeRequest.shouldSkip(rfc3339) {
-		return
+		return false
+	}
+
+	if t.multiline != nil {
+		t.multiline.add(rfc3339Nano, content)
+		return false
 	}
+	t.consumeEntry(rfc3339Nano, content)
+	return false
+}
 
-	if t.Options.IsExclude(content) || !t.Options.IsInclude(content) {
+// consumeEntry filters and prints a log entry, which consists of multiple
+// lines when multi-line entries are joined
+func (t *Tail) consumeEntry(rfc3339Nano, content string) {
+	t.ordered.advance(rfc3339Nano)
+	level := t.Options.detectLevel(content)
+	if t.Options.IsExclude(content) || t.Options.isBelowMinLevel(level) {
+		t.stats.exclude()
+		return
+	}
+	matched := t.Options.isInclude(content, t.patternCounter("include")) && t.Options.IsFieldMatch(content)
+	if matched {
+		t.stats.match()
+	} else if t.contextLines == nil {
+		t.stats.exclude()
 		return
 	}
 
@@ -271,13 +409,39 @@ func (t *Tail) consumeLine(line string) {
 	if t.Options.Timestamps {
 		updatedTs, err := t.Options.UpdateTimezoneAndFormat(rfc3339Nano)
 		if err != nil {
-			t.PrintWithoutHighlight(fmt.Sprintf("[%v] %s", err, line))
+			t.PrintWithoutHighlight(fmt.Sprintf("[%v] %s %s", err, rfc3339Nano, content))
 			return
 		}
 		timestamp = updatedTs
 	}
 
-	t.Print(content, timestamp)
+	// an unmatched entry is counted as excluded unless it is printed as a
+	// context line
+	t.contextLines.add(contextLine{msg: content, timestamp: timestamp, level: level}, matched,
+		t.printEntry,
+		t.printSeparator,
+		t.stats.exclude)
+}
+
+// printSeparator prints ContextSeparator through the template like a log
+// line without a timestamp
+func (t *Tail) printSeparator() {
+	t.repeats.flush()
+	if t.Options.OmitContextSeparator {
+		return
+	}
+	t.PrintWithoutHighlight(ContextSeparator)
+}
+
+// printEntry prints the entry unless it repeats the last one when repeated
+// messages are collapsed
+func (t *Tail) printEntry(l contextLine) {
+	t.stats.emit()
+	if t.repeats != nil {
+		t.repeats.add(l.msg, l.timestamp, l.level)
+		return
+	}
+	t.printLevel(l.msg, l.timestamp, l.level)
 }
 
 func (t *Tail) rememberLastTimestamp(timestamp string) {
@@ -14,7 +14,10 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/utils/ptr"
 )
 
 func TestDetermineColor(t *testing.T) {
@@ -62,6 +65,7 @@ func TestConsumeStreamTail(t *testing.T) {
 	tests := []struct {
 		name      string
 		resumeReq *ResumeRequest
+		until     *time.Time
 		expected  []byte
 	}{
 		{
@@ -101,6 +105,14 @@ line 4 (my-node/my-namespace/my-pod/my-container)
 line 2 (my-node/my-namespace/my-pod/my-container)
 line 3 (my-node/my-namespace/my-pod/my-container)
 line 4 (my-node/my-namespace/my-pod/my-container)
+`),
+		},
+		{
+			name:  "Until stops the tail",
+			until: ptr.To(time.Date(2023, 2, 13, 21, 20, 31, 1, time.UTC)),
+			expected: []byte(`line 1 (my-node/my-namespace/my-pod/my-container)
+line 2 (my-node/my-namespace/my-pod/my-container)
+line 3 (my-node/my-namespace/my-pod/my-container)
 `),
 		},
 	}
@@ -118,7 +130,7 @@ line 4 (my-node/my-namespace/my-pod/my-container)
 					NodeName: "my-node",
 				},
 			}
-			tail := NewTail(clientset.CoreV1(), pod, "my-container", tmpl, out, io.Discard, &TailOptions{}, false)
+			tail := NewTail(clientset.CoreV1(), pod, "my-container", tmpl, out, io.Discard, &TailOptions{Until: tt.until}, false)
 			tail.resumeRequest = tt.resumeReq
 			if err := tail.ConsumeRequest(context.TODO(), &responseWrapperMock{data: bytes.NewBufferString(logLines)}); err != nil {
 				t.Fatalf("%d: unexpected err %v", i, err)
@@ -260,6 +272,216 @@ func TestInclude(t *testing.T) {
 	}
 }
 
+func TestLevel(t *testing.T) {
+	tmpl := template.Must(template.New("").Parse(`{{with .Level}}[{{.}}] {{end}}{{.Message}}` + "\n"))
+	logLines := `2023-02-13T21:20:30.000000001Z level=info msg=a
+2023-02-13T21:20:30.000000002Z level=error msg=b
+2023-02-13T21:20:30.000000003Z level=error msg=b
+2023-02-13T21:20:31.000000001Z level=debug msg=c
+`
+
+	tests := []struct {
+		name     string
+		options  *TailOptions
+		expected string
+	}{
+		{
+			name:    "not detected",
+			options: &TailOptions{},
+			expected: `level=info msg=a
+level=error msg=b
+level=error msg=b
+level=debug msg=c
+`,
+		},
+		{
+			name:    "template",
+			options: &TailOptions{TemplateLevel: true},
+			expected: `[info] level=info msg=a
+[error] level=error msg=b
+[error] level=error msg=b
+[debug] level=debug msg=c
+`,
+		},
+		{
+			name: "context lines and repeats",
+			options: &TailOptions{
+				TemplateLevel: true,
+				MinLevel:      LevelInfo,
+				Include:       []*regexp.Regexp{regexp.MustCompile("msg=b")},
+				BeforeContext: 1,
+				RepeatMode:    RepeatExact,
+			},
+			expected: `[info] level=info msg=a
+[error] level=error msg=b
+last message repeated 1 time
+`,
+		},
+	}
+
+	clientset := fake.NewSimpleClientset()
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "my-namespace", Name: "my-pod"}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := new(bytes.Buffer)
+			tail := NewTail(clientset.CoreV1(), pod, "my-container", tmpl, out, io.Discard, tt.options, false)
+			if err := tail.ConsumeRequest(context.TODO(), &responseWrapperMock{data: bytes.NewBufferString(logLines)}); err != nil {
+				t.Fatalf("unexpected err %v", err)
+			}
+			if tt.expected != out.String() {
+				t.Errorf("expected `%s`, but actual `%s`", tt.expected, out)
+			}
+		})
+	}
+}
+
+func TestContextLines(t *testing.T) {
+	logLines := `2023-02-13T21:20:30.000000001Z log 1
+2023-02-13T21:20:30.000000002Z log 2
+2023-02-13T21:20:30.000000003Z error 3
+2023-02-13T21:20:30.000000004Z log 4
+2023-02-13T21:20:30.000000005Z log 5
+2023-02-13T21:20:30.000000006Z log 6
+2023-02-13T21:20:30.000000007Z log 7
+2023-02-13T21:20:30.000000008Z error 8
+2023-02-13T21:20:30.000000009Z log 9
+2023-02-13T21:20:31.000000001Z error 10
+2023-02-13T21:20:31.000000002Z log 11`
+	tmpl := template.Must(template.New("").Parse(`{{printf "%s\n" .Message}}`))
+
+	tests := []struct {
+		name     string
+		before   int
+		after    int
+		exclude  []*regexp.Regexp
+		omit     bool
+		expected string
+	}{
+		{
+			name: "no context",
+			expected: `error 3
+error 8
+error 10
+`,
+		},
+		{
+			name:  "after",
+			after: 1,
+			expected: `error 3
+log 4
+--
+error 8
+log 9
+error 10
+log 11
+`,
+		},
+		{
+			name:   "before",
+			before: 2,
+			expected: `log 1
+log 2
+error 3
+--
+log 6
+log 7
+error 8
+log 9
+error 10
+`,
+		},
+		{
+			name:   "before and after",
+			before: 1,
+			after:  1,
+			expected: `log 2
+error 3
+log 4
+--
+log 7
+error 8
+log 9
+error 10
+log 11
+`,
+		},
+		{
+			name:   "contiguous groups",
+			before: 2,
+			after:  2,
+			expected: `log 1
+log 2
+error 3
+log 4
+log 5
+log 6
+log 7
+error 8
+log 9
+error 10
+log 11
+`,
+		},
+		{
+			name:    "excluded lines are not context lines",
+			before:  1,
+			after:   1,
+			exclude: []*regexp.Regexp{regexp.MustCompile("log [27]")},
+			expected: `log 1
+error 3
+log 4
+--
+log 6
+error 8
+log 9
+error 10
+log 11
+`,
+		},
+		{
+			name:  "separator is omitted",
+			after: 1,
+			omit:  true,
+			expected: `error 3
+log 4
+error 8
+log 9
+error 10
+log 11
+`,
+		},
+	}
+
+	clientset := fake.NewSimpleClientset()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := new(bytes.Buffer)
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "my-namespace",
+					Name:      "my-pod",
+				},
+			}
+			options := &TailOptions{
+				Include:              []*regexp.Regexp{regexp.MustCompile("error")},
+				Exclude:              tt.exclude,
+				BeforeContext:        tt.before,
+				AfterContext:         tt.after,
+				OmitContextSeparator: tt.omit,
+			}
+
+			tail := NewTail(clientset.CoreV1(), pod, "my-container", tmpl, out, io.Discard, options, false)
+			if err := tail.ConsumeRequest(context.TODO(), &responseWrapperMock{data: bytes.NewBufferString(logLines)}); err != nil {
+				t.Fatalf("unexpected err %v", err)
+			}
+
+			if tt.expected != out.String() {
+				t.Errorf("expected `%s`, but actual `%s`", tt.expected, out)
+			}
+		})
+	}
+}
+
 type responseWrapperMock struct {
 	data io.Reader
 }
@@ -288,6 +510,13 @@ func TestPrintStarting(t *testing.T) {
 			},
 			[]byte("+ my-namespace my-pod › my-container\n"),
 		},
+		{
+			&TailOptions{
+				Namespace: true,
+				Context:   "my-context",
+			},
+			[]byte("+ my-context my-namespace my-pod › my-container\n"),
+		},
 		{
 			&TailOptions{
 				OnlyLogLines: true,
@@ -321,6 +550,68 @@ func TestPrintStarting(t *testing.T) {
 	}
 }
 
+func TestPrintStartingPrevious(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	errOut := new(bytes.Buffer)
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "my-namespace",
+			Name:      "my-pod",
+		},
+	}
+	tail := NewTail(clientset.CoreV1(), pod, "my-container", nil, io.Discard, errOut, &TailOptions{}, false)
+	tail.previous = true
+	tail.printStarting()
+	tail.printStopping()
+
+	expected := "+ my-pod › my-container (previous)\n- my-pod › my-container (previous)\n"
+	if errOut.String() != expected {
+		t.Errorf("expected %q, but actual %q", expected, errOut)
+	}
+}
+
+func TestResumePrevious(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	var actual *corev1.PodLogOptions
+	clientset.PrependReactor("get", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "log" {
+			return false, nil, nil
+		}
+		actual = action.(k8stesting.GenericAction).GetValue().(*corev1.PodLogOptions)
+		return true, &runtime.Unknown{Raw: []byte(`2023-02-13T21:20:30.000000001Z line 1
+2023-02-13T21:20:30.000000002Z line 2
+2023-02-13T21:20:31.000000001Z line 3
+`)}, nil
+	})
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "my-namespace", Name: "my-pod"}}
+	out := new(bytes.Buffer)
+	tmpl := template.Must(template.New("").Parse(`{{.Message}}` + "\n"))
+	tail := NewTail(clientset.CoreV1(), pod, "my-container", tmpl, out, io.Discard, &TailOptions{
+		Follow:    true,
+		TailLines: ptr.To[int64](10),
+	}, false)
+
+	// the last tail printed the first line
+	resumeRequest := &ResumeRequest{Timestamp: "2023-02-13T21:20:30Z", LinesToSkip: 1}
+	if err := tail.ResumePrevious(context.TODO(), resumeRequest); err != nil {
+		t.Fatalf("unexpected err %v", err)
+	}
+
+	sinceTime := metav1.NewTime(time.Date(2023, 2, 13, 21, 20, 30, 0, time.UTC))
+	expected := &corev1.PodLogOptions{
+		Container:  "my-container",
+		Previous:   true,
+		Timestamps: true,
+		SinceTime:  &sinceTime,
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %+v, but actual %+v", expected, actual)
+	}
+	if expected := "line 2\nline 3\n"; out.String() != expected {
+		t.Errorf("expected %q, but actual %q", expected, out)
+	}
+}
+
 func TestPrintStopping(t *testing.T) {
 	tests := []struct {
 		options  *TailOptions
@@ -336,6 +627,13 @@ func TestPrintStopping(t *testing.T) {
 			},
 			[]byte("- my-namespace my-pod › my-container\n"),
 		},
+		{
+			&TailOptions{
+				Namespace: true,
+				Context:   "my-context",
+			},
+			[]byte("- my-context my-namespace my-pod › my-container\n"),
+		},
 		{
 			&TailOptions{
 				OnlyLogLines: true,
@@ -3,8 +3,11 @@ package stern
 import (
 	"errors"
 	"regexp"
+	"slices"
 	"sort"
 	"strings"
+	"text/template"
+	"text/template/parse"
 	"time"
 
 	"github.com/fatih/color"
@@ -22,9 +25,33 @@ type Log struct {
 	// from Message so that templates can still parse Message as JSON.
 	Timestamp string `json:"timestamp,omitempty"`
 
+	// Level is the level detected from the message such as "warn" and
+	// "error". It is empty if no level is detected, or unless the template
+	// might print it or --min-level is specified.
+	Level string `json:"level,omitempty"`
+
+	// RepeatCount is the number of the repeated messages suppressed before
+	// this message, which reports them. It is zero for other messages.
+	RepeatCount int `json:"repeatCount,omitempty"`
+
+	// Event is the lifecycle transition of the container printed with
+	// --lifecycle-events. It is nil for log lines.
+	Event *ContainerEvent `json:"event,omitempty"`
+
+	// Kind is "Event" for the Kubernetes events printed with --events. It is
+	// empty for log lines.
+	Kind string `json:"kind,omitempty"`
+
+	// Context is the kubeconfig context of the cluster where the pod runs.
+	// It is empty unless multiple contexts are specified.
+	Context string `json:"context,omitempty"`
+
 	// Node name of the pod
 	NodeName string `json:"nodeName"`
 
+	// NodeLabels are the labels of the node specified by --node-label
+	NodeLabels map[string]string `json:"nodeLabels,omitempty"`
+
 	// Namespace of the pod
 	Namespace string `json:"namespace"`
 
@@ -51,13 +78,38 @@ type TailOptions struct {
 
 	SinceSeconds *int64
 	SinceTime    *metav1.Time
-	Exclude      []*regexp.Regexp
-	Include      []*regexp.Regexp
-	Highlight    []*regexp.Regexp
-	Namespace    bool
-	TailLines    *int64
-	Follow       bool
-	OnlyLogLines bool
+	// the tail stops when a line after this time comes if not nil
+	Until     *time.Time
+	Exclude   []*regexp.Regexp
+	Include   []*regexp.Regexp
+	Highlight []*regexp.Regexp
+	// filters on the fields of JSON or logfmt lines, all of which must match
+	FieldFilters       []*FieldFilter
+	UnstructuredPolicy UnstructuredPolicy
+	// joins multi-line entries such as stack traces if not nil
+	Multiline        *Multiline
+	MultilineTimeout time.Duration
+	// rules to redact secrets from messages before the output
+	Redact []*RedactRule
+	// collapses consecutive repeated messages unless RepeatOff
+	RepeatMode   RepeatMode
+	RepeatWindow time.Duration
+	// lines below this level are dropped, but lines without a level are kept
+	MinLevel Level
+	// detects the levels of the lines for .Level in the template
+	TemplateLevel bool
+	// the number of lines to show before and after the matching lines
+	BeforeContext int
+	AfterContext  int
+	// omits ContextSeparator between the groups of context lines, which is
+	// not a record of JSON outputs
+	OmitContextSeparator bool
+	Namespace            bool
+	Context              string
+	NodeLabels           map[string]string
+	TailLines            *int64
+	Follow               bool
+	OnlyLogLines         bool
 
 	// regexp for highlighting the matched string
 	reHightlight *regexp.Regexp
@@ -74,22 +126,93 @@ func (o TailOptions) IsExclude(msg string) bool {
 }
 
 func (o TailOptions) IsInclude(msg string) bool {
+	return o.isInclude(msg, nil)
+}
+
+// isInclude is IsInclude calling matched with each include pattern matching
+// the message. All the patterns are evaluated if matched is not nil.
+func (o TailOptions) isInclude(msg string, matched func(re *regexp.Regexp)) bool {
 	if len(o.Include) == 0 {
 		return true
 	}
 
+	found := false
 	for _, rin := range o.Include {
 		if rin.MatchString(msg) {
-			return true
+			if matched == nil {
+				return true
+			}
+			matched(rin)
+			found = true
 		}
 	}
 
-	return false
+	return found
+}
+
+// IsAfterUntil returns if the RFC3339Nano timestamp of a line is after Until
+func (o TailOptions) IsAfterUntil(rfc3339Nano string) bool {
+	if o.Until == nil {
+		return false
+	}
+	ts, err := time.Parse(time.RFC3339Nano, rfc3339Nano)
+	if err != nil {
+		return false
+	}
+	return ts.After(*o.Until)
+}
+
+// RedactMessage returns the message with the secrets redacted by the rules
+func (o TailOptions) RedactMessage(msg string) string {
+	for _, r := range o.Redact {
+		msg = r.Redact(msg)
+	}
+	return msg
+}
+
+// isBelowMinLevel returns if the level is detected and lower than MinLevel
+func (o TailOptions) isBelowMinLevel(level Level) bool {
+	return o.MinLevel != LevelUnknown && level != LevelUnknown && level < o.MinLevel
+}
+
+// detectLevel returns the level of the message if it is used by MinLevel or
+// the template, or LevelUnknown otherwise
+func (o TailOptions) detectLevel(msg string) Level {
+	if o.MinLevel == LevelUnknown && !o.TemplateLevel {
+		return LevelUnknown
+	}
+	return DetectLevel(msg)
+}
+
+// IsFieldMatch returns if the message matches all the field filters. Lines
+// that are neither JSON nor logfmt are decided by UnstructuredPolicy.
+func (o TailOptions) IsFieldMatch(msg string) bool {
+	if len(o.FieldFilters) == 0 {
+		return true
+	}
+
+	fields, ok := parseStructuredLog(msg)
+	if !ok {
+		return o.UnstructuredPolicy == UnstructuredKeep
+	}
+	for _, f := range o.FieldFilters {
+		if !f.Matches(fields) {
+			return false
+		}
+	}
+
+	return true
 }
 
 var colorHighlight = color.New(color.FgRed, color.Bold).SprintFunc()
 
 func (o TailOptions) HighlightMatchedString(msg string) string {
+	return o.highlightMatchedString(msg, nil)
+}
+
+// highlightMatchedString is HighlightMatchedString calling matched once with
+// each pattern of --highlight that highlights a part of the message
+func (o TailOptions) highlightMatchedString(msg string, matched func(re *regexp.Regexp)) string {
 	highlight := append(o.Include, o.Highlight...)
 	if len(highlight) == 0 {
 		return msg
@@ -109,9 +232,20 @@ func (o TailOptions) HighlightMatchedString(msg string) string {
 		o.reHightlight = regexp.MustCompile("(" + strings.Join(ss, "|") + ")")
 	}
 
+	var found []*regexp.Regexp
 	msg = o.reHightlight.ReplaceAllStringFunc(msg, func(part string) string {
+		if matched != nil {
+			for _, re := range o.Highlight {
+				if !slices.Contains(found, re) && re.MatchString(part) {
+					found = append(found, re)
+				}
+			}
+		}
 		return colorHighlight(part)
 	})
+	for _, re := range found {
+		matched(re)
+	}
 
 	return msg
 }
@@ -127,3 +261,53 @@ func (o TailOptions) UpdateTimezoneAndFormat(timestamp string) (string, error) {
 	}
 	return t.In(o.Location).Format(format), nil
 }
+
+// templateRefersTo returns if the template refers to the field of Log like
+// "{{.Level}}", or passes the whole Log to a function like "{{json .}}"
+func templateRefersTo(tmpl *template.Template, field string) bool {
+	if tmpl == nil {
+		return false
+	}
+	// root is true while the dot is the Log, which is changed by with and
+	// range
+	var walk func(node parse.Node, root bool) bool
+	walkAll := func(nodes []parse.Node, root bool) bool {
+		return slices.ContainsFunc(nodes, func(n parse.Node) bool { return walk(n, root) })
+	}
+	walk = func(node parse.Node, root bool) bool {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n != nil {
+				return walkAll(n.Nodes, root)
+			}
+		case *parse.ActionNode:
+			return walk(n.Pipe, root)
+		case *parse.PipeNode:
+			if n != nil {
+				return slices.ContainsFunc(n.Cmds, func(c *parse.CommandNode) bool { return walk(c, root) })
+			}
+		case *parse.CommandNode:
+			return walkAll(n.Args, root)
+		case *parse.DotNode:
+			return root
+		case *parse.FieldNode:
+			return slices.Contains(n.Ident, field)
+		case *parse.VariableNode:
+			return slices.Equal(n.Ident, []string{"$"}) || slices.Contains(n.Ident, field)
+		case *parse.ChainNode:
+			return slices.Contains(n.Field, field) || walk(n.Node, root)
+		case *parse.IfNode:
+			return walk(n.Pipe, root) || walk(n.List, root) || walk(n.ElseList, root)
+		case *parse.RangeNode:
+			return walk(n.Pipe, root) || walk(n.List, false) || walk(n.ElseList, root)
+		case *parse.WithNode:
+			return walk(n.Pipe, root) || walk(n.List, false) || walk(n.ElseList, root)
+		case *parse.TemplateNode:
+			return walk(n.Pipe, root)
+		}
+		return false
+	}
+	return slices.ContainsFunc(tmpl.Templates(), func(t *template.Template) bool {
+		return t.Tree != nil && walk(t.Tree.Root, true)
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"regexp"
 	"testing"
+	"text/template"
 	"time"
 
 	"github.com/fatih/color"
@@ -42,6 +43,70 @@ func TestIsIncludeTestOptions(t *testing.T) {
 	}
 }
 
+func TestTemplateRefersTo(t *testing.T) {
+	tests := []struct {
+		tmpl     string
+		expected bool
+	}{
+		{`{{.Message}}`, false},
+		{`{{json .}}`, true},
+		{`{{json $}}`, true},
+		{`{{with .Message}}{{json .}}{{end}}`, false},
+		{`{{range .Labels}}{{.}}{{end}}`, false},
+		{`{{.Level}} {{.Message}}`, true},
+		{`{{levelColor .Level}}`, true},
+		{`{{if eq .Level "error"}}!{{end}}{{.Message}}`, true},
+		{`{{with .Message}}{{else}}{{$.Level}}{{end}}`, true},
+		{`{{define "l"}}{{.Level}}{{end}}{{template "l" .}}`, true},
+		{`{{with $d := .Message | parseJSON}}{{$d.level}}{{end}}`, false},
+	}
+
+	funcs := template.FuncMap{
+		"json":       func(any) string { return "" },
+		"levelColor": func(string) string { return "" },
+		"parseJSON":  func(string) map[string]any { return nil },
+	}
+	for i, tt := range tests {
+		tmpl := template.Must(template.New("").Funcs(funcs).Parse(tt.tmpl))
+		if actual := templateRefersTo(tmpl, "Level"); actual != tt.expected {
+			t.Errorf("%d: expected %v, but actual %v", i, tt.expected, actual)
+		}
+	}
+}
+
+func TestIsFieldMatch(t *testing.T) {
+	filters := []*FieldFilter{
+		mustNewFieldFilter("level in (error,fatal)"),
+		mustNewFieldFilter("status >= 500"),
+	}
+
+	tests := []struct {
+		msg          string
+		unstructured UnstructuredPolicy
+		expected     bool
+	}{
+		{`{"level":"error","status":503}`, UnstructuredDrop, true},
+		{`{"level": "error", "status": 404}`, UnstructuredDrop, false},
+		{`{"level":"info","status":500}`, UnstructuredDrop, false},
+		{`level=fatal status=500 msg="upstream failed"`, UnstructuredDrop, true},
+		{`level=warn status=500`, UnstructuredDrop, false},
+		{"ERROR upstream failed", UnstructuredDrop, false},
+		{"ERROR upstream failed", UnstructuredKeep, true},
+		{`{"level":"info"`, UnstructuredKeep, true},
+	}
+
+	for i, tt := range tests {
+		o := &TailOptions{FieldFilters: filters, UnstructuredPolicy: tt.unstructured}
+		if actual := o.IsFieldMatch(tt.msg); actual != tt.expected {
+			t.Errorf("%d: expected %v, but actual %v", i, tt.expected, actual)
+		}
+	}
+
+	if o := (&TailOptions{}); !o.IsFieldMatch("plain text") {
+		t.Errorf("expected to match without field filters")
+	}
+}
+
 func TestUpdateTimezoneAndFormat(t *testing.T) {
 	location, _ := time.LoadLocation("Asia/Tokyo")
 
@@ -15,11 +15,13 @@
 package stern
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"sync"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/klog/v2"
 )
 
@@ -27,6 +29,10 @@ import (
 type Target struct {
 	Pod       *corev1.Pod
 	Container string
+
+	// restarted is true when the container replaced the previous one whose
+	// logs have been shown
+	restarted bool
 }
 
 // GetID returns the ID of the object
@@ -40,11 +46,25 @@ type targetState struct {
 	containerID string
 }
 
+// containerStatus holds a last seen status of a container to find the
+// lifecycle transitions
+type containerStatus struct {
+	podUID string
+	status corev1.ContainerStatus
+}
+
+// targetStates holds the last shown states of targets
+type targetStates struct {
+	m        map[string]*targetState
+	statuses map[string]*containerStatus
+	scopes   map[*watchScope]struct{} // the pod watches sharing the states
+	mu       sync.RWMutex
+}
+
 // targetFilter is a filter of Target
 type targetFilter struct {
 	c            targetFilterConfig
-	targetStates map[string]*targetState
-	mu           sync.RWMutex
+	targetStates *targetStates
 }
 
 type targetFilterConfig struct {
@@ -56,27 +76,49 @@ type targetFilterConfig struct {
 	initContainers         bool
 	ephemeralContainers    bool
 	containerStates        []ContainerState
+	owner                  *ownerMatcher
+	nodes                  *objectWatcher    // nodes matching the node selector, nil if not specified
+	endpoints              *endpointsWatcher // ready endpoints of the service, nil if not specified
+	namespaces             *namespaceFilter  // nil unless namespaces are discovered dynamically
+	annotationSelector     labels.Selector
+	podFilters             []*PodFilter
+	metrics                *Metrics
+	// lifecycle prints the lifecycle transitions of the containers if not nil
+	lifecycle *lifecyclePrinter
+	// events prints the Kubernetes events of the matched pods if not nil
+	events *eventWatcher
 }
 
 func newTargetFilter(c targetFilterConfig) *targetFilter {
+	return &targetFilter{
+		c: c,
+		targetStates: &targetStates{
+			m:        make(map[string]*targetState),
+			statuses: make(map[string]*containerStatus),
+			scopes:   make(map[*watchScope]struct{}),
+		},
+	}
+}
+
+// withPodFilter returns a filter with the different pod filter and owner.
+// The returned filter shares the target states with the original one, so
+// a target is not added twice even if it matches both filters.
+func (f *targetFilter) withPodFilter(podFilter *regexp.Regexp, owner *ownerMatcher) *targetFilter {
+	c := f.c
+	c.podFilter = podFilter
+	c.owner = owner
 	return &targetFilter{
 		c:            c,
-		targetStates: make(map[string]*targetState),
+		targetStates: f.targetStates,
 	}
 }
 
 // visit passes filtered Targets to the visitor function
-func (f *targetFilter) visit(pod *corev1.Pod, visitor func(t *Target, conditionFound bool)) {
-	// filter by pod
-	if !f.c.podFilter.MatchString(pod.Name) {
+func (f *targetFilter) visit(ctx context.Context, pod *corev1.Pod, visitor func(t *Target, conditionFound bool)) {
+	if !f.matchPod(ctx, pod) {
 		return
 	}
-
-	for _, re := range f.c.excludePodFilter {
-		if re.MatchString(pod.Name) {
-			return
-		}
-	}
+	f.c.events.add(pod)
 
 	// filter by condition
 	conditionFound := true
@@ -114,6 +156,12 @@ OUTER:
 			Container: c.Name,
 		}
 
+		if f.c.lifecycle != nil {
+			if events := f.lifecycleEvents(t, string(pod.UID), c); len(events) > 0 {
+				f.c.lifecycle.print(t, events)
+			}
+		}
+
 		if !conditionFound {
 			visitor(t, false)
 			f.forget(string(pod.UID))
@@ -126,6 +174,61 @@ OUTER:
 	}
 }
 
+// matchPod returns if the pod passes the filters that do not depend on containers
+func (f *targetFilter) matchPod(ctx context.Context, pod *corev1.Pod) bool {
+	// filter by pod
+	if !f.c.podFilter.MatchString(pod.Name) {
+		return false
+	}
+
+	for _, re := range f.c.excludePodFilter {
+		if re.MatchString(pod.Name) {
+			return false
+		}
+	}
+
+	// filter by namespace
+	if f.c.namespaces != nil && !f.c.namespaces.Matches(pod.Namespace) {
+		return false
+	}
+
+	// filter by node
+	if f.c.nodes != nil && !f.c.nodes.Has(pod.Spec.NodeName) {
+		return false
+	}
+
+	// filter by annotations
+	if f.c.annotationSelector != nil && !f.c.annotationSelector.Matches(labels.Set(pod.Annotations)) {
+		return false
+	}
+
+	// filter by pod fields
+	if len(f.c.podFilters) > 0 {
+		obj, err := toUnstructuredPod(pod)
+		if err != nil {
+			klog.V(7).InfoS("Failed to convert the pod", "pod", pod.Name, "err", err)
+			return false
+		}
+		for _, pf := range f.c.podFilters {
+			if !pf.Matches(obj) {
+				return false
+			}
+		}
+	}
+
+	// filter by owner
+	if f.c.owner != nil && !f.c.owner.Matches(ctx, pod) {
+		return false
+	}
+
+	// filter by endpoints
+	if f.c.endpoints != nil && !f.c.endpoints.Has(pod) {
+		return false
+	}
+
+	return true
+}
+
 func (f *targetFilter) matchContainerState(state corev1.ContainerState) bool {
 	for _, containerState := range f.c.containerStates {
 		if containerState.Match(state) {
@@ -139,10 +242,10 @@ func (f *targetFilter) shouldAdd(t *Target, podUID string, cs corev1.ContainerSt
 	state := stateToString(cs.State)
 	containerID := chooseContainerID(cs)
 
-	f.mu.Lock()
-	last := f.targetStates[t.GetID()]
-	f.targetStates[t.GetID()] = &targetState{podUID: podUID, containerID: containerID}
-	f.mu.Unlock()
+	f.targetStates.mu.Lock()
+	last := f.targetStates.m[t.GetID()]
+	f.targetStates.m[t.GetID()] = &targetState{podUID: podUID, containerID: containerID}
+	f.targetStates.mu.Unlock()
 
 	if containerID == "" {
 		// does not have a container to retrieve logs
@@ -167,25 +270,56 @@ func (f *targetFilter) shouldAdd(t *Target, podUID string, cs corev1.ContainerSt
 	// add a container when the container ID is changed from the last time
 	klog.V(7).InfoS("Container ID was changed",
 		"state", state, "target", t.GetID(), "container", containerID, "last", last.containerID)
+	lastTerminated := cs.LastTerminationState.Terminated
+	t.restarted = lastTerminated != nil && lastTerminated.ContainerID == last.containerID
 	return true
 }
 
+// lifecycleEvents returns the lifecycle transitions since the last status of
+// the container. It returns nothing when the container is seen the first time.
+func (f *targetFilter) lifecycleEvents(t *Target, podUID string, cs corev1.ContainerStatus) []ContainerEvent {
+	f.targetStates.mu.Lock()
+	last := f.targetStates.statuses[t.GetID()]
+	f.targetStates.statuses[t.GetID()] = &containerStatus{podUID: podUID, status: cs}
+	f.targetStates.mu.Unlock()
+
+	if last == nil || last.podUID != podUID {
+		return nil
+	}
+	return containerEvents(last.status, cs)
+}
+
 func (f *targetFilter) forget(podUID string) {
-	f.mu.Lock()
-	defer f.mu.Unlock()
+	f.targetStates.mu.Lock()
+	defer f.targetStates.mu.Unlock()
 	// delete target states belonging to the pod
-	for targetID, state := range f.targetStates {
+	for targetID, state := range f.targetStates.m {
 		if state.podUID == podUID {
 			klog.V(7).InfoS("Forget targetState", "target", targetID)
-			delete(f.targetStates, targetID)
+			delete(f.targetStates.m, targetID)
+		}
+	}
+}
+
+// remove forgets the pod that is deleted or no longer matches, including the
+// last statuses of its containers, and stops printing its events
+func (f *targetFilter) remove(podUID string) {
+	f.forget(podUID)
+	f.targetStates.mu.Lock()
+	for targetID, status := range f.targetStates.statuses {
+		if status.podUID == podUID {
+			delete(f.targetStates.statuses, targetID)
 		}
 	}
+	f.targetStates.mu.Unlock()
+	f.c.events.remove(podUID)
+	f.c.lifecycle.remove(podUID)
 }
 
 func (f *targetFilter) isActive(t *Target) bool {
-	f.mu.RLock()
-	defer f.mu.RUnlock()
-	last := f.targetStates[t.GetID()]
+	f.targetStates.mu.RLock()
+	defer f.targetStates.mu.RUnlock()
+	last := f.targetStates.m[t.GetID()]
 	return last != nil && last.containerID != ""
 }
 
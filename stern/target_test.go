@@ -1,12 +1,15 @@
 package stern
 
 import (
+	"context"
 	"reflect"
 	"regexp"
 	"testing"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 func TestTargetFilter(t *testing.T) {
@@ -155,6 +158,59 @@ func TestTargetFilter(t *testing.T) {
 				genTarget("node1", "pod1", "ephemeral-container3-waiting"),
 			},
 		},
+		{
+			name: "filter by nodes",
+			config: targetFilterConfig{
+				podFilter:              regexp.MustCompile(``),
+				excludePodFilter:       nil,
+				containerFilter:        regexp.MustCompile(`.*`),
+				containerExcludeFilter: nil,
+				initContainers:         false,
+				ephemeralContainers:    false,
+				containerStates:        []ContainerState{RUNNING, TERMINATED, WAITING},
+				nodes:                  &objectWatcher{objects: map[string]map[string]string{"node2": nil}},
+			},
+			expected: []Target{
+				genTarget("node2", "pod2", "container1-running"),
+				genTarget("node2", "pod2", "container2-terminated"),
+				genTarget("node2", "pod2", "container3-waiting"),
+			},
+		},
+		{
+			name: "filter by podFilters",
+			config: targetFilterConfig{
+				podFilter:              regexp.MustCompile(``),
+				excludePodFilter:       nil,
+				containerFilter:        regexp.MustCompile(`.*`),
+				containerExcludeFilter: nil,
+				initContainers:         false,
+				ephemeralContainers:    false,
+				containerStates:        []ContainerState{RUNNING, TERMINATED, WAITING},
+				podFilters: []*PodFilter{
+					mustNewPodFilter(`{.spec.nodeName}=node2`),
+					mustNewPodFilter(`{.status.containerStatuses[*].name}=~running`),
+				},
+			},
+			expected: []Target{
+				genTarget("node2", "pod2", "container1-running"),
+				genTarget("node2", "pod2", "container2-terminated"),
+				genTarget("node2", "pod2", "container3-waiting"),
+			},
+		},
+		{
+			name: "filter by annotationSelector",
+			config: targetFilterConfig{
+				podFilter:              regexp.MustCompile(``),
+				excludePodFilter:       nil,
+				containerFilter:        regexp.MustCompile(`.*`),
+				containerExcludeFilter: nil,
+				initContainers:         true,
+				ephemeralContainers:    true,
+				containerStates:        []ContainerState{RUNNING, TERMINATED, WAITING},
+				annotationSelector:     labels.SelectorFromSet(labels.Set{"team": "payments"}),
+			},
+			expected: []Target{},
+		},
 		{
 			name: "filter by containerFilter",
 			config: targetFilterConfig{
@@ -307,7 +363,7 @@ func TestTargetFilter(t *testing.T) {
 			actual := []Target{}
 			for _, pod := range pods {
 				filter := newTargetFilter(tt.config)
-				filter.visit(pod, func(target *Target, condition bool) {
+				filter.visit(context.Background(), pod, func(target *Target, condition bool) {
 					actual = append(actual, *target)
 				})
 			}
@@ -358,6 +414,16 @@ func TestTargetFilterShouldAdd(t *testing.T) {
 			Container: containerName,
 		}
 	}
+	restarted := corev1.ContainerStatus{
+		Name:        "c1",
+		ContainerID: "cid3",
+		State: corev1.ContainerState{
+			Running: &corev1.ContainerStateRunning{},
+		},
+		LastTerminationState: corev1.ContainerState{
+			Terminated: &corev1.ContainerStateTerminated{ContainerID: "cid2"},
+		},
+	}
 	tests := []struct {
 		name     string
 		forget   bool
@@ -402,6 +468,11 @@ func TestTargetFilterShouldAdd(t *testing.T) {
 			},
 			expected: []Target{genTarget("c1", "cid2")},
 		},
+		{
+			name:     "restarted container replaces the last one",
+			cs:       restarted,
+			expected: []Target{{Pod: createPod(restarted), Container: "c1", restarted: true}},
+		},
 		{
 			name:   "forget() allows the same ID ",
 			forget: true,
@@ -421,7 +492,7 @@ func TestTargetFilterShouldAdd(t *testing.T) {
 				filter.forget("uid1")
 			}
 			actual := []Target{}
-			filter.visit(createPod(tt.cs), func(target *Target, condition bool) {
+			filter.visit(context.Background(), createPod(tt.cs), func(target *Target, condition bool) {
 				actual = append(actual, *target)
 			})
 			if !reflect.DeepEqual(tt.expected, actual) {
@@ -431,6 +502,50 @@ func TestTargetFilterShouldAdd(t *testing.T) {
 	}
 }
 
+func TestTargetFilterWithPodFilter(t *testing.T) {
+	filter := newTargetFilter(targetFilterConfig{
+		podFilter:       regexp.MustCompile(`pod1`),
+		containerFilter: regexp.MustCompile(`.*`),
+		containerStates: []ContainerState{RUNNING},
+	})
+	// another query that matches all pods
+	another := filter.withPodFilter(regexp.MustCompile(``), nil)
+
+	createPod := func(name, uid string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "ns1",
+				Name:      name,
+				UID:       types.UID(uid),
+			},
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{
+					{
+						Name:        "c1",
+						ContainerID: "cid-" + uid,
+						State:       corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+					},
+				},
+			},
+		}
+	}
+
+	var actual []string
+	visitor := func(target *Target, condition bool) {
+		actual = append(actual, target.GetID())
+	}
+	for _, pod := range []*corev1.Pod{createPod("pod1", "uid1"), createPod("pod2", "uid2")} {
+		filter.visit(context.Background(), pod, visitor)
+		another.visit(context.Background(), pod, visitor)
+	}
+
+	// pod1 matches both filters, but it should be added only once
+	expected := []string{"ns1-pod1-c1", "ns1-pod2-c1"}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %v, but actual %v", expected, actual)
+	}
+}
+
 func TestChooseContainerID(t *testing.T) {
 	lastState := corev1.ContainerState{
 		Terminated: &corev1.ContainerStateTerminated{
@@ -540,3 +655,11 @@ func TestChooseContainerID(t *testing.T) {
 		})
 	}
 }
+
+func mustNewPodFilter(expr string) *PodFilter {
+	f, err := NewPodFilter(expr)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
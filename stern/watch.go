@@ -16,8 +16,9 @@ package stern
 
 import (
 	"context"
-
-	"github.com/pkg/errors"
+	"maps"
+	"slices"
+	"sync"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -25,61 +26,350 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/watch"
 	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
-	"k8s.io/client-go/tools/cache"
-	watchtools "k8s.io/client-go/tools/watch"
+	"k8s.io/klog/v2"
 )
 
 // Watch starts listening to Kubernetes events and emits modified
 // containers/pods. The result is targets added.
 func WatchTargets(ctx context.Context, i v1.PodInterface, labelSelector labels.Selector, fieldSelector fields.Selector, filter *targetFilter) (added, deleted chan *Target, err error) {
-	// RetryWatcher will make sure that in case the underlying watcher is
-	// closed (e.g. due to API timeout or etcd timeout) it will get restarted
-	// from the last point without the consumer even knowing about it.
-	watcher, err := watchtools.NewRetryWatcherWithContext(ctx, "1", &cache.ListWatch{
-		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
-			return i.Watch(ctx, metav1.ListOptions{LabelSelector: labelSelector.String(), FieldSelector: fieldSelector.String()})
-		},
+	added = make(chan *Target)
+	deleted = make(chan *Target)
+	done, err := retryWatch(ctx, "pods", "1", filter.c.metrics, func(options metav1.ListOptions) (watch.Interface, error) {
+		return i.Watch(ctx, metav1.ListOptions{LabelSelector: labelSelector.String(), FieldSelector: fieldSelector.String()})
+	}, func(e watch.Event) {
+		pod, ok := e.Object.(*corev1.Pod)
+		if !ok {
+			return
+		}
+
+		switch e.Type {
+		case watch.Added, watch.Modified:
+			filter.visit(ctx, pod, func(t *Target, conditionFound bool) {
+				ch := added
+				if !conditionFound {
+					ch = deleted
+				}
+				// The receiver might stop receiving when the watch is restarted
+				select {
+				case ch <- t:
+				case <-ctx.Done():
+				}
+			})
+		case watch.Deleted:
+			filter.remove(string(pod.UID))
+		}
 	})
 	if err != nil {
-		return nil, nil, errors.Wrap(err, "failed to create a watcher")
+		return nil, nil, err
+	}
+	// The receiver knows that the watch has ended, including when it is
+	// closed because of error
+	go func() {
+		<-done
+		close(added)
+	}()
+
+	return added, deleted, nil
+}
+
+// watchScope is the scope of a pod watch. The watches of all the queries
+// share the target states, so a target added by one watch is not added by
+// the others, and it is deleted only when no watch matches it.
+type watchScope struct {
+	namespace string // empty for all namespaces
+	filter    *targetFilter
+
+	// selector and targets are guarded by the lock of the target states
+	selector labels.Selector
+	// targets holds the targets added by the watch or handed over by the
+	// others to find ones that no longer match
+	targets map[string]*Target
+}
+
+// addScope registers the scope of a watch, and the returned function
+// unregisters it
+func (f *targetFilter) addScope(s *watchScope) (remove func()) {
+	f.targetStates.mu.Lock()
+	defer f.targetStates.mu.Unlock()
+
+	f.targetStates.scopes[s] = struct{}{}
+	return func() {
+		f.targetStates.mu.Lock()
+		defer f.targetStates.mu.Unlock()
+
+		delete(f.targetStates.scopes, s)
+	}
+}
+
+// setScopeSelector updates the label selector of the scope
+func (f *targetFilter) setScopeSelector(s *watchScope, selector labels.Selector) {
+	f.targetStates.mu.Lock()
+	defer f.targetStates.mu.Unlock()
+
+	s.selector = selector
+}
+
+// scopeTargets returns the targets of the scope
+func (f *targetFilter) scopeTargets(s *watchScope) []*Target {
+	f.targetStates.mu.RLock()
+	defer f.targetStates.mu.RUnlock()
+
+	return slices.Collect(maps.Values(s.targets))
+}
+
+// setScopeTarget adds the target to the scope, or removes it if remove
+func (f *targetFilter) setScopeTarget(s *watchScope, t *Target, remove bool) {
+	f.targetStates.mu.Lock()
+	defer f.targetStates.mu.Unlock()
+
+	if remove {
+		delete(s.targets, t.GetID())
+	} else {
+		s.targets[t.GetID()] = t
+	}
+}
+
+// handOver moves the target of the scope to another watch matching the pod,
+// which deletes the target when it no longer matches. It returns false if no
+// other watch matches the pod.
+func (f *targetFilter) handOver(ctx context.Context, self *watchScope, t *Target) bool {
+	type candidate struct {
+		scope    *watchScope
+		selector labels.Selector
+	}
+	f.targetStates.mu.RLock()
+	var others []candidate
+	for s := range f.targetStates.scopes {
+		if s != self && (s.namespace == "" || s.namespace == t.Pod.Namespace) {
+			others = append(others, candidate{s, s.selector})
+		}
+	}
+	f.targetStates.mu.RUnlock()
+
+	// matchPod is called without the lock because it might call the API
+	for _, c := range others {
+		if !c.selector.Matches(labels.Set(t.Pod.Labels)) || !c.scope.filter.matchPod(ctx, t.Pod) {
+			continue
+		}
+		f.targetStates.mu.Lock()
+		_, registered := f.targetStates.scopes[c.scope]
+		if registered {
+			c.scope.targets[t.GetID()] = t
+			delete(self.targets, t.GetID())
+		}
+		f.targetStates.mu.Unlock()
+		if registered {
+			return true
+		}
+	}
+	return false
+}
+
+// watchTargetsWithSelector is like WatchTargets, but restarts the watch with
+// the label selector received from selectors. When it is notified by refresh,
+// the targets are re-evaluated in place, and the pods that start to match are
+// listed without restarting the watch. Targets that were added by the watch
+// and no longer match are sent to deleted unless the watch of another query
+// matches them.
+func watchTargetsWithSelector(ctx context.Context, i v1.PodInterface, namespace string, labelSelector labels.Selector, selectors <-chan labels.Selector, refresh <-chan struct{}, fieldSelector fields.Selector, filter *targetFilter) (added, deleted chan *Target, err error) {
+	wctx, wcancel := context.WithCancel(ctx)
+	a, d, err := WatchTargets(wctx, i, labelSelector, fieldSelector, filter)
+	if err != nil {
+		wcancel()
+		return nil, nil, err
 	}
+	scope := &watchScope{
+		namespace: namespace,
+		filter:    filter,
+		selector:  labelSelector,
+		targets:   make(map[string]*Target),
+	}
+	removeScope := filter.addScope(scope)
 
 	added = make(chan *Target)
 	deleted = make(chan *Target)
-	go func() {
+	go func(a, d chan *Target, wcancel context.CancelFunc) {
+		defer func() { wcancel() }()
+		defer removeScope()
+		send := func(ch chan *Target, t *Target) bool {
+			select {
+			case ch <- t:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		drop := func() bool {
+			for _, t := range filter.scopeTargets(scope) {
+				if labelSelector.Matches(labels.Set(t.Pod.Labels)) && filter.matchPod(ctx, t.Pod) {
+					continue
+				}
+				if filter.handOver(ctx, scope, t) {
+					continue
+				}
+				filter.setScopeTarget(scope, t, true)
+				filter.remove(string(t.Pod.UID))
+				if !send(deleted, t) {
+					return false
+				}
+			}
+			return true
+		}
+		// restart replaces the watch with the one of the selector. The last
+		// watch is kept if the new one fails to start.
+		restart := func(s labels.Selector) bool {
+			nctx, ncancel := context.WithCancel(ctx)
+			na, nd, err := WatchTargets(nctx, i, s, fieldSelector, filter)
+			if err != nil {
+				ncancel()
+				klog.V(7).InfoS("Failed to restart the pod watch", "err", err)
+				return true
+			}
+			filter.c.metrics.countWatchRestart("pods")
+			wcancel()
+			a, d, wcancel = na, nd, ncancel
+			labelSelector = s
+			filter.setScopeSelector(scope, s)
+			return drop()
+		}
+		// reevaluate drops the targets that no longer match, and adds the
+		// targets of the pods that were rejected by the filter before
+		reevaluate := func() bool {
+			if !drop() {
+				return false
+			}
+			pods, err := i.List(ctx, metav1.ListOptions{LabelSelector: labelSelector.String(), FieldSelector: fieldSelector.String()})
+			if err != nil {
+				// The targets are added by the next change of the pods
+				klog.V(7).InfoS("Failed to list pods to refresh targets", "err", err)
+				return true
+			}
+			for idx := range pods.Items {
+				ok := true
+				filter.visit(ctx, &pods.Items[idx], func(t *Target, conditionFound bool) {
+					if !ok {
+						return
+					}
+					filter.setScopeTarget(scope, t, !conditionFound)
+					if conditionFound {
+						ok = send(added, t)
+					} else {
+						ok = send(deleted, t)
+					}
+				})
+				if !ok {
+					return false
+				}
+			}
+			return true
+		}
 		for {
 			select {
-			case e := <-watcher.ResultChan():
-				if e.Object == nil {
-					// Closed because of error
+			case t, ok := <-a:
+				if !ok {
 					close(added)
 					return
 				}
-
-				pod, ok := e.Object.(*corev1.Pod)
-				if !ok {
+				for _, t := range filter.scopeTargets(scope) {
+					if !filter.isActive(t) {
+						filter.setScopeTarget(scope, t, true)
+					}
+				}
+				filter.setScopeTarget(scope, t, false)
+				if !send(added, t) {
+					close(added)
+					return
+				}
+			case t := <-d:
+				filter.setScopeTarget(scope, t, true)
+				if !send(deleted, t) {
+					close(added)
+					return
+				}
+			case s := <-selectors:
+				if s.String() == labelSelector.String() {
 					continue
 				}
-
-				switch e.Type {
-				case watch.Added, watch.Modified:
-					filter.visit(pod, func(t *Target, conditionFound bool) {
-						if conditionFound {
-							added <- t
-						} else {
-							deleted <- t
-						}
-					})
-				case watch.Deleted:
-					filter.forget(string(pod.UID))
+				klog.V(7).InfoS("Restart the pod watch because the selector was changed",
+					"old", labelSelector.String(), "new", s.String())
+				if !restart(s) {
+					close(added)
+					return
+				}
+			case <-refresh:
+				klog.V(7).InfoS("Re-evaluate targets")
+				if !reevaluate() {
+					close(added)
+					return
 				}
 			case <-ctx.Done():
-				watcher.Stop()
 				close(added)
 				return
 			}
 		}
-	}()
+	}(a, d, wcancel)
 
 	return added, deleted, nil
 }
+
+// notifier notifies the subscribers of changes, which are used to refresh
+// targets by re-evaluating them
+type notifier struct {
+	mu          sync.Mutex
+	subscribers []chan struct{}
+}
+
+// subscribe returns a channel to be notified of changes
+func (n *notifier) subscribe() <-chan struct{} {
+	ch := make(chan struct{}, 1)
+	n.mu.Lock()
+	n.subscribers = append(n.subscribers, ch)
+	n.mu.Unlock()
+	return ch
+}
+
+func (n *notifier) notify() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, ch := range n.subscribers {
+		// The notifications are coalesced while the subscriber is busy
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// mergeNotifications returns a channel to be notified when any of the
+// channels is notified. It returns nil if no channel is given.
+func mergeNotifications(ctx context.Context, chs ...<-chan struct{}) <-chan struct{} {
+	var filtered []<-chan struct{}
+	for _, ch := range chs {
+		if ch != nil {
+			filtered = append(filtered, ch)
+		}
+	}
+	switch len(filtered) {
+	case 0:
+		return nil
+	case 1:
+		return filtered[0]
+	}
+	merged := make(chan struct{}, 1)
+	for _, ch := range filtered {
+		go func() {
+			for {
+				select {
+				case <-ch:
+					select {
+					case merged <- struct{}{}:
+					default:
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	return merged
+}
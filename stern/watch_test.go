@@ -0,0 +1,210 @@
+package stern
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func genWatchPod(name, version, nodeName string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "ns1",
+			Name:            name,
+			UID:             types.UID(name),
+			ResourceVersion: "1",
+			Labels:          map[string]string{"app": "api", "version": version},
+		},
+		Spec: corev1.PodSpec{
+			NodeName: nodeName,
+		},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{
+				{
+					Name:        "container1",
+					State:       corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+					ContainerID: "id-" + name,
+				},
+			},
+		},
+	}
+}
+
+// fakePodWatchers returns the channel receiving the fake watchers of the pod
+// watches started on the client
+func fakePodWatchers(client *fake.Clientset) chan *watch.FakeWatcher {
+	watchers := make(chan *watch.FakeWatcher, 3)
+	client.PrependWatchReactor("pods", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		w := watch.NewFake()
+		watchers <- w
+		return true, w, nil
+	})
+	return watchers
+}
+
+func TestWatchTargetsWithSelectorSharedTarget(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := fake.NewSimpleClientset()
+	watchers := fakePodWatchers(client)
+	filter := newTargetFilter(targetFilterConfig{
+		podFilter:       regexp.MustCompile(""),
+		containerFilter: regexp.MustCompile(".*"),
+		containerStates: []ContainerState{RUNNING},
+	})
+	selectors1 := make(chan labels.Selector)
+	selectors2 := make(chan labels.Selector)
+
+	// the first query selects pods by version, and the second one by app
+	added, deleted, err := watchTargetsWithSelector(ctx, client.CoreV1().Pods("ns1"), "ns1",
+		labels.SelectorFromSet(labels.Set{"version": "v1"}), selectors1, nil, fields.Everything(), filter)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w1 := <-watchers
+	_, deleted2, err := watchTargetsWithSelector(ctx, client.CoreV1().Pods("ns1"), "ns1",
+		labels.SelectorFromSet(labels.Set{"app": "api"}), selectors2, nil, fields.Everything(), filter.withPodFilter(regexp.MustCompile(""), nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	<-watchers
+
+	go w1.Add(genWatchPod("pod1", "v1", ""))
+	var target *Target
+	select {
+	case target = <-added:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out")
+	}
+
+	// pod1 is kept because the second query still matches it
+	selectors1 <- labels.SelectorFromSet(labels.Set{"version": "v2"})
+	<-watchers
+	select {
+	case target := <-deleted:
+		t.Errorf("expected no targets to be deleted, but %s was deleted", target.GetID())
+	case <-time.After(100 * time.Millisecond):
+	}
+	if !filter.isActive(target) {
+		t.Errorf("expected pod1 to be active")
+	}
+
+	// pod1 is deleted by the second query that took it over
+	selectors2 <- labels.SelectorFromSet(labels.Set{"app": "web"})
+	<-watchers
+	select {
+	case target := <-deleted2:
+		if target.GetID() != "ns1-pod1-container1" {
+			t.Errorf("expected pod1 to be deleted, but actual %s", target.GetID())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out")
+	}
+}
+
+func TestWatchTargetsWithSelector(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	receive := func(ch chan *Target) *Target {
+		select {
+		case target := <-ch:
+			return target
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out")
+		}
+		return nil
+	}
+
+	client := fake.NewSimpleClientset()
+	watchers := fakePodWatchers(client)
+	filter := newTargetFilter(targetFilterConfig{
+		podFilter:       regexp.MustCompile(""),
+		containerFilter: regexp.MustCompile(".*"),
+		containerStates: []ContainerState{RUNNING},
+		nodes:           &objectWatcher{objects: map[string]map[string]string{"node1": nil}},
+		metrics:         NewMetrics(),
+	})
+	selectors := make(chan labels.Selector)
+	refresh := make(chan struct{})
+
+	added, deleted, err := watchTargetsWithSelector(ctx,
+		client.CoreV1().Pods("ns1"),
+		"ns1",
+		labels.SelectorFromSet(labels.Set{"version": "v1"}),
+		selectors,
+		refresh,
+		fields.Everything(),
+		filter,
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	w1 := <-watchers
+	go w1.Add(genWatchPod("pod1", "v1", "node1"))
+	if target := receive(added); target.GetID() != "ns1-pod1-container1" {
+		t.Errorf("expected pod1 to be added, but actual %s", target.GetID())
+	}
+
+	// the same selector does not restart the watch
+	selectors <- labels.SelectorFromSet(labels.Set{"version": "v1"})
+	// pod1 is stopped because it does not match the new selector
+	selectors <- labels.SelectorFromSet(labels.Set{"version": "v2"})
+	target := receive(deleted)
+	if target.GetID() != "ns1-pod1-container1" {
+		t.Errorf("expected pod1 to be deleted, but actual %s", target.GetID())
+	}
+	if filter.isActive(target) {
+		t.Errorf("expected pod1 to be forgotten")
+	}
+
+	w2 := <-watchers
+	go w2.Add(genWatchPod("pod2", "v2", "node1"))
+	if target := receive(added); target.GetID() != "ns1-pod2-container1" {
+		t.Errorf("expected pod2 to be added, but actual %s", target.GetID())
+	}
+	if len(watchers) != 0 {
+		t.Errorf("expected the watch to be restarted only once")
+	}
+	if actual := testutil.ToFloat64(filter.c.metrics.watchReconnects.WithLabelValues("pods")); actual != 1 {
+		t.Errorf("expected the restart to be counted once, but actual %v", actual)
+	}
+
+	// pod2 is stopped because node1 left
+	filter.c.nodes.delete("node1")
+	refresh <- struct{}{}
+	if target := receive(deleted); target.GetID() != "ns1-pod2-container1" {
+		t.Errorf("expected pod2 to be deleted, but actual %s", target.GetID())
+	}
+
+	// pod2 and pod3 are listed because node1 joined again
+	if _, err := client.CoreV1().Pods("ns1").Create(ctx, genWatchPod("pod2", "v2", "node1"), metav1.CreateOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := client.CoreV1().Pods("ns1").Create(ctx, genWatchPod("pod3", "v2", "node1"), metav1.CreateOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	filter.c.nodes.set("node1", nil)
+	refresh <- struct{}{}
+	for _, id := range []string{"ns1-pod2-container1", "ns1-pod3-container1"} {
+		if target := receive(added); target.GetID() != id {
+			t.Errorf("expected %s to be added, but actual %s", id, target.GetID())
+		}
+	}
+	if len(watchers) != 0 {
+		t.Errorf("expected the watch not to be restarted by refresh")
+	}
+}